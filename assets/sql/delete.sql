@@ -1,4 +1,10 @@
 DROP TABLE IF EXISTS terms  CASCADE;
 DROP TABLE IF EXISTS docs  CASCADE;
 DROP TABLE IF EXISTS postings  CASCADE;
+DROP TABLE IF EXISTS document_text  CASCADE;
 DROP TABLE IF EXISTS frontier  CASCADE;
+DROP TABLE IF EXISTS inlinks  CASCADE;
+DROP TABLE IF EXISTS index_meta  CASCADE;
+DROP TABLE IF EXISTS doc_boost  CASCADE;
+DROP TABLE IF EXISTS domain_exclusions  CASCADE;
+DROP TABLE IF EXISTS corpus_stats  CASCADE;
@@ -21,6 +21,8 @@ CREATE TABLE IF NOT EXISTS docs (
   title TEXT,                     -- Optional title for display in search results
   snippet TEXT,                    -- Optional snippet for display in search results
   norm REAL,                       -- Vector magnitude for normalization in TF-IDF
+  title_len INTEGER NOT NULL DEFAULT 0, -- Number of terms in the title
+  revision INTEGER NOT NULL DEFAULT 0,  -- Bumped each time the document is re-indexed, so the ranker notices changes
   UNIQUE(domain, hash)              -- Prevent duplicates in same domain
 );
 
@@ -30,11 +32,21 @@ CREATE TABLE IF NOT EXISTS postings (
   term_id INTEGER NOT NULL,         -- Foreign key to terms table
   doc_id INTEGER NOT NULL,          -- Foreign key to docs table
   tf_raw INTEGER NOT NULL,          -- Raw term frequency in this document
+  tf_title INTEGER NOT NULL DEFAULT 0, -- Raw term frequency in this document's title
+  positions INTEGER[],             -- Word positions of the term in the body, for phrase queries; NULL if unknown
   PRIMARY KEY (term_id, doc_id),    -- Ensures unique term-doc pairs
   FOREIGN KEY (term_id) REFERENCES terms(id) ON DELETE CASCADE,
   FOREIGN KEY (doc_id) REFERENCES docs(id) ON DELETE CASCADE
 );
 
+-- Document text table stores the gzip-compressed visible text of each document
+-- Only populated when text capture is enabled, since it significantly increases storage
+CREATE TABLE IF NOT EXISTS document_text (
+  doc_id INTEGER PRIMARY KEY,       -- Foreign key to docs table
+  body BYTEA NOT NULL,              -- Gzip-compressed extracted text
+  FOREIGN KEY (doc_id) REFERENCES docs(id) ON DELETE CASCADE
+);
+
 -- Frontier table manages URLs to be crawled (breadth-first search queue)
 -- Tracks crawling state and URL hierarchy
 CREATE TABLE IF NOT EXISTS frontier (
@@ -42,11 +54,80 @@ CREATE TABLE IF NOT EXISTS frontier (
   url_norm TEXT NOT NULL UNIQUE,     -- Normalized URL for deduplication
   parent_url TEXT,                 -- The URL of the parent page (where this link was found)
   depth INTEGER NOT NULL,            -- Depth in the crawling tree
-  status INTEGER NOT NULL CHECK(status IN (0, 1, 2, 3)) -- 0: unvisited, 1: in progress, 2: complete, 3: failed
+  status INTEGER NOT NULL CHECK(status IN (0, 1, 2, 3, 4, 5)), -- 0: unvisited, 1: in progress, 2: complete, 3: failed, 4: skipped, 5: failed but retryable
+  priority REAL NOT NULL DEFAULT 0,  -- Heuristic crawl priority, higher is crawled first
+  failure_reason TEXT,               -- Why the URL failed (e.g. unsupported_language), NULL unless status is failed or retryable
+  attempts INTEGER NOT NULL DEFAULT 0 -- Times the URL failed transiently, counted towards the retry limit
+);
+
+-- Inlinks table records the link graph: one row per distinct (page, linked URL) edge
+-- Used for inbound-link counts in frontier prioritization and link-based ranking
+CREATE TABLE IF NOT EXISTS inlinks (
+  from_url TEXT NOT NULL,           -- The URL of the page containing the link
+  to_url_norm TEXT NOT NULL,        -- Normalized URL the link points to
+  PRIMARY KEY (from_url, to_url_norm)
 );
 
+-- Index metadata table records settings the stored statistics were computed with
+-- e.g. the TF scheme used for docs.norm, so searches can check they agree
+CREATE TABLE IF NOT EXISTS index_meta (
+  key TEXT PRIMARY KEY,             -- Setting name
+  value TEXT NOT NULL               -- Setting value
+);
+
+-- The prune generation always exists, so indexers can lock it while they write postings
+INSERT INTO index_meta (key, value) VALUES ('prune_generation', '0') ON CONFLICT (key) DO NOTHING;
+
+-- Doc boost table holds editorial score multipliers for pinning documents higher or lower
+-- Documents without a row are scored with a neutral boost of 1.0
+CREATE TABLE IF NOT EXISTS doc_boost (
+  doc_id INTEGER PRIMARY KEY,       -- Foreign key to docs table
+  boost REAL NOT NULL,              -- Score boost, see store.BoostMode for how it is applied
+  FOREIGN KEY (doc_id) REFERENCES docs(id) ON DELETE CASCADE
+);
+
+-- Domain exclusions table lists domains (and their subdomains) the crawler won't follow links to
+-- Typically mirrors found by store.FindDuplicateAcrossDomains
+CREATE TABLE IF NOT EXISTS domain_exclusions (
+  domain TEXT PRIMARY KEY,          -- Excluded domain, lowercase
+  reason TEXT                       -- Why it was excluded, e.g. "mirror of en.wikipedia.org"
+);
+
+-- Corpus stats table caches corpus-wide BM25 statistics, refreshed by the ranker
+-- Holds at most one row, so searches don't aggregate over docs on every query
+CREATE TABLE IF NOT EXISTS corpus_stats (
+  id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id), -- Constant key enforcing a single row
+  n REAL NOT NULL,                  -- Number of documents with at least one term
+  avgdl REAL,                       -- Average document length in terms
+  avgtl REAL,                       -- Average title length in terms
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now() -- When the ranker last refreshed the stats
+);
+
+-- Documents indexed before domain and hash were recorded; cmd/backfill fills them in.
+-- These must run before the indexes below, which cover the columns. The unique index
+-- shares the name of the UNIQUE(domain, hash) constraint, so it only adds it where missing.
+ALTER TABLE docs ADD COLUMN IF NOT EXISTS domain TEXT;
+ALTER TABLE docs ADD COLUMN IF NOT EXISTS hash TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS docs_domain_hash_key ON docs(domain, hash);
+
 -- Performance indexes for efficient querying
 CREATE INDEX IF NOT EXISTS idx_docs_domain_hash ON docs(domain);
+CREATE INDEX IF NOT EXISTS idx_docs_hash ON docs(hash);
 CREATE INDEX IF NOT EXISTS idx_frontier_status ON frontier(status);
+CREATE INDEX IF NOT EXISTS idx_frontier_status_priority ON frontier(status, priority DESC, depth);
 CREATE INDEX IF NOT EXISTS idx_postings_term ON postings(term_id);
 CREATE INDEX IF NOT EXISTS idx_postings_doc ON postings(doc_id);
+CREATE INDEX IF NOT EXISTS idx_inlinks_to ON inlinks(to_url_norm);
+
+-- Migrations for databases created before a column was introduced
+ALTER TABLE docs ADD COLUMN IF NOT EXISTS title TEXT;
+ALTER TABLE docs ADD COLUMN IF NOT EXISTS snippet TEXT;
+ALTER TABLE docs ADD COLUMN IF NOT EXISTS title_len INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE docs ADD COLUMN IF NOT EXISTS revision INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE postings ADD COLUMN IF NOT EXISTS tf_title INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE postings ADD COLUMN IF NOT EXISTS positions INTEGER[];
+ALTER TABLE frontier ADD COLUMN IF NOT EXISTS priority REAL NOT NULL DEFAULT 0;
+ALTER TABLE frontier ADD COLUMN IF NOT EXISTS failure_reason TEXT;
+ALTER TABLE frontier ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE frontier DROP CONSTRAINT IF EXISTS frontier_status_check;
+ALTER TABLE frontier ADD CONSTRAINT frontier_status_check CHECK(status IN (0, 1, 2, 3, 4, 5));
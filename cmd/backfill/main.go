@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/jdpolicano/go-search/internal/extract"
+	"github.com/jdpolicano/go-search/internal/logging"
+	"github.com/jdpolicano/go-search/internal/store"
+)
+
+func main() {
+	batchSize := flag.Int("batch", 500, "number of documents examined per batch")
+	hashAlgo := flag.String("hash", string(extract.HashSHA256), "content hash algorithm the crawler is configured with: sha256, sha1 or xxhash")
+	logFormat := flag.String("log-format", string(logging.FormatFromEnv()), "log output format: json or text")
+	flag.Parse()
+
+	format, err := logging.ParseLogFormat(*logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logger := logging.NewSampledLogger(slog.LevelInfo, format, os.Stdout, logging.SampleRateFromEnv())
+
+	// Hash with the crawler's algorithm, so backfilled hashes dedupe against new documents
+	algo := extract.HashAlgorithm(*hashAlgo)
+	if err := algo.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	hashText := func(text string) (string, error) {
+		return extract.HashTextWith(text, algo)
+	}
+
+	// Drop the same stop words from documents and queries, per GOSEARCH_STOP_WORDS
+	stopWords, err := extract.StopWordsFromEnv()
+	if err != nil {
+		logger.Error("Error loading stop words", "error", err)
+		os.Exit(2)
+	}
+	extract.SetStopWords(stopWords)
+
+	s, err := store.NewStore(store.DSNFromEnv())
+	if err != nil {
+		logger.Error("Error creating store", "error", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	var afterId int64
+	total := 0
+	for {
+		var updated int
+		var lastId int64
+		err := s.InTx(ctx, func(tx store.DBTX) error {
+			var err error
+			updated, lastId, err = store.BackfillDocMetadataBatch(ctx, tx, afterId, *batchSize, hashText)
+			return err
+		})
+		if err != nil {
+			logger.Error("Error backfilling documents", "afterId", afterId, "error", err)
+			os.Exit(1)
+		}
+
+		if lastId == afterId {
+			break
+		}
+
+		total += updated
+		afterId = lastId
+		logger.Info("Backfilled batch", "updated", updated, "total", total, "lastId", lastId)
+	}
+
+	logger.Info("Backfill finished", "updated", total)
+}
@@ -2,32 +2,24 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
 	"sync"
-	"time"
+	"syscall"
 
 	"github.com/jdpolicano/go-search/internal/crawler"
-	"github.com/jdpolicano/go-search/internal/extract/language"
+	"github.com/jdpolicano/go-search/internal/extract"
 	"github.com/jdpolicano/go-search/internal/logging"
 	"github.com/jdpolicano/go-search/internal/store"
 )
 
-func main() {
-	logger := logging.NewLogger(slog.LevelInfo)
-
-	// // Load the .env file
-	// err := godotenv.Load()
-	// if err != nil {
-	// 	// Log a fatal error if the file cannot be loaded
-	// 	log.Fatalf("Error loading .env file: %s", err)
-	// }
-
-	s, err := store.NewStore("db/store.db")
-	if err != nil {
-		logger.Error("Error creating store", "error", err)
-		return
-	}
-	seeds := []string{
+// defaultCrawl is crawled when no config file is given.
+var defaultCrawl = crawler.CrawlConfig{
+	Seeds: []string{
 		"https://en.wikipedia.org/wiki/Artificial_intelligence",
 		"https://en.wikipedia.org/wiki/C_(programming_language)",
 		"https://en.wikipedia.org/wiki/Google_Search",
@@ -56,19 +48,129 @@ func main() {
 		"https://en.wikipedia.org/wiki/Computer_science",
 		"https://en.wikipedia.org/wiki/Programmer",
 		"https://en.wikipedia.org/wiki/Software",
+	},
+	Langs: []string{"en"},
+}
+
+func main() {
+	configPath := flag.String("config", "", "JSON crawl config file; the built-in seeds are crawled when empty")
+	seeds := flag.String("seeds", "", "comma separated seed URLs, replacing the config's")
+	langs := flag.String("langs", "", "comma separated ISO 639 language codes, replacing the config's")
+	scope := flag.String("scope", "", "comma separated domains (and subdomains) to stay within, replacing the config's")
+	block := flag.String("block", "", "comma separated domains (and subdomains) never to crawl, replacing the config's")
+	maxDepth := flag.Int("max-depth", 0, "deepest link distance from a seed to crawl; 0 is unlimited")
+	budget := flag.Int("budget", 0, "maximum pages crawled per domain; 0 is unlimited")
+	delay := flag.Duration("delay", crawler.DefaultCrawlerConfig().PolitenessDelay, "minimum time between fetches to one host")
+	userAgent := flag.String("user-agent", "", "User-Agent sent with every request")
+	stem := flag.Bool("stem", false, "index Porter stems of English words; must match the existing index")
+	skipBoilerplate := flag.Bool("skip-boilerplate", false, "leave text in navigation, headers and footers out of the index")
+	keepNumbers := flag.Bool("keep-numbers", false, "index numbers, decimals and versions; must match the existing index")
+	duration := flag.Duration("duration", 0, "how long to crawl before shutting down gracefully; 0 crawls until interrupted")
+	flag.Parse()
+
+	logger := logging.NewLogger(slog.LevelInfo)
+
+	// Drop the same stop words from documents and queries, per GOSEARCH_STOP_WORDS
+	stopWords, err := extract.StopWordsFromEnv()
+	if err != nil {
+		logger.Error("Error loading stop words", "error", err)
+		os.Exit(2)
+	}
+	extract.SetStopWords(stopWords)
+
+	cc := defaultCrawl
+	if *configPath != "" {
+		loaded, err := crawler.LoadCrawlConfig(*configPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error loading crawl config:", err)
+			os.Exit(2)
+		}
+		cc = loaded
+	}
+
+	// Flags given on the command line override the config file
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "seeds":
+			cc.Seeds = strings.Split(*seeds, ",")
+		case "langs":
+			cc.Langs = strings.Split(*langs, ",")
+		case "scope":
+			cc.Scope = strings.Split(*scope, ",")
+		case "block":
+			cc.Block = strings.Split(*block, ",")
+		case "max-depth":
+			cc.MaxDepth = *maxDepth
+		case "budget":
+			cc.DomainBudget = budget
+		case "delay":
+			d := crawler.Duration(*delay)
+			cc.PolitenessDelay = &d
+		case "user-agent":
+			cc.UserAgent = *userAgent
+		case "stem":
+			cc.Stemming = stem
+		case "keep-numbers":
+			cc.KeepNumbers = keepNumbers
+		case "skip-boilerplate":
+			cc.SkipBoilerplate = skipBoilerplate
+		}
+	})
+	if err := cc.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid crawl config:", err)
+		os.Exit(2)
+	}
+
+	// // Load the .env file
+	// err := godotenv.Load()
+	// if err != nil {
+	// 	// Log a fatal error if the file cannot be loaded
+	// 	log.Fatalf("Error loading .env file: %s", err)
+	// }
+
+	s, err := store.NewStore(store.DSNFromEnv())
+	if err != nil {
+		logger.Error("Error creating store", "error", err)
+		return
 	}
-	supportedLangs := []language.Language{language.English}
 	wg := sync.WaitGroup{}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	index, err := crawler.NewIndex(ctx, cancel, s, seeds, supportedLangs, &wg, logger)
+	// Share write slots with a ranker running against the same database
+	base := crawler.DefaultCrawlerConfig()
+	base.WriteLimiter = store.NewSharedWriteLimiter(4, s.Pool)
+	index, err := crawler.NewIndexFromConfig(ctx, cancel, s, cc, base, nil, &wg, logger)
 	if err != nil {
 		logger.Error("Error creating index", "error", err)
 		return
 	}
-	logger.Info("Starting crawler...")
-	go index.Run()
-	time.Sleep(60 * time.Second * 60) // run for 60 minutes
-	cancel()
+	// Stop gracefully on SIGINT/SIGTERM or once the duration is up. Signal handling is
+	// then restored, so a second interrupt exits without waiting for pages in flight.
+	runCtx, stopSignals := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	if *duration > 0 {
+		var cancelRun context.CancelFunc
+		runCtx, cancelRun = context.WithTimeout(runCtx, *duration)
+		defer cancelRun()
+	}
+	context.AfterFunc(runCtx, func() {
+		stopSignals()
+		logger.Info("Shutting down, interrupt again to exit immediately")
+	})
+
+	logger.Info("Starting crawler...", "duration", *duration)
+	index.Run(runCtx)
+	summary := index.Close()
 	wg.Wait()
+
+	if err := summary.WriteTable(os.Stdout); err != nil {
+		logger.Error("Error writing crawl summary", "error", err)
+	}
+
+	failures, err := store.GetFailureReasonCounts(context.Background(), s.Pool)
+	if err != nil {
+		logger.Error("Error counting failures", "error", err)
+		return
+	}
+	logger.Info("Crawl failures by reason", "failures", failures)
 }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/jdpolicano/go-search/internal/extract"
+	"github.com/jdpolicano/go-search/internal/extract/language"
+	"github.com/jdpolicano/go-search/internal/logging"
+	"github.com/jdpolicano/go-search/internal/store"
+)
+
+// record is a single newline-delimited JSON document read from stdin.
+type record struct {
+	Url  string `json:"url"`
+	Html string `json:"html"`
+}
+
+func main() {
+	logger := logging.NewLogger(slog.LevelInfo)
+
+	// Drop the same stop words from documents and queries, per GOSEARCH_STOP_WORDS
+	stopWords, err := extract.StopWordsFromEnv()
+	if err != nil {
+		logger.Error("Error loading stop words", "error", err)
+		os.Exit(2)
+	}
+	extract.SetStopWords(stopWords)
+
+	s, err := store.NewStore(store.DSNFromEnv())
+	if err != nil {
+		logger.Error("Error creating store", "error", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	if err := store.UseIndexTokenizer(ctx, s.Pool); err != nil {
+		logger.Error("Error reading index tokenizer settings", "error", err)
+		os.Exit(1)
+	}
+	if err := store.CheckIndexStopWords(ctx, s.Pool, extract.StopWordsHash()); err != nil {
+		logger.Error("Error checking index stop words", "error", err)
+		os.Exit(1)
+	}
+	parser := extract.NewHtmlParser([]language.Language{language.English})
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64<<20) // allow large pages on a single line
+
+	line, indexed, failed := 0, 0, 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			logger.Error("Error decoding record", "line", line, "error", err)
+			failed++
+			continue
+		}
+
+		if err := ingest(ctx, s, parser, rec); err != nil {
+			logger.Error("Error ingesting record", "line", line, "url", rec.Url, "error", err)
+			failed++
+			continue
+		}
+
+		logger.Info("Indexed document successfully", "line", line, "url", rec.Url)
+		indexed++
+	}
+
+	if err := scanner.Err(); err != nil {
+		logger.Error("Error reading stdin", "line", line, "error", err)
+	}
+
+	logger.Info("Ingestion finished", "records", indexed+failed, "indexed", indexed, "failed", failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// ingest parses, extracts and indexes a single record in its own transaction.
+func ingest(ctx context.Context, s store.Store, parser *extract.HtmlParser, rec record) error {
+	if rec.Url == "" {
+		return errors.New("record is missing a url")
+	}
+
+	doc, err := parser.Parse(strings.NewReader(rec.Html))
+	if err != nil {
+		return err
+	}
+
+	extracted, err := extract.ProcessHtmlDocument(doc)
+	if err != nil {
+		return err
+	}
+
+	entry, err := store.NewIndexEntry(rec.Url, extracted.Hash, extracted.Len, extracted.TermFreqs)
+	if err != nil {
+		return err
+	}
+	if err := entry.SetTitle(extracted.Title); err != nil {
+		return err
+	}
+	entry.Positions = extracted.Positions
+	entry.Snippet = extracted.Snippet
+
+	return s.InTx(ctx, func(tx store.DBTX) error {
+		return store.IndexDocumentInit(ctx, tx, entry)
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/jdpolicano/go-search/internal/logging"
+	"github.com/jdpolicano/go-search/internal/store"
+)
+
+func main() {
+	exclude := flag.String("exclude", "", "exclude this domain from future crawls and remove its documents, instead of reporting mirrors")
+	include := flag.String("include", "", "lift the exclusion of this domain")
+	reason := flag.String("reason", "mirror", "reason recorded with -exclude")
+	flag.Parse()
+
+	logger := logging.NewLogger(slog.LevelWarn)
+
+	s, err := store.NewStore(store.DSNFromEnv())
+	if err != nil {
+		logger.Error("Error creating store", "error", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	switch {
+	case *exclude != "":
+		removed, err := store.ExcludeDomain(ctx, s.Pool, *exclude, *reason)
+		if err != nil {
+			logger.Error("Error excluding domain", "domain", *exclude, "error", err)
+			os.Exit(1)
+		}
+		fmt.Println("excluded", *exclude, "and removed", removed, "documents")
+	case *include != "":
+		if err := store.IncludeDomain(ctx, s.Pool, *include); err != nil {
+			logger.Error("Error including domain", "domain", *include, "error", err)
+			os.Exit(1)
+		}
+		fmt.Println("included", *include)
+	default:
+		groups, err := store.FindDuplicateAcrossDomains(ctx, s.Reader())
+		if err != nil {
+			logger.Error("Error finding duplicates", "error", err)
+			os.Exit(1)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(groups); err != nil {
+			logger.Error("Error encoding duplicates", "error", err)
+			os.Exit(1)
+		}
+	}
+}
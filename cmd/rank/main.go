@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -14,9 +16,20 @@ import (
 )
 
 func main() {
-	logger := logging.NewLogger(slog.LevelInfo)
+	explain := flag.Bool("explain", false, "log what each ranking phase would update, then exit without updating")
+	minDF := flag.Int("min-df", 0, "leave terms appearing in fewer documents out of document norms")
+	pruneMinDF := flag.Int("prune-min-df", 0, "delete terms appearing in fewer documents and their postings, then exit")
+	logFormat := flag.String("log-format", string(logging.FormatFromEnv()), "log output format: json or text")
+	flag.Parse()
 
-	s, err := store.NewStore("db/store.db")
+	format, err := logging.ParseLogFormat(*logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logger := logging.NewSampledLogger(slog.LevelInfo, format, os.Stdout, logging.SampleRateFromEnv())
+
+	s, err := store.NewStore(store.DSNFromEnv())
 	if err != nil {
 		logger.Error("Error creating store", "error", err)
 		os.Exit(1)
@@ -26,7 +39,32 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	ranker := rank.NewRanker(s, logger, 10*time.Minute)
+	if *pruneMinDF > 0 {
+		pruned, err := store.PruneRareTerms(ctx, s.Pool, *pruneMinDF)
+		if err != nil {
+			logger.Error("Error pruning rare terms", "minDF", *pruneMinDF, "error", err)
+			os.Exit(1)
+		}
+		logger.Info("Pruned rare terms", "minDF", *pruneMinDF, "pruned", pruned)
+		return
+	}
+
+	cfg := rank.DefaultRankerConfig()
+	cfg.MinDF = *minDF
+	cfg.WriteLimiter = store.NewSharedWriteLimiter(4, s.Pool)
+	ranker, err := rank.NewRanker(s, logger, 10*time.Minute, cfg)
+	if err != nil {
+		logger.Error("Error creating ranker", "error", err)
+		os.Exit(1)
+	}
+
+	if *explain {
+		if _, err := ranker.Explain(ctx); err != nil {
+			logger.Error("Ranking explain error", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/jdpolicano/go-search/internal/extract"
+	"github.com/jdpolicano/go-search/internal/logging"
+	"github.com/jdpolicano/go-search/internal/server"
+	"github.com/jdpolicano/go-search/internal/store"
+)
+
+func main() {
+	limit := flag.Int("limit", 10, "maximum number of results to print")
+	offset := flag.Int("offset", 0, "number of top results to skip")
+	minMatch := flag.Int("min-match", 0, "distinct query terms a result must contain (0 = auto)")
+	minDF := flag.Int("min-df", 0, "ignore query terms appearing in fewer documents (0 = keep all)")
+	boostMode := flag.String("boost-mode", string(store.BoostMultiply), "how doc boosts adjust scores: multiply or add")
+	logFormat := flag.String("log-format", string(logging.FormatFromEnv()), "log output format: json or text")
+	parallelTerms := flag.Int("parallel-terms", 0, "split queries with at least this many terms into concurrently searched groups; 0 disables")
+	parallelism := flag.Int("parallelism", 4, "number of term groups searched concurrently")
+	asJSON := flag.Bool("json", false, "print results as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <query>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	format, err := logging.ParseLogFormat(*logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logger := logging.NewSampledLogger(slog.LevelWarn, format, os.Stdout, logging.SampleRateFromEnv())
+
+	// Drop the same stop words from documents and queries, per GOSEARCH_STOP_WORDS
+	stopWords, err := extract.StopWordsFromEnv()
+	if err != nil {
+		logger.Error("Error loading stop words", "error", err)
+		os.Exit(2)
+	}
+	extract.SetStopWords(stopWords)
+
+	if err := store.BoostMode(*boostMode).Validate(); err != nil {
+		logger.Error("Invalid boost mode", "error", err)
+		os.Exit(2)
+	}
+
+	query := strings.Join(flag.Args(), " ")
+	if query == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	s, err := store.NewStore(store.DSNFromEnv())
+	if err != nil {
+		logger.Error("Error creating store", "error", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+
+	// Tokenize with the server's tokenizer and the index's settings so results match the HTTP API
+	if err := store.UseIndexTokenizer(context.Background(), s.Reader()); err != nil {
+		logger.Error("Error reading index tokenizer settings", "error", err)
+		os.Exit(1)
+	}
+	terms, phrases, err := server.ParseQuery(query)
+	if err != nil {
+		logger.Error("Error tokenizing query", "query", query, "error", err)
+		os.Exit(1)
+	}
+
+	results, err := store.SearchBM25(context.Background(), s.Reader(), terms, store.SearchOptions{Limit: *limit, Offset: *offset, MinDistinctMatches: *minMatch, MinDF: *minDF, BoostMode: store.BoostMode(*boostMode), Phrases: phrases, Parallel: store.ParallelOptions{MinTerms: *parallelTerms, Concurrency: *parallelism}})
+	if err != nil {
+		logger.Error("Search failed", "query", query, "terms", terms, "error", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			logger.Error("Error encoding results", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	for i, result := range results {
+		title := ""
+		if result.Title != nil {
+			title = *result.Title
+		}
+		fmt.Printf("%3d. %.4f  %s\n", *offset+i+1, result.Score, result.URL)
+		if title != "" {
+			fmt.Printf("     %s\n", title)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/jdpolicano/go-search/internal/extract"
 	"github.com/jdpolicano/go-search/internal/logging"
 	"github.com/jdpolicano/go-search/internal/server"
 	"github.com/jdpolicano/go-search/internal/store"
@@ -17,14 +18,37 @@ import (
 func main() {
 	logger := logging.NewLogger(slog.LevelInfo)
 
-	s, err := store.NewStore("db/store.db")
+	// Drop the same stop words from documents and queries, per GOSEARCH_STOP_WORDS
+	stopWords, err := extract.StopWordsFromEnv()
+	if err != nil {
+		logger.Error("Error loading stop words", "error", err)
+		os.Exit(2)
+	}
+	extract.SetStopWords(stopWords)
+
+	// Serve queries from a read replica when one is configured.
+	var s store.Store
+	if replicaDSN := os.Getenv("GOSEARCH_READ_REPLICA_DSN"); replicaDSN != "" {
+		s, err = store.NewStoreWithReadReplica(store.DSNFromEnv(), replicaDSN)
+	} else {
+		s, err = store.NewStore(store.DSNFromEnv())
+	}
 	if err != nil {
 		logger.Error("Error creating store", "error", err)
 		os.Exit(1)
 	}
-	defer s.Pool.Close()
+	defer s.Close()
+
+	// Tokenize queries the way the index's documents were tokenized
+	if err := store.UseIndexTokenizer(context.Background(), s.Reader()); err != nil {
+		logger.Error("Error reading index tokenizer settings", "error", err)
+		os.Exit(1)
+	}
+
+	cfg := server.DefaultServerConfig()
+	cfg.AdminToken = os.Getenv("GOSEARCH_ADMIN_TOKEN")
 
-	srv := server.NewServer(s, logger)
+	srv := server.NewServer(s, cfg, nil, logger)
 
 	serverCtx, serverCancel := context.WithCancel(context.Background())
 	defer serverCancel()
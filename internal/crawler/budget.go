@@ -0,0 +1,62 @@
+// Package crawler contains per-domain crawl budget tracking.
+package crawler
+
+import (
+	"maps"
+	"sync"
+)
+
+// domainBudget caps how many pages are crawled per domain so one large site
+// can't consume the whole crawl. A limit below 1 means unlimited, and a nil
+// *domainBudget allows everything without counting.
+type domainBudget struct {
+	mu     sync.Mutex     // Guards counts
+	counts map[string]int // Pages crawled so far, by domain
+	limit  int            // Maximum pages per domain
+}
+
+// newDomainBudget creates a domainBudget starting from previously recorded counts.
+func newDomainBudget(limit int, initial map[string]int) *domainBudget {
+	counts := make(map[string]int, len(initial))
+	maps.Copy(counts, initial)
+	return &domainBudget{counts: counts, limit: limit}
+}
+
+// Take records a crawl for the domain, returning false without recording it if the
+// domain has already used up its budget. The crawl is charged up front so concurrent
+// workers can't overshoot the budget; Refund returns it if the fetch fails.
+func (b *domainBudget) Take(domain string) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limit > 0 && b.counts[domain] >= b.limit {
+		return false
+	}
+	b.counts[domain]++
+	return true
+}
+
+// Refund returns a crawl taken for the domain whose fetch failed, so only pages
+// actually fetched count against the budget.
+func (b *domainBudget) Refund(domain string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.counts[domain] > 0 {
+		b.counts[domain]--
+	}
+}
+
+// Counts returns a snapshot of the pages crawled per domain.
+func (b *domainBudget) Counts() map[string]int {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return maps.Clone(b.counts)
+}
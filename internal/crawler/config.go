@@ -0,0 +1,100 @@
+// Package crawler contains configuration for the crawling pipeline.
+package crawler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jdpolicano/go-search/internal/extract"
+	"github.com/jdpolicano/go-search/internal/store"
+)
+
+// CrawlerConfig holds the tunable settings for the crawling pipeline.
+type CrawlerConfig struct {
+	Fetcher              Fetcher                  // Fetches page content; nil uses an HttpFetcher configured by Fetch
+	Fetch                FetchConfig              // Settings for the default HttpFetcher
+	CrawlWorkers         int                      // Number of pages fetched concurrently across all hosts; below 1 is treated as 1
+	MaxConcurrentPerHost int                      // Maximum number of in-flight fetches to a single host, counted until each body is read
+	PolitenessDelay      time.Duration            // Minimum time between starting fetches to a single host, e.g. 1s for at most 1 req/s; 0 disables
+	DomainDelays         map[string]time.Duration // Politeness delays by host, "*.domain" for subdomains or "*" for all others, overriding PolitenessDelay; host and domain entries also override robots.txt Crawl-delay
+	FetchRetry           store.RetryPolicy        // Retries of transient fetch failures (network errors, 429 and 5xx)
+	MaxRetryRuns         int                      // Runs a URL may fail transiently in before it is marked failed for good; 0 retries indefinitely
+	RespectRobots        bool                     // Skip URLs disallowed by robots.txt and honor its Crawl-delay
+	RobotsTTL            time.Duration            // How long a host's robots.txt is cached before being fetched again; 0 never refetches
+	PolitenessJitter     float64                  // Up to this fraction is randomly added to each politeness delay, never subtracted, to avoid synchronized bursts
+	JitterSeed           int64                    // Seed for the jitter RNG, for reproducible schedules; 0 seeds randomly
+	StoreDocumentText    bool                     // Persist extracted text for snippets and re-ranking; costs significant storage
+	MinDocumentTerms     int                      // Documents with fewer terms after stop-word removal are not indexed; pages with no terms never are
+	SkipRefreshStubs     bool                     // Don't index pages that immediately meta-refresh elsewhere
+	DiscoveryPaths       []string                 // Paths probed on each seed host and crawled if found; empty disables discovery
+	PriorityWeights      store.PriorityWeights    // Heuristic weights used to prioritize discovered URLs
+	URLFilters           FilterChain              // Filters every discovered link must pass to be queued
+	MaxLinksPerPage      int                      // Most links queued from a single page, keeping the highest priority; 0 is unlimited
+	DomainBudget         int                      // Maximum pages crawled per domain, including earlier runs; 0 is unlimited
+	MaxFrontierSize      int                      // Maximum number of unvisited URLs kept in the frontier; 0 is unlimited
+	FrontierEviction     store.TrimPolicy         // What to evict when the frontier is full; TrimNone drops new URLs instead
+	TermCacheSize        int                      // Number of term ids cached by the indexer; 0 disables the cache
+	Readability          bool                     // Index only a page's main content when it can be identified
+	Extract              extract.Options          // Content extraction settings, such as the dedup hash algorithm
+	Stemming             bool                     // Reduce terms to their Porter stems; must match the existing index, English only
+	KeepNumbers          bool                     // Index numbers, decimals and versions; must match the existing index, grows it noticeably
+	StreamingExtraction  bool                     // Extract in one pass without a document tree; saves memory, caps text at extract.MaxStreamText and drops Readability
+	WriteLimiter         *store.WriteLimiter      // Throttles index writes under lock contention; use NewSharedWriteLimiter to include other processes
+	IndexBatchSize       int                      // Documents committed per index transaction; 1 commits each document alone
+	IndexFlushInterval   time.Duration            // Longest a partial index batch waits before being committed
+}
+
+// DefaultCrawlerConfig returns a CrawlerConfig populated with safe defaults.
+func DefaultCrawlerConfig() CrawlerConfig {
+	return CrawlerConfig{
+		Fetch:                DefaultFetchConfig(),
+		CrawlWorkers:         8,
+		MaxConcurrentPerHost: 2,
+		PolitenessDelay:      time.Second,
+		FetchRetry:           store.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second},
+		MaxRetryRuns:         3,
+		RespectRobots:        true,
+		RobotsTTL:            24 * time.Hour,
+		PolitenessJitter:     0.2,
+		SkipRefreshStubs:     true,
+		PriorityWeights:      store.DefaultPriorityWeights(),
+		URLFilters:           FilterChain{SchemeFilter("http", "https")},
+		TermCacheSize:        50000,
+		Extract:              extract.DefaultOptions(),
+		WriteLimiter:         store.NewWriteLimiter(4),
+		IndexBatchSize:       1,
+		IndexFlushInterval:   2 * time.Second,
+	}
+}
+
+// fetcher returns the configured Fetcher, or an HttpFetcher built from Fetch if none is set.
+func (cfg CrawlerConfig) fetcher() Fetcher {
+	if cfg.Fetcher != nil {
+		return cfg.Fetcher
+	}
+	return NewHttpFetcher(cfg.Fetch)
+}
+
+// userAgent returns the User-Agent the default HttpFetcher sends.
+func (cfg CrawlerConfig) userAgent() string {
+	for name, value := range cfg.Fetch.Headers {
+		if http.CanonicalHeaderKey(name) == "User-Agent" {
+			return value
+		}
+	}
+	return DefaultUserAgent
+}
+
+// robots returns the RobotsCache the crawler checks URLs against, or nil if robots.txt
+// isn't respected. It fetches with the configured Fetcher if set, otherwise with a plain
+// HttpFetcher sending the same headers, since robots.txt is neither HTML nor rendered.
+func (cfg CrawlerConfig) robots() *RobotsCache {
+	if !cfg.RespectRobots {
+		return nil
+	}
+	fetcher := cfg.Fetcher
+	if fetcher == nil {
+		fetcher = NewHttpFetcher(FetchConfig{Headers: cfg.Fetch.Headers, DomainHeaders: cfg.Fetch.DomainHeaders, CookieJar: cfg.Fetch.CookieJar, Timeout: cfg.Fetch.Timeout})
+	}
+	return NewRobotsCache(fetcher, cfg.userAgent(), cfg.RobotsTTL)
+}
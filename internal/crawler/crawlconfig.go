@@ -0,0 +1,234 @@
+// Package crawler contains the per-run crawl configuration loaded by cmd/crawler.
+package crawler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jdpolicano/go-search/internal/extract/language"
+	"github.com/jdpolicano/go-search/internal/store"
+)
+
+// Duration is a time.Duration that reads from JSON as a string such as "1.5s".
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"1s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON formats the duration as a string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// CrawlConfig describes one crawl run: what to crawl and how politely. It is the
+// file format read by cmd/crawler; absent fields keep the CrawlerConfig defaults.
+// Fields whose zero value is a setting of its own, such as a politeness_delay of 0,
+// are pointers so that setting them to zero can be told apart from leaving them out.
+// Embedders can keep building a CrawlerConfig and calling NewIndex directly.
+type CrawlConfig struct {
+	Seeds            []string            `json:"seeds"`              // Starting URLs
+	Scope            []string            `json:"scope"`              // Domains (and their subdomains) links may lead to; empty is unrestricted
+	Block            []string            `json:"block"`              // Domains (and their subdomains) links may never lead to, even within Scope
+	MaxDepth         int                 `json:"max_depth"`          // Deepest link distance from a seed to crawl; 0 is unlimited
+	DomainBudget     *int                `json:"domain_budget"`      // Maximum pages per domain; 0 is unlimited
+	PolitenessDelay  *Duration           `json:"politeness_delay"`   // Minimum time between fetches to one host; 0 disables
+	DomainDelays     map[string]Duration `json:"domain_delays"`      // Per-domain overrides of PolitenessDelay, see CrawlerConfig.DomainDelays
+	MaxPerHost       int                 `json:"max_per_host"`       // Maximum in-flight fetches to one host
+	UserAgent        string              `json:"user_agent"`         // User-Agent sent with every request; empty keeps the default
+	Langs            []string            `json:"langs"`              // ISO 639-1 or 639-3 codes of the languages to index
+	MaxLinksPerPage  *int                `json:"max_links_per_page"` // Most links queued from one page; 0 is unlimited
+	MaxBodySize      *int64              `json:"max_body_size"`      // Largest page body in bytes; larger pages fail. 0 is unlimited
+	MinDocumentTerms *int                `json:"min_document_terms"` // Pages with fewer terms are crawled but not indexed; pages with no terms never are
+	Stemming         *bool               `json:"stemming"`           // Index Porter stems of English words; must match the existing index
+	KeepNumbers      *bool               `json:"keep_numbers"`       // Index numbers, decimals and versions; must match the existing index
+	SkipBoilerplate  *bool               `json:"skip_boilerplate"`   // Leave text in navigation, headers and footers out of the index
+	BoilerplateTags  []string            `json:"boilerplate_tags"`   // Elements skipped by skip_boilerplate; empty keeps the defaults
+	BoilerplateRoles []string            `json:"boilerplate_roles"`  // Role attribute values skipped by skip_boilerplate; empty keeps the defaults
+	MetaDescription  *bool               `json:"meta_description"`   // Index the words of each page's meta description
+	MetaKeywords     *bool               `json:"meta_keywords"`      // Index the words of each page's meta keywords
+	ImageAlt         *bool               `json:"image_alt"`          // Index the alt text of images
+	MetadataWeight   int                 `json:"metadata_weight"`    // Times each metadata word counts toward term frequency; 0 keeps the default of 1
+}
+
+// LoadCrawlConfig reads a CrawlConfig from a JSON file, rejecting unknown fields so
+// typos aren't silently ignored, and validates it.
+func LoadCrawlConfig(path string) (CrawlConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return CrawlConfig{}, err
+	}
+	defer f.Close()
+
+	var cc CrawlConfig
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cc); err != nil {
+		return CrawlConfig{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cc.Validate(); err != nil {
+		return CrawlConfig{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return cc, nil
+}
+
+// Validate checks the configuration, returning every problem found rather than only the first.
+func (cc CrawlConfig) Validate() error {
+	var errs []error
+	if len(cc.Seeds) == 0 {
+		errs = append(errs, errors.New("seeds: at least one seed is required"))
+	}
+	for _, seed := range cc.Seeds {
+		if _, err := store.NormalizeURL(seed); err != nil {
+			errs = append(errs, fmt.Errorf("seeds: invalid url %q: %w", seed, err))
+		}
+	}
+	if len(cc.Langs) == 0 {
+		errs = append(errs, errors.New("langs: at least one language is required"))
+	}
+	for _, code := range cc.Langs {
+		if _, ok := language.FromCode(code); !ok {
+			errs = append(errs, fmt.Errorf("langs: unsupported language %q", code))
+		}
+	}
+	for _, domain := range slices.Concat(cc.Scope, cc.Block) {
+		if domain == "" || strings.ContainsAny(domain, "/:") {
+			errs = append(errs, fmt.Errorf("scope, block: %q is not a domain name", domain))
+		}
+	}
+	if cc.MaxDepth < 0 {
+		errs = append(errs, errors.New("max_depth: must not be negative"))
+	}
+	if cc.DomainBudget != nil && *cc.DomainBudget < 0 {
+		errs = append(errs, errors.New("domain_budget: must not be negative"))
+	}
+	if cc.PolitenessDelay != nil && *cc.PolitenessDelay < 0 {
+		errs = append(errs, errors.New("politeness_delay: must not be negative"))
+	}
+	for domain, delay := range cc.DomainDelays {
+		if delay < 0 {
+			errs = append(errs, fmt.Errorf("domain_delays: delay for %q must not be negative", domain))
+		}
+	}
+	if cc.MaxPerHost < 0 {
+		errs = append(errs, errors.New("max_per_host: must not be negative"))
+	}
+	for _, tag := range cc.BoilerplateTags {
+		if tag == "" || strings.ContainsAny(tag, "<> ") {
+			errs = append(errs, fmt.Errorf("boilerplate_tags: %q is not an element name", tag))
+		}
+	}
+	if cc.MetadataWeight < 0 {
+		errs = append(errs, errors.New("metadata_weight: must not be negative"))
+	}
+	if cc.MaxLinksPerPage != nil && *cc.MaxLinksPerPage < 0 {
+		errs = append(errs, errors.New("max_links_per_page: must not be negative"))
+	}
+	if cc.MaxBodySize != nil && *cc.MaxBodySize < 0 {
+		errs = append(errs, errors.New("max_body_size: must not be negative"))
+	}
+	if cc.MinDocumentTerms != nil && *cc.MinDocumentTerms < 0 {
+		errs = append(errs, errors.New("min_document_terms: must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Languages returns the configured languages. Codes are assumed valid; see Validate.
+func (cc CrawlConfig) Languages() []language.Language {
+	langs := make([]language.Language, 0, len(cc.Langs))
+	for _, code := range cc.Langs {
+		if lang, ok := language.FromCode(code); ok {
+			langs = append(langs, lang)
+		}
+	}
+	return langs
+}
+
+// Apply returns base with the run's settings applied over it.
+func (cc CrawlConfig) Apply(base CrawlerConfig) CrawlerConfig {
+	cfg := base
+	if len(cc.Scope) > 0 {
+		cfg.URLFilters = append(cfg.URLFilters[:len(cfg.URLFilters):len(cfg.URLFilters)], DomainScopeFilter(cc.Scope...))
+	}
+	if len(cc.Block) > 0 {
+		cfg.URLFilters = append(cfg.URLFilters[:len(cfg.URLFilters):len(cfg.URLFilters)], DomainExcludeFilter(cc.Block...))
+	}
+	if cc.MaxDepth > 0 {
+		cfg.URLFilters = append(cfg.URLFilters[:len(cfg.URLFilters):len(cfg.URLFilters)], MaxDepthFilter(cc.MaxDepth))
+	}
+	if cc.DomainBudget != nil {
+		cfg.DomainBudget = *cc.DomainBudget
+	}
+	if cc.PolitenessDelay != nil {
+		cfg.PolitenessDelay = time.Duration(*cc.PolitenessDelay)
+	}
+	if len(cc.DomainDelays) > 0 {
+		cfg.DomainDelays = make(map[string]time.Duration, len(cc.DomainDelays))
+		for domain, delay := range cc.DomainDelays {
+			cfg.DomainDelays[domain] = time.Duration(delay)
+		}
+	}
+	if cc.MaxPerHost > 0 {
+		cfg.MaxConcurrentPerHost = cc.MaxPerHost
+	}
+	if cc.MaxLinksPerPage != nil {
+		cfg.MaxLinksPerPage = *cc.MaxLinksPerPage
+	}
+	if cc.MaxBodySize != nil {
+		cfg.Fetch.MaxBodySize = *cc.MaxBodySize
+	}
+	if cc.MinDocumentTerms != nil {
+		cfg.MinDocumentTerms = *cc.MinDocumentTerms
+	}
+	if cc.Stemming != nil {
+		cfg.Stemming = *cc.Stemming
+	}
+	if cc.KeepNumbers != nil {
+		cfg.KeepNumbers = *cc.KeepNumbers
+	}
+	if cc.SkipBoilerplate != nil {
+		cfg.Extract.SkipBoilerplate = *cc.SkipBoilerplate
+	}
+	if len(cc.BoilerplateTags) > 0 {
+		cfg.Extract.BoilerplateTags = cc.BoilerplateTags
+	}
+	if len(cc.BoilerplateRoles) > 0 {
+		cfg.Extract.BoilerplateRoles = cc.BoilerplateRoles
+	}
+	if cc.MetaDescription != nil {
+		cfg.Extract.MetaDescription = *cc.MetaDescription
+	}
+	if cc.MetaKeywords != nil {
+		cfg.Extract.MetaKeywords = *cc.MetaKeywords
+	}
+	if cc.ImageAlt != nil {
+		cfg.Extract.ImageAlt = *cc.ImageAlt
+	}
+	if cc.MetadataWeight > 0 {
+		cfg.Extract.MetadataWeight = cc.MetadataWeight
+	}
+	if cc.UserAgent != "" {
+		headers := make(map[string]string, len(cfg.Fetch.Headers)+1)
+		for name, value := range cfg.Fetch.Headers {
+			headers[name] = value
+		}
+		headers["User-Agent"] = cc.UserAgent
+		cfg.Fetch.Headers = headers
+	}
+	return cfg
+}
@@ -4,6 +4,7 @@ package crawler
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"sync"
 
@@ -18,26 +19,54 @@ type CrawlerMessage struct {
 // Crawler handles fetching web content from URLs and passing it to the processor.
 // It manages HTTP requests and coordinates with the processing pipeline.
 type Crawler struct {
-	in     chan CrawlerMessage   // Input channel for crawl requests
-	out    chan ProcessorMessage // Output channel for fetched content
-	wg     *sync.WaitGroup       // WaitGroup for goroutine management
-	s      store.Store           // Database store for status updates
-	ctx    context.Context       // Context for cancellation
-	cancel context.CancelFunc    // Cancel function for stopping the crawler
-	logger *slog.Logger          // Structured logger
+	in      chan CrawlerMessage   // Input channel for crawl requests
+	out     chan ProcessorMessage // Output channel for fetched content
+	wg      *sync.WaitGroup       // WaitGroup for goroutine management
+	workers int                   // Number of concurrent fetch workers
+	pool    sync.WaitGroup        // Tracks running workers
+	outOnce sync.Once             // Closes out exactly once
+	s       store.Store           // Database store for status updates
+	fetcher Fetcher               // Fetches page content
+	limiter *hostLimiter          // Per-host concurrent fetch limiter
+	robots  *RobotsCache          // Cached robots.txt rules; nil crawls everything
+	cfg     CrawlerConfig         // Crawler configuration
+	budget  *domainBudget         // Per-domain crawl budget
+	stats   *crawlStats           // Counters for the crawl summary
+	hooks   *Hooks                // Optional pipeline observation hooks
+	ctx     context.Context       // Context for cancellation
+	cancel  context.CancelFunc    // Cancel function for stopping the crawler
+	logger  *slog.Logger          // Structured logger
 }
 
 // NewCrawler creates a new Crawler instance with the given configuration.
-func NewCrawler(ctx context.Context, cancel context.CancelFunc, s store.Store, in chan CrawlerMessage, wg *sync.WaitGroup, logger *slog.Logger) *Crawler {
+// The limiter and robots cache are passed in so seed discovery can share them.
+func NewCrawler(ctx context.Context, cancel context.CancelFunc, s store.Store, in chan CrawlerMessage, cfg CrawlerConfig, limiter *hostLimiter, robots *RobotsCache, budget *domainBudget, stats *crawlStats, hooks *Hooks, wg *sync.WaitGroup, logger *slog.Logger) *Crawler {
 	out := make(chan ProcessorMessage)
-	return &Crawler{in, out, wg, s, ctx, cancel, logger}
+	fetcher := cfg.fetcher()
+	workers := max(cfg.CrawlWorkers, 1)
+	return &Crawler{in, out, wg, workers, sync.WaitGroup{}, sync.Once{}, s, fetcher, limiter, robots, cfg, budget, stats, hooks, ctx, cancel, logger}
 }
 
-// Run starts the crawler's main loop, processing URLs from the input channel.
-// It fetches web content and sends it to the processor for further handling.
+// Run starts the crawler's workers, which share the input channel, fetch web content
+// concurrently and send it to the processor. It returns, closing the output channel,
+// once every worker has exited, and only then marks the crawler done.
 func (c *Crawler) Run() {
 	defer c.wg.Done()
 
+	c.pool.Add(c.workers)
+	for range c.workers {
+		go func() {
+			defer c.pool.Done()
+			c.work()
+		}()
+	}
+	c.pool.Wait()
+	c.closeOut()
+}
+
+// work is a single worker's loop, processing URLs from the input channel until it
+// is closed or the crawler is canceled.
+func (c *Crawler) work() {
 	for {
 		select {
 		case <-c.ctx.Done():
@@ -46,33 +75,167 @@ func (c *Crawler) Run() {
 		case cm, ok := <-c.in:
 			if !ok {
 				c.logger.Info("Crawler \"in\" channel closed, returning")
-				c.cancel()
 				return
 			}
 
 			c.logger.Debug("Crawler handling url", "url", cm.fi.Url)
-			ioReader, ioErr := getReaderFromUrl(cm.fi.Url)
+			if !c.allowedByRobots(cm.fi) || !c.withinBudget(cm.fi) {
+				continue
+			}
+
+			resp, ioErr := c.fetchWithRetry(cm.fi.Url)
 			if ioErr != nil {
+				c.refundBudget(cm.fi)
 				c.handleIoError(cm, ioErr)
 				continue
 			}
 
-			c.out <- ProcessorMessage{cm.fi, ioReader}
+			c.hooks.fetched(cm.fi.Url)
+			c.recordFetch(cm.fi.Url)
+			if !c.allowedRedirect(cm.fi, resp.Url) {
+				if closer, ok := resp.Body.(io.Closer); ok {
+					closer.Close()
+				}
+				continue
+			}
+			select {
+			case <-c.ctx.Done():
+				c.logger.Info("Crawler work canceled, dropping fetched page", "url", cm.fi.Url)
+				if closer, ok := resp.Body.(io.Closer); ok {
+					closer.Close()
+				}
+				return
+			case c.out <- ProcessorMessage{cm.fi, resp.Url, c.stats.countReader(resp.Body), resp.Header}:
+			}
 		}
 	}
 }
 
-// handleIoError handles I/O errors that occur during URL fetching.
+// allowedByRobots checks an item against its host's robots.txt, marking it skipped and
+// returning false if it is disallowed. The host's Crawl-delay is passed to the limiter.
+func (c *Crawler) allowedByRobots(fi store.FrontierItem) bool {
+	allowed, err := allowedByRobots(c.ctx, c.robots, c.limiter, fi.Url)
+	if err != nil {
+		// Only cancellation or an unparseable URL get here; the fetch would fail too
+		c.logger.Debug("Error checking robots.txt", "url", fi.Url, "error", err)
+		return true
+	}
+	if allowed {
+		return true
+	}
+	c.logger.Debug("Disallowed by robots.txt, skipping url", "url", fi.Url)
+	c.stats.recordSkip(skipRobots)
+	c.updateItemStatus(fi.UrlNorm, store.StatusSkipped)
+	return false
+}
+
+// withinBudget charges a crawl to the item's domain, marking the item skipped
+// and returning false if the domain has exhausted its budget.
+func (c *Crawler) withinBudget(fi store.FrontierItem) bool {
+	domain, err := store.GetHostame(fi.Url)
+	if err != nil || c.budget.Take(domain) {
+		return true
+	}
+	c.logger.Debug("Domain crawl budget exhausted, skipping url", "url", fi.Url, "domain", domain)
+	c.stats.recordSkip(skipBudget)
+	c.updateItemStatus(fi.UrlNorm, store.StatusSkipped)
+	return false
+}
+
+// refundBudget returns the crawl charged to the item's domain by withinBudget after
+// its fetch failed.
+func (c *Crawler) refundBudget(fi store.FrontierItem) {
+	if domain, err := store.GetHostame(fi.Url); err == nil {
+		c.budget.Refund(domain)
+	}
+}
+
+// allowedRedirect checks the URL a frontier item redirected to against the URL filters
+// and robots.txt, as if it had been linked to, marking the item skipped and returning
+// false if the target wouldn't have been crawled. The Index stage records the page's
+// outcome on the target once it is known.
+func (c *Crawler) allowedRedirect(fi store.FrontierItem, finalUrl string) bool {
+	target, ok := redirectTarget(fi, finalUrl, c.cfg.PriorityWeights)
+	if !ok {
+		return true
+	}
+	c.logger.Debug("Followed redirect", "url", fi.Url, "final", finalUrl)
+
+	if !c.cfg.URLFilters.Keep(target.UrlNorm, target.Depth) {
+		c.logger.Debug("Redirect target rejected by URL filters, skipping url", "url", fi.Url, "final", finalUrl)
+		c.stats.recordSkip(skipRedirectFiltered)
+		c.updateItemStatus(fi.UrlNorm, store.StatusSkipped)
+		return false
+	}
+	allowed, err := allowedByRobots(c.ctx, c.robots, c.limiter, target.Url)
+	if err != nil || allowed {
+		return true
+	}
+	c.logger.Debug("Redirect target disallowed by robots.txt, skipping url", "url", fi.Url, "final", finalUrl)
+	c.stats.recordSkip(skipRobots)
+	c.updateItemStatus(fi.UrlNorm, store.StatusSkipped)
+	return false
+}
+
+// redirectTarget returns the frontier item for the URL fi redirected to, which takes
+// fi's place in the crawl tree, or false if fi wasn't redirected to another page.
+func redirectTarget(fi store.FrontierItem, finalUrl string, w store.PriorityWeights) (store.FrontierItem, bool) {
+	if finalUrl == "" || finalUrl == fi.Url {
+		return store.FrontierItem{}, false
+	}
+	target, err := store.NewFrontierItemFromParent(fi, finalUrl, w)
+	if err != nil || target.UrlNorm == fi.UrlNorm {
+		return store.FrontierItem{}, false
+	}
+	target.ParentUrl, target.Depth = fi.ParentUrl, fi.Depth
+	target.Priority = store.ScoreURL(target.Url, target.Depth, w)
+	return target, true
+}
+
+// recordFetch counts a successful fetch of url in the crawl summary.
+func (c *Crawler) recordFetch(url string) {
+	host, err := store.GetHostame(url)
+	if err != nil {
+		host = url
+	}
+	c.stats.recordFetch(host)
+}
+
+// fetch retrieves a URL while holding one of its host's concurrent fetch slots.
+func (c *Crawler) fetch(url string) (Response, error) {
+	host, err := store.GetHostame(url)
+	if err != nil {
+		return Response{}, err
+	}
+	return c.limiter.Fetch(c.ctx, c.fetcher, host, url)
+}
+
+// handleIoError handles I/O errors that occur during URL fetching. Items that failed
+// for a transient reason are marked retryable, so a later run tries them again.
 func (c *Crawler) handleIoError(cm CrawlerMessage, err error) {
 	c.logger.Error("Error getting reader for URL", "url", cm.fi.Url, "error", err)
-	c.updateItemStatus(cm.fi.UrlNorm, store.StatusFailed)
+	c.hooks.failed(cm.fi.Url, err)
+	reason := failureReason(err, reasonFetch)
+	c.stats.recordFailure(reason)
+	if fetchRetryable(err) {
+		c.markItemRetryable(cm.fi.UrlNorm, reason)
+		return
+	}
+	c.markItemFailed(cm.fi.UrlNorm, reason)
 }
 
-// Close gracefully shuts down the crawler by closing channels and signaling completion.
+// Close gracefully shuts down the crawler. It waits for the workers to exit, which they
+// do once the input channel is closed or the context canceled, then closes the output
+// channel. Run marks the crawler done, so Close doesn't touch the WaitGroup.
 func (c *Crawler) Close() {
 	c.logger.Info("Closing crawler")
-	close(c.out)
-	c.wg.Done()
+	c.pool.Wait()
+	c.closeOut()
+}
+
+// closeOut closes the output channel, if it hasn't been already.
+func (c *Crawler) closeOut() {
+	c.outOnce.Do(func() { close(c.out) })
 }
 
 // updates the status of a frontier item in the database.
@@ -90,3 +253,35 @@ func (c *Crawler) updateItemStatus(urlNorm string, status store.FrontierStatusEn
 	}
 	return nil
 }
+
+// markItemFailed marks a frontier item failed in the database, recording why.
+func (c *Crawler) markItemFailed(urlNorm string, reason string) error {
+	conn, err := c.s.Pool.Acquire(c.ctx)
+	if err != nil {
+		c.logger.Error("Error acquiring connection to update status", "url", urlNorm, "error", err)
+		return err
+	}
+	defer conn.Release()
+	err = store.MarkFIFailed(c.ctx, conn, urlNorm, reason)
+	if err != nil {
+		c.logger.Error("Error updating status to failed", "url", urlNorm, "reason", reason, "error", err)
+		return err
+	}
+	return nil
+}
+
+// markItemRetryable marks a frontier item as failed transiently, recording why.
+func (c *Crawler) markItemRetryable(urlNorm string, reason string) error {
+	conn, err := c.s.Pool.Acquire(c.ctx)
+	if err != nil {
+		c.logger.Error("Error acquiring connection to update status", "url", urlNorm, "error", err)
+		return err
+	}
+	defer conn.Release()
+	err = store.MarkFIRetryable(c.ctx, conn, urlNorm, reason)
+	if err != nil {
+		c.logger.Error("Error updating status to retryable", "url", urlNorm, "reason", reason, "error", err)
+		return err
+	}
+	return nil
+}
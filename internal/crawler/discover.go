@@ -0,0 +1,87 @@
+// Package crawler contains seed discovery for sparsely linked sites.
+package crawler
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/jdpolicano/go-search/internal/store"
+)
+
+// DefaultDiscoveryPaths are common paths worth probing on sites without a sitemap.
+var DefaultDiscoveryPaths = []string{"/", "/about", "/blog", "/index.html"}
+
+// discoverSeeds probes each path against the root of every seed's host and returns
+// the URLs that were fetched successfully, so they can be crawled as extra seeds.
+// Each host is probed once regardless of how many seeds share it. Probes go through
+// the crawl's limiter and robots cache like any other fetch, so paths robots.txt
+// disallows are skipped and hosts see the same politeness; different hosts are
+// probed concurrently.
+func discoverSeeds(ctx context.Context, fetcher Fetcher, limiter *hostLimiter, robots *RobotsCache, seeds []string, paths []string, logger *slog.Logger) []string {
+	probed := make(map[string]bool)
+	var hosts, roots []string
+	for _, seed := range seeds {
+		host, err := store.GetHostame(seed)
+		if err != nil || probed[host] {
+			continue
+		}
+		probed[host] = true
+		hosts = append(hosts, host)
+		roots = append(roots, seed)
+	}
+
+	// Keep each host's finds in its own slot so the result is in seed order
+	found := make([][]string, len(hosts))
+	var wg sync.WaitGroup
+	for i := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			found[i] = probeHost(ctx, fetcher, limiter, robots, hosts[i], roots[i], paths, logger)
+		}()
+	}
+	wg.Wait()
+
+	var all []string
+	for _, urls := range found {
+		all = append(all, urls...)
+	}
+	return all
+}
+
+// probeHost probes each path against the root of seed's host, returning the URLs
+// that were allowed by robots.txt and fetched successfully.
+func probeHost(ctx context.Context, fetcher Fetcher, limiter *hostLimiter, robots *RobotsCache, host, seed string, paths []string, logger *slog.Logger) []string {
+	var found []string
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			return found
+		}
+
+		url, err := store.MakeUrl(seed, path)
+		if err != nil {
+			continue
+		}
+
+		allowed, err := allowedByRobots(ctx, robots, limiter, url)
+		if err != nil || !allowed {
+			logger.Debug("Discovery probe disallowed by robots.txt", "url", url, "error", err)
+			continue
+		}
+
+		resp, err := limiter.Fetch(ctx, fetcher, host, url)
+		if err != nil {
+			logger.Debug("Discovery probe failed", "url", url, "error", err)
+			continue
+		}
+		if closer, ok := resp.Body.(io.Closer); ok {
+			closer.Close()
+		}
+
+		logger.Info("Discovery probe succeeded", "host", host, "path", path, "url", url)
+		found = append(found, url)
+	}
+	return found
+}
@@ -0,0 +1,160 @@
+// Package crawler contains transparent decompression and charset decoding of fetched
+// response bodies.
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// acceptEncoding is sent with every request. Setting it ourselves turns off net/http's
+// transparent gzip handling, so decodeBody handles deflate as well.
+const acceptEncoding = "gzip, deflate"
+
+// decodedBody reads a decompressed body, closing both the decompressor and the
+// underlying response body.
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close closes the decompressor, then the response body.
+func (b decodedBody) Close() error {
+	var first error
+	for _, c := range b.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// decodeBody replaces a response's body with one yielding the decompressed bytes,
+// according to its Content-Encoding. Servers that claim an encoding but send plain
+// bytes anyway are detected by the stream's magic bytes and passed through as is.
+// Once decoded, the Content-Encoding and Content-Length headers are removed as they
+// no longer describe the body.
+func decodeBody(resp *http.Response) error {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		return nil
+	}
+
+	buffered := bufio.NewReader(resp.Body)
+	magic, _ := buffered.Peek(2)
+
+	var decoder io.ReadCloser
+	switch encoding {
+	case "gzip", "x-gzip":
+		if len(magic) < 2 || magic[0] != 0x1f || magic[1] != 0x8b {
+			break
+		}
+		zr, err := gzip.NewReader(buffered)
+		if err != nil {
+			return err
+		}
+		decoder = zr
+	case "deflate":
+		// "deflate" is meant to be zlib wrapped, but some servers send a raw stream
+		if len(magic) == 2 && magic[0]&0x0f == 8 && (uint16(magic[0])<<8|uint16(magic[1]))%31 == 0 {
+			zr, err := zlib.NewReader(buffered)
+			if err != nil {
+				return err
+			}
+			decoder = zr
+		} else {
+			decoder = flate.NewReader(buffered)
+		}
+	default:
+		// Unknown encodings are left for the parser to reject
+		return nil
+	}
+
+	if decoder == nil {
+		resp.Body = decodedBody{buffered, []io.Closer{resp.Body}}
+		return nil
+	}
+	resp.Body = decodedBody{decoder, []io.Closer{decoder, resp.Body}}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// charsetPeekSize is how much of a body is inspected for a charset declaration,
+// matching the HTML spec's prescan that charset.DetermineEncoding implements.
+const charsetPeekSize = 1024
+
+// decodeCharset returns a reader yielding body transcoded to UTF-8, which is all the
+// HTML parser understands. The charset comes from a byte order mark, the charset
+// parameter of contentType or a <meta charset> near the start of the body, in that
+// order. A body that declares none is assumed to already be UTF-8, rather than the
+// windows-1252 a browser would guess, and so is one declaring an unknown charset.
+func decodeCharset(body io.Reader, contentType string) io.Reader {
+	buffered := bufio.NewReaderSize(body, charsetPeekSize)
+	// A short or failing body yields what it has; read errors surface on the next Read
+	peek, _ := buffered.Peek(charsetPeekSize)
+
+	// A BOM or Content-Type charset is certain; otherwise only a <meta> declaration
+	// counts, not DetermineEncoding's guess from the bytes
+	enc, name, certain := charset.DetermineEncoding(peek, contentType)
+	if !certain {
+		enc, name = charset.Lookup(metaCharset(peek))
+		// A page can't really be UTF-16 if its <meta> was readable as ASCII
+		if enc == nil || strings.HasPrefix(name, "utf-16") {
+			return buffered
+		}
+	}
+	if name == "utf-8" {
+		return buffered
+	}
+	return enc.NewDecoder().Reader(buffered)
+}
+
+// metaCharset returns the charset declared by the first <meta charset> or
+// <meta http-equiv="Content-Type"> tag in the start of a document, or "" if there
+// is none. Tags are tokenized, so a charset attribute on another element, such as
+// <script charset>, or the word in text or a script's source isn't mistaken for one.
+func metaCharset(peek []byte) string {
+	z := html.NewTokenizer(bytes.NewReader(peek))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) != "meta" {
+				continue
+			}
+			var httpEquiv, content string
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				switch string(key) {
+				case "charset":
+					return strings.TrimSpace(string(val))
+				case "http-equiv":
+					httpEquiv = string(val)
+				case "content":
+					content = string(val)
+				}
+			}
+			if strings.EqualFold(httpEquiv, "content-type") {
+				if _, params, err := mime.ParseMediaType(content); err == nil && params["charset"] != "" {
+					return params["charset"]
+				}
+			}
+		}
+	}
+}
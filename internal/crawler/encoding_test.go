@@ -0,0 +1,35 @@
+package crawler
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecodeCharset(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		want        string
+	}{
+		{"undeclared utf-8", "<p>café</p>", "text/html", "<p>café</p>"},
+		{"content type", "<p>caf\xe9</p>", "text/html; charset=iso-8859-1", "<p>café</p>"},
+		{"meta charset", `<meta charset="windows-1252"><p>caf` + "\xe9</p>", "text/html", `<meta charset="windows-1252"><p>café</p>`},
+		{"meta http-equiv", `<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1"><p>caf` + "\xe9</p>", "", `<meta http-equiv="Content-Type" content="text/html; charset=iso-8859-1"><p>café</p>`},
+		{"script charset", `<script charset="iso-8859-1" src="a.js"></script><p>café</p>`, "text/html", `<script charset="iso-8859-1" src="a.js"></script><p>café</p>`},
+		{"charset in text", "<p>set charset=latin1 in café</p>", "", "<p>set charset=latin1 in café</p>"},
+		{"unknown charset", `<meta charset="x-klingon"><p>café</p>`, "", `<meta charset="x-klingon"><p>café</p>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := io.ReadAll(decodeCharset(strings.NewReader(tt.body), tt.contentType))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// Package crawler contains classification of crawl failures.
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/jdpolicano/go-search/internal/extract"
+)
+
+// Failure reasons recorded on failed frontier items. Stage fallbacks cover errors
+// that don't match a more specific reason.
+const (
+	reasonUnsupportedLanguage = "unsupported_language"
+	reasonEmptyDocument       = "empty_document"
+	reasonNoContent           = "no_content"
+	reasonDisqualified        = "disqualified"
+	reasonTooLarge            = "too_large"
+	reasonRedirect            = "redirect"
+	reasonCanceled            = "canceled"
+	reasonNetwork             = "network"
+	reasonFetch               = "fetch"
+	reasonParse               = "parse"
+	reasonIndex               = "index"
+)
+
+// failureReason classifies an error for the failure_reason column, so legitimately
+// skipped pages (e.g. unsupported language) can be told apart from real bugs.
+// fallback names the pipeline stage and is used for unrecognized errors.
+func failureReason(err error, fallback string) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, extract.ErrorNotSupportedLanguage):
+		return reasonUnsupportedLanguage
+	case errors.Is(err, extract.ErrorEmptyDocument):
+		return reasonEmptyDocument
+	case errors.Is(err, extract.ErrorNoContent):
+		return reasonNoContent
+	case errors.Is(err, ErrorDisqualified):
+		return reasonDisqualified
+	case errors.Is(err, ErrorBodyTooLarge):
+		return reasonTooLarge
+	case errors.Is(err, ErrorTooManyRedirects), errors.Is(err, ErrorRedirectLoop):
+		return reasonRedirect
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return reasonCanceled
+	case errors.As(err, &netErr):
+		return reasonNetwork
+	default:
+		return fallback
+	}
+}
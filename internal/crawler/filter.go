@@ -0,0 +1,132 @@
+// Package crawler contains the URL admission filters applied to discovered links.
+package crawler
+
+import (
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// URLFilter decides whether a discovered link is admitted to the frontier. It is
+// given the normalized URL and the depth the link would be crawled at.
+type URLFilter interface {
+	Keep(normalizedURL string, depth int) bool
+}
+
+// URLFilterFunc adapts a function to a URLFilter.
+type URLFilterFunc func(normalizedURL string, depth int) bool
+
+// Keep calls f.
+func (f URLFilterFunc) Keep(normalizedURL string, depth int) bool {
+	return f(normalizedURL, depth)
+}
+
+// FilterChain admits a link only if every filter in it does. An empty chain admits everything.
+type FilterChain []URLFilter
+
+// Keep reports whether every filter keeps the link, stopping at the first that doesn't.
+func (c FilterChain) Keep(normalizedURL string, depth int) bool {
+	for _, filter := range c {
+		if !filter.Keep(normalizedURL, depth) {
+			return false
+		}
+	}
+	return true
+}
+
+// SchemeFilter keeps links whose scheme is one of schemes, e.g. "http" and "https",
+// dropping mailto:, javascript: and the like.
+func SchemeFilter(schemes ...string) URLFilter {
+	return URLFilterFunc(func(normalizedURL string, depth int) bool {
+		u, err := url.Parse(normalizedURL)
+		return err == nil && slices.Contains(schemes, u.Scheme)
+	})
+}
+
+// DomainScopeFilter keeps links to one of domains or any of their subdomains. Domains
+// are matched case-insensitively, and a leading "*." or "." is ignored, so
+// "*.wikipedia.org" is the same as "wikipedia.org".
+func DomainScopeFilter(domains ...string) URLFilter {
+	domains = normalizeDomains(domains)
+	return URLFilterFunc(func(normalizedURL string, depth int) bool {
+		u, err := url.Parse(normalizedURL)
+		if err != nil {
+			return false
+		}
+		host := u.Hostname()
+		for _, domain := range domains {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// normalizeDomains returns domains lowercased, without wildcard prefixes or trailing dots.
+func normalizeDomains(domains []string) []string {
+	normalized := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		domain = strings.TrimPrefix(strings.TrimPrefix(domain, "*"), ".")
+		domain = strings.TrimSuffix(domain, ".")
+		if domain != "" {
+			normalized = append(normalized, domain)
+		}
+	}
+	return normalized
+}
+
+// DomainExcludeFilter drops links to any of domains or their subdomains, such as
+// mirror sites recorded with store.ExcludeDomain.
+func DomainExcludeFilter(domains ...string) URLFilter {
+	scope := DomainScopeFilter(domains...)
+	return URLFilterFunc(func(normalizedURL string, depth int) bool {
+		return !scope.Keep(normalizedURL, depth)
+	})
+}
+
+// RegexExcludeFilter drops links matching any of patterns.
+func RegexExcludeFilter(patterns ...*regexp.Regexp) URLFilter {
+	return URLFilterFunc(func(normalizedURL string, depth int) bool {
+		for _, pattern := range patterns {
+			if pattern.MatchString(normalizedURL) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// MaxDepthFilter drops links deeper than maxDepth links from a seed.
+func MaxDepthFilter(maxDepth int) URLFilter {
+	return URLFilterFunc(func(normalizedURL string, depth int) bool {
+		return depth <= maxDepth
+	})
+}
+
+// TrapFilter drops links that look like crawler traps: paths with more than
+// maxSegments segments, or in which any one segment repeats more than maxRepeats
+// times, as generated by relative links on pages served at ever deeper paths
+// (e.g. /a/b/a/b/a/b) or endless calendars.
+func TrapFilter(maxSegments, maxRepeats int) URLFilter {
+	return URLFilterFunc(func(normalizedURL string, depth int) bool {
+		u, err := url.Parse(normalizedURL)
+		if err != nil {
+			return false
+		}
+		segments := strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+		if len(segments) > maxSegments {
+			return false
+		}
+		counts := make(map[string]int, len(segments))
+		for _, segment := range segments {
+			counts[segment]++
+			if counts[segment] > maxRepeats {
+				return false
+			}
+		}
+		return true
+	})
+}
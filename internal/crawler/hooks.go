@@ -0,0 +1,42 @@
+// Package crawler contains observation hooks for the crawling pipeline.
+package crawler
+
+import "github.com/jdpolicano/go-search/internal/store"
+
+// Hooks holds optional callbacks invoked at each stage of the crawling pipeline.
+// Any callback may be left nil, and a nil *Hooks disables all of them.
+// Callbacks run synchronously on the pipeline goroutines, so they should return quickly.
+type Hooks struct {
+	OnFetch   func(url string)              // Called after a page has been fetched
+	OnIndex   func(entry store.IndexEntry)  // Called after a document has been committed to the index
+	OnError   func(url string, err error)   // Called when any stage fails for a URL
+	OnEnqueue func(item store.FrontierItem) // Called for each discovered link submitted to the frontier, including already known ones
+}
+
+// fetched invokes OnFetch if it is set.
+func (h *Hooks) fetched(url string) {
+	if h != nil && h.OnFetch != nil {
+		h.OnFetch(url)
+	}
+}
+
+// indexed invokes OnIndex if it is set.
+func (h *Hooks) indexed(entry store.IndexEntry) {
+	if h != nil && h.OnIndex != nil {
+		h.OnIndex(entry)
+	}
+}
+
+// failed invokes OnError if it is set.
+func (h *Hooks) failed(url string, err error) {
+	if h != nil && h.OnError != nil {
+		h.OnError(url, err)
+	}
+}
+
+// enqueued invokes OnEnqueue if it is set.
+func (h *Hooks) enqueued(item store.FrontierItem) {
+	if h != nil && h.OnEnqueue != nil {
+		h.OnEnqueue(item)
+	}
+}
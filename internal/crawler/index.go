@@ -3,17 +3,26 @@ package crawler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
 	"sync"
+	"time"
 
+	"github.com/jdpolicano/go-search/internal/extract"
 	"github.com/jdpolicano/go-search/internal/extract/language"
 	"github.com/jdpolicano/go-search/internal/queue"
 	"github.com/jdpolicano/go-search/internal/store"
 )
 
-// IndexMessage represents a message containing an index entry to be stored.
+// IndexMessage represents a message containing processed page content to be indexed.
+// It carries everything extracted from the page so the index can build its entry
+// without re-parsing.
 type IndexMessage struct {
-	entry store.IndexEntry
+	fi        store.FrontierItem // Frontier item the content was fetched for
+	url       string             // URL the content was fetched from after redirects; empty means fi.Url
+	extracted extract.Extracted  // Links, term frequencies, hash, length, text, title and snippet of the page
 }
 
 // Index coordinates the entire crawling and indexing workflow.
@@ -25,126 +34,396 @@ type Index struct {
 	in        chan IndexMessage  // Input channel for index entries
 	wg        *sync.WaitGroup    // WaitGroup for goroutine management
 	s         store.Store        // Database store
+	hooks     *Hooks             // Optional pipeline observation hooks
+	budget    *domainBudget      // Per-domain crawl budget
+	stats     *crawlStats        // Counters for the crawl summary
+	terms     *store.TermCache   // Term ids resolved by earlier documents
+	pruneGen  int64              // Prune generation the term cache was filled under
+	cfg       CrawlerConfig      // Crawler configuration
 	ctx       context.Context    // Context for cancellation
 	cancel    context.CancelFunc // Cancel function for stopping the workflow
+	done      chan struct{}      // Closed when Run returns
 	logger    *slog.Logger       // Structured logger
 }
 
 // NewIndex creates a new Index instance with the given configuration.
-// It sets up the entire crawling pipeline and initializes seed URLs.
-func NewIndex(ctx context.Context, cancel context.CancelFunc, s store.Store, seeds []string, langs []language.Language, wg *sync.WaitGroup, logger *slog.Logger) (*Index, error) {
-	// Create SQL-based queue with capacity of 500
-	sqlQueue, err := queue.NewSqlQueue(ctx, s, 500, seeds)
-	if err != nil {
-		return nil, err
+// It sets up the entire crawling pipeline and initializes seed URLs. The pipeline's
+// goroutines are added to wg here, before Run starts them, so a caller may start Run
+// in a goroutine and immediately wait on wg; Run must then be called.
+func NewIndex(ctx context.Context, cancel context.CancelFunc, s store.Store, seeds []string, langs []language.Language, cfg CrawlerConfig, hooks *Hooks, wg *sync.WaitGroup, logger *slog.Logger) (*Index, error) {
+	// The crawl's politeness state, shared by discovery and the crawler
+	limiter := newHostLimiter(cfg.MaxConcurrentPerHost, cfg.PolitenessDelay, cfg.DomainDelays, cfg.PolitenessJitter, cfg.JitterSeed)
+	robots := cfg.robots()
+
+	// Optionally probe seed hosts for common pages to bootstrap sparsely linked sites
+	if len(cfg.DiscoveryPaths) > 0 {
+		discovered := discoverSeeds(ctx, cfg.fetcher(), limiter, robots, seeds, cfg.DiscoveryPaths, logger)
+		logger.Info("Discovery finished", "probed", len(cfg.DiscoveryPaths), "found", len(discovered))
+		seeds = append(seeds[:len(seeds):len(seeds)], discovered...)
 	}
 
-	// Add seed URLs to the queue
+	// Drop seeds that aren't valid URLs rather than failing the whole crawl
+	valid := make([]string, 0, len(seeds))
 	for _, seed := range seeds {
-		fi, err := store.NewFrontierItemFromSeed(seed)
-		if err == nil {
-			sqlQueue.Enqueue(fi)
-		} else {
+		if _, err := store.NewFrontierItemFromSeed(seed, cfg.PriorityWeights); err != nil {
 			logger.Error("Error creating frontier item from seed", "seed", seed, "error", err)
+			continue
 		}
+		valid = append(valid, seed)
+	}
+
+	// Keep away from domains excluded in earlier runs, such as known mirrors
+	excluded, err := store.GetExcludedDomains(ctx, s.Pool)
+	if err != nil {
+		return nil, err
+	}
+	if len(excluded) > 0 {
+		logger.Info("Excluding domains", "domains", excluded)
+		cfg.URLFilters = append(cfg.URLFilters[:len(cfg.URLFilters):len(cfg.URLFilters)], DomainExcludeFilter(excluded...))
+	}
+
+	// Tokenize with the settings the index was built with
+	if err := store.CheckIndexStemming(ctx, s.Pool, cfg.Stemming); err != nil {
+		return nil, err
+	}
+	if err := store.CheckIndexKeepNumbers(ctx, s.Pool, cfg.KeepNumbers); err != nil {
+		return nil, err
+	}
+	if err := store.CheckIndexStopWords(ctx, s.Pool, extract.StopWordsHash()); err != nil {
+		return nil, err
+	}
+	extract.SetStemming(cfg.Stemming)
+	extract.SetKeepNumbers(cfg.KeepNumbers)
+
+	// Give pages that failed transiently in earlier runs another chance, up to a limit
+	requeued, exhausted, err := store.RequeueRetryable(ctx, s.Pool, cfg.MaxRetryRuns)
+	if err != nil {
+		return nil, err
+	}
+	if requeued > 0 || exhausted > 0 {
+		logger.Info("Requeued retryable frontier items", "count", requeued, "exhausted", exhausted)
 	}
 
+	// Return items claimed by an earlier run that stopped before crawling them
+	reclaimed, err := store.RequeueInProgress(ctx, s.Pool)
+	if err != nil {
+		return nil, err
+	}
+	if reclaimed > 0 {
+		logger.Info("Requeued in-progress frontier items", "count", reclaimed)
+	}
+
+	// Create SQL-based queue with a buffer of 500, inserting the seeds
+	sqlQueue, err := queue.NewSqlQueue(ctx, s, 500, valid, cfg.PriorityWeights, cfg.MaxFrontierSize, cfg.FrontierEviction)
+	if err != nil {
+		return nil, err
+	}
+
+	// Seed the per-domain budget with the pages indexed by earlier runs
+	indexed, err := store.GetDocCountsByDomain(ctx, s.Pool)
+	if err != nil {
+		return nil, err
+	}
+	budget := newDomainBudget(cfg.DomainBudget, indexed)
+	stats := newCrawlStats()
+
 	// Set up the crawling pipeline
-	queue := NewCrawlQueue(ctx, cancel, sqlQueue, wg, logger)
-	crawler := NewCrawler(ctx, cancel, s, queue.out, wg, logger)
-	processor := NewProcessor(ctx, cancel, s, crawler.out, queue.in, langs, wg, logger)
+	queue := NewCrawlQueue(ctx, cancel, sqlQueue, hooks, wg, logger)
+	crawler := NewCrawler(ctx, cancel, s, queue.out, cfg, limiter, robots, budget, stats, hooks, wg, logger)
+	processor := NewProcessor(ctx, cancel, s, crawler.out, queue.in, langs, cfg, stats, hooks, wg, logger)
 	in := processor.index
-	return &Index{queue, crawler, processor, in, wg, s, ctx, cancel, logger}, nil
+	terms := store.NewTermCache(cfg.TermCacheSize)
+	wg.Add(pipelineStages)
+	return &Index{queue, crawler, processor, in, wg, s, hooks, budget, stats, terms, 0, cfg, ctx, cancel, make(chan struct{}), logger}, nil
+}
+
+// NewIndexFromConfig validates a CrawlConfig and creates an Index for it, applying the
+// run's settings over base.
+func NewIndexFromConfig(ctx context.Context, cancel context.CancelFunc, s store.Store, cc CrawlConfig, base CrawlerConfig, hooks *Hooks, wg *sync.WaitGroup, logger *slog.Logger) (*Index, error) {
+	if err := cc.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid crawl config: %w", err)
+	}
+	return NewIndex(ctx, cancel, s, cc.Seeds, cc.Languages(), cc.Apply(base), hooks, wg, logger)
 }
 
-// Run starts the indexing workflow by initializing all components and processing index entries.
-func (idx *Index) Run() {
+// pipelineStages is the number of goroutines Run adds to the WaitGroup: the queue,
+// crawler and processor, plus the indexing loop.
+const pipelineStages = 4
+
+// Run starts the indexing workflow by initializing all components and processing index
+// entries. When ctx is done the crawl shuts down gracefully as if Close were called,
+// letting pages in flight finish; canceling the context given to NewIndex instead
+// abandons them. Run returns once the index input closes or the workflow is canceled.
+func (idx *Index) Run(ctx context.Context) {
+	defer close(idx.done)
+	stop := context.AfterFunc(ctx, func() {
+		idx.logger.Info("Crawl run stopped, shutting down gracefully")
+		idx.queue.Close()
+	})
+	defer stop()
+
 	idx.startWorkflow()
 	idx.firstPassage()
-	idx.logger.Info("Index run finished")
+	stats := idx.terms.Stats()
+	idx.logger.Info("Index run finished", "termCacheHits", stats.Hits, "termCacheMisses", stats.Misses)
 }
 
 // firstPassage processes index entries from the input channel and stores them in the database.
-// It handles transactions and updates frontier item status upon completion.
+// Entries are committed in batches of cfg.IndexBatchSize, or whatever has accumulated every
+// cfg.IndexFlushInterval. When the input closes the partial batch is flushed; when the
+// workflow is canceled it is abandoned, leaving its frontier items to be crawled again.
 func (idx *Index) firstPassage() {
 	defer idx.wg.Done()
+
+	batchSize := max(idx.cfg.IndexBatchSize, 1)
+	batch := make([]indexItem, 0, batchSize)
+
+	var tick <-chan time.Time
+	if batchSize > 1 && idx.cfg.IndexFlushInterval > 0 {
+		ticker := time.NewTicker(idx.cfg.IndexFlushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
 	for {
 		select {
 		case <-idx.ctx.Done():
+			if len(batch) > 0 {
+				idx.logger.Warn("Abandoning partial index batch", "documents", len(batch))
+			}
 			idx.logger.Info("Index work canceled, returning")
 			return
+		case <-tick:
+			batch = idx.flush(batch)
 		case im, ok := <-idx.in:
 			if !ok {
+				idx.flush(batch)
 				idx.logger.Info("Index \"in\" channel closed, returning")
 				idx.cancel() // cancel the whole workflow if it hasn't already.
 				return
 			}
 
-			// Begin transaction for atomic database operations
-			tx, err := idx.s.Pool.Begin(idx.ctx)
+			entry, err := idx.newIndexEntry(im)
 			if err != nil {
 				idx.handleError(im, err)
 				continue
 			}
 
-			// Index the document
-			err = store.IndexDocumentInit(idx.ctx, tx, im.entry)
-			if err != nil {
-				tx.Rollback(idx.ctx)
-				idx.handleError(im, err)
-				continue
+			batch = append(batch, indexItem{im, entry})
+			if len(batch) >= batchSize {
+				batch = idx.flush(batch)
 			}
+		}
+	}
+}
 
-			// Update frontier item status to completed
-			err = store.UpdateFIStatus(idx.ctx, tx, im.entry.UrlNorm, store.StatusCompleted)
-			if err != nil {
-				tx.Rollback(idx.ctx)
-				idx.handleError(im, err)
-				continue
-			}
+// indexItem pairs an index entry with the message it was built from, for error handling.
+type indexItem struct {
+	im    IndexMessage
+	entry store.IndexEntry
+}
+
+// flush commits a batch of entries, returning the emptied batch for reuse. If the batch
+// can't be committed as a whole, each entry is retried on its own so a single bad
+// document only fails itself.
+func (idx *Index) flush(batch []indexItem) []indexItem {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	// Retry the whole transaction on transient lock contention
+	err := store.WithRetry(idx.ctx, store.DefaultRetryPolicy(), func() error {
+		return idx.indexEntries(batch)
+	})
+	if err == nil {
+		idx.stats.indexed.Add(int64(len(batch)))
+		for _, item := range batch {
+			idx.logger.Info("Indexed document successfully", "url", item.entry.Url)
+			idx.hooks.indexed(item.entry)
+		}
+		return batch[:0]
+	}
+
+	if len(batch) == 1 || idx.ctx.Err() != nil {
+		for _, item := range batch {
+			idx.handleError(item.im, err)
+		}
+		return batch[:0]
+	}
+
+	idx.logger.Warn("Index batch failed, indexing documents individually", "documents", len(batch), "error", err)
+	for _, item := range batch {
+		idx.flush([]indexItem{item})
+	}
+	return batch[:0]
+}
+
+// newIndexEntry builds the index entry for a processed page. It is indexed under the
+// URL it was finally fetched from, so links redirecting to one page share a document.
+func (idx *Index) newIndexEntry(im IndexMessage) (store.IndexEntry, error) {
+	url := im.fi.Url
+	if im.url != "" {
+		url = im.url
+	}
+	entry, err := store.NewIndexEntry(url, im.extracted.Hash, im.extracted.Len, im.extracted.TermFreqs)
+	if err != nil {
+		return store.IndexEntry{}, err
+	}
+	if err := entry.SetTitle(im.extracted.Title); err != nil {
+		return store.IndexEntry{}, err
+	}
+	entry.Positions = im.extracted.Positions
+	entry.Snippet = im.extracted.Snippet
+	if idx.cfg.StoreDocumentText {
+		entry.Text = im.extracted.Text
+	}
+	return entry, nil
+}
+
+// indexEntries indexes documents and marks their frontier items completed in a single transaction.
+// Term ids resolved along the way are cached only after the transaction commits, and the
+// cache is cleared first if another process pruned terms since it was filled.
+func (idx *Index) indexEntries(batch []indexItem) (err error) {
+	if err := idx.cfg.WriteLimiter.Acquire(idx.ctx); err != nil {
+		return err
+	}
+	defer func() { idx.cfg.WriteLimiter.Release(err) }()
+
+	resolved := make(map[string]int64)
+	err = idx.s.InTx(idx.ctx, func(tx store.DBTX) error {
+		generation, err := store.GetPruneGeneration(idx.ctx, tx)
+		if err != nil {
+			return err
+		}
+		if generation != idx.pruneGen {
+			idx.terms.Clear()
+			idx.pruneGen = generation
+		}
 
-			// Commit the transaction
-			err = tx.Commit(idx.ctx)
+		for _, item := range batch {
+			// Index the document
+			ids, err := idx.indexEntry(tx, item.entry)
 			if err != nil {
-				idx.handleError(im, err)
-				continue
+				return fmt.Errorf("%s: %w", item.entry.Url, err)
 			}
+			maps.Copy(resolved, ids)
 
-			idx.logger.Info("Indexed document successfully", "url", im.entry.Url)
+			// Update frontier item status to completed, along with any redirect target
+			if err := store.UpdateFIStatus(idx.ctx, tx, item.im.fi.UrlNorm, store.StatusCompleted); err != nil {
+				return err
+			}
+			if err := idx.markRedirectTarget(tx, item.im, store.StatusCompleted, ""); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	idx.terms.Add(resolved)
+	return nil
+}
+
+// indexEntry indexes one document. A page already indexed under its URL, such as a
+// re-crawled one, is diffed against its stored postings so only changed terms are written.
+func (idx *Index) indexEntry(tx store.DBTX, entry store.IndexEntry) (map[string]int64, error) {
+	ids, reindexed, err := store.ReindexDocumentCached(idx.ctx, tx, entry, idx.terms)
+	if err != nil || reindexed {
+		return ids, err
 	}
+	return store.IndexDocumentCached(idx.ctx, tx, entry, idx.terms)
 }
 
 // handleError processes errors that occur during indexing by updating the frontier item status.
+// The failure reason is recorded on the frontier item. Pages whose content is already
+// indexed under another URL are skipped rather than failed.
 func (idx *Index) handleError(im IndexMessage, err error) {
-	idx.logger.Error("Error indexing document", "url", im.entry.Url, "error", err)
+	if errors.Is(err, store.ErrorDuplicateContent) {
+		idx.skipDuplicate(im)
+		return
+	}
+
+	reason := failureReason(err, reasonIndex)
+	idx.stats.recordFailure(reason)
+	idx.logger.Error("Error indexing document", "url", im.fi.Url, "reason", reason, "error", err)
+	idx.hooks.failed(im.fi.Url, err)
 	conn, e := idx.s.Pool.Acquire(idx.ctx)
 	if e != nil {
-		idx.logger.Error("Error acquiring connection to update status", "url", im.entry.Url, "error", e)
+		idx.logger.Error("Error acquiring connection to update status", "url", im.fi.Url, "error", e)
 		return
 	}
 	defer conn.Release()
-	e = store.UpdateFIStatus(idx.ctx, conn, im.entry.UrlNorm, store.StatusFailed)
+	e = store.MarkFIFailed(idx.ctx, conn, im.fi.UrlNorm, reason)
 	if e != nil {
-		idx.logger.Error("Error updating status to failed", "url", im.entry.UrlNorm, "error", e)
+		idx.logger.Error("Error updating status to failed", "url", im.fi.UrlNorm, "error", e)
+	}
+	if e := idx.markRedirectTarget(conn, im, store.StatusFailed, reason); e != nil {
+		idx.logger.Error("Error updating redirect target status", "url", im.url, "error", e)
 	}
 }
 
-// startWorkflow initializes and starts all components of the crawling pipeline.
+// skipDuplicate marks a page whose content hash is already indexed in its domain as skipped.
+func (idx *Index) skipDuplicate(im IndexMessage) {
+	idx.stats.recordSkip(skipDuplicate)
+	idx.logger.Info("Skipping duplicate content", "url", im.fi.Url, "hash", im.extracted.Hash)
+	conn, err := idx.s.Pool.Acquire(idx.ctx)
+	if err != nil {
+		idx.logger.Error("Error acquiring connection to update status", "url", im.fi.Url, "error", err)
+		return
+	}
+	defer conn.Release()
+	if err := store.UpdateFIStatus(idx.ctx, conn, im.fi.UrlNorm, store.StatusSkipped); err != nil {
+		idx.logger.Error("Error updating status to skipped", "url", im.fi.UrlNorm, "error", err)
+	}
+	if err := idx.markRedirectTarget(conn, im, store.StatusSkipped, ""); err != nil {
+		idx.logger.Error("Error updating redirect target status", "url", im.url, "error", err)
+	}
+}
+
+// markRedirectTarget gives the URL a page redirected to the page's outcome, so the
+// target isn't crawled again when linked to directly. Pages that weren't redirected
+// are left alone.
+func (idx *Index) markRedirectTarget(db store.DBTX, im IndexMessage, status store.FrontierStatusEnum, reason string) error {
+	target, ok := redirectTarget(im.fi, im.url, idx.cfg.PriorityWeights)
+	if !ok {
+		return nil
+	}
+	return store.MarkRedirectTarget(idx.ctx, db, target, status, reason)
+}
+
+// DomainCounts returns the number of pages crawled per domain, including earlier runs.
+func (idx *Index) DomainCounts() map[string]int {
+	return idx.budget.Counts()
+}
+
+// startWorkflow starts all components of the crawling pipeline. They were added to
+// the WaitGroup by NewIndex.
 func (idx *Index) startWorkflow() {
 	go idx.queue.Run()
-	idx.wg.Add(1)
 	go idx.crawler.Run()
-	idx.wg.Add(1)
 	go idx.processor.Run()
-	idx.wg.Add(1)
 }
 
-// Close gracefully shuts down the index and all its components.
-func (idx *Index) Close() {
+// Summary returns what the crawl has done so far. It is safe to call while the crawl runs.
+func (idx *Index) Summary() CrawlSummary {
+	summary := idx.stats.summary()
+	summary.Writes = idx.cfg.WriteLimiter.Stats()
+	return summary
+}
+
+// Close gracefully shuts down the index and all its components, returning and
+// logging the crawl summary. The queue stops dispatching URLs, the crawler finishes
+// its in-flight fetches, the processor its pages and the index its last batch, each
+// stage closing its output after its input. Close waits for Run to return, so Run
+// must have been started.
+func (idx *Index) Close() CrawlSummary {
 	idx.logger.Info("Closing main Index process")
-	idx.queue.Close() // this should cascade through the pipeline.
-	idx.crawler.Close()
-	idx.processor.Close()
-	idx.wg.Done()
+	idx.queue.Close()
+	<-idx.done
+
+	summary := idx.Summary()
+	idx.logger.Info("Crawl summary", summary.LogAttrs()...)
+	return summary
 }
@@ -0,0 +1,182 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jdpolicano/go-search/internal/extract"
+	"github.com/jdpolicano/go-search/internal/extract/language"
+	"github.com/jdpolicano/go-search/internal/store"
+	"github.com/jdpolicano/go-search/internal/store/testutil"
+)
+
+// newTestIndex returns an Index with only its indexing stage, reading from in.
+func newTestIndex(ctx context.Context, s store.Store, in chan IndexMessage, wg *sync.WaitGroup) *Index {
+	ctx, cancel := context.WithCancel(ctx)
+	cfg := DefaultCrawlerConfig()
+	return &Index{
+		in:     in,
+		wg:     wg,
+		s:      s,
+		budget: newDomainBudget(0, nil),
+		stats:  newCrawlStats(),
+		terms:  store.NewTermCache(cfg.TermCacheSize),
+		cfg:    cfg,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+func TestIndexSkipsDuplicateContent(t *testing.T) {
+	dsn, err := testutil.TestDSN()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	const page = `<html lang="en"><head><title>Tomatoes</title></head><body><p>Identical article text about gardening and tomatoes.</p></body></html>`
+	tests := []struct {
+		name     string
+		urls     []string
+		bodies   []string
+		wantDocs int
+	}{
+		{"identical bodies on one domain", []string{"https://example.com/a", "https://example.com/b"}, []string{page, page}, 1},
+		{"identical bodies under three urls", []string{"https://example.com/a", "https://example.com/b", "https://example.com/c?ref=1"}, []string{page, page, page}, 1},
+		{"identical bodies on two domains", []string{"https://example.com/a", "https://example.org/a"}, []string{page, page}, 2},
+		{"different bodies", []string{"https://example.com/a", "https://example.com/b"}, []string{page, strings.Replace(page, "tomatoes", "peppers", 1)}, 2},
+	}
+	parser := extract.NewHtmlParser([]language.Language{language.English})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, cleanup, err := testutil.NewTempStore(ctx, dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cleanup()
+
+			items := make([]store.FrontierItem, len(tt.urls))
+			for i, url := range tt.urls {
+				if items[i], err = store.NewFrontierItemFromSeed(url, store.DefaultPriorityWeights()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := store.InsertFIBatch(ctx, s.Pool, items); err != nil {
+				t.Fatal(err)
+			}
+
+			in := make(chan IndexMessage, len(items))
+			for i, item := range items {
+				doc, err := parser.Parse(strings.NewReader(tt.bodies[i]))
+				if err != nil {
+					t.Fatal(err)
+				}
+				extracted, err := extract.ProcessHtmlDocument(doc)
+				if err != nil {
+					t.Fatal(err)
+				}
+				in <- IndexMessage{fi: item, extracted: extracted}
+			}
+			close(in)
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			idx := newTestIndex(ctx, s, in, &wg)
+			idx.firstPassage()
+			wg.Wait()
+
+			var docs int
+			if err := s.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM docs").Scan(&docs); err != nil {
+				t.Fatal(err)
+			}
+			if docs != tt.wantDocs {
+				t.Errorf("%d doc rows, want %d", docs, tt.wantDocs)
+			}
+			skipped, err := store.GetFICountByStatus(ctx, s.Pool, store.StatusSkipped)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := len(items) - tt.wantDocs; skipped != want {
+				t.Errorf("%d frontier items skipped, want %d", skipped, want)
+			}
+			if got := idx.Summary().Skipped[skipDuplicate]; got != int64(len(items)-tt.wantDocs) {
+				t.Errorf("summary counts %d duplicates, want %d", got, len(items)-tt.wantDocs)
+			}
+		})
+	}
+}
+
+// benchmarkMessage builds the i'th distinct page of a benchmark crawl.
+func benchmarkMessage(i int) (IndexMessage, error) {
+	text := fmt.Sprintf("page %d about crawling indexing ranking and searching the web", i)
+	words, err := extract.ScanWordsFromString(text)
+	if err != nil {
+		return IndexMessage{}, err
+	}
+	freqs := make(map[string]int)
+	positions := make(map[string][]int)
+	for pos, word := range words {
+		freqs[word]++
+		positions[word] = append(positions[word], pos)
+	}
+	url := fmt.Sprintf("https://example.com/page/%d", i)
+	fi, err := store.NewFrontierItemFromSeed(url, store.DefaultPriorityWeights())
+	if err != nil {
+		return IndexMessage{}, err
+	}
+	extracted := extract.Extracted{TermFreqs: freqs, Positions: positions, Hash: strconv.Itoa(i), Len: len(words), Title: "Page"}
+	return IndexMessage{fi: fi, extracted: extracted}, nil
+}
+
+// BenchmarkIndexBatchSize compares committing each document in its own transaction
+// with committing them in batches, reporting documents indexed per second.
+func BenchmarkIndexBatchSize(b *testing.B) {
+	dsn, err := testutil.TestDSN()
+	if err != nil {
+		b.Skip(err)
+	}
+
+	for _, batchSize := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("batch=%d", batchSize), func(b *testing.B) {
+			ctx := context.Background()
+			s, cleanup, err := testutil.NewTempStore(ctx, dsn)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer cleanup()
+
+			in := make(chan IndexMessage, b.N)
+			for i := range b.N {
+				im, err := benchmarkMessage(i)
+				if err != nil {
+					b.Fatal(err)
+				}
+				in <- im
+			}
+			close(in)
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			idx := newTestIndex(ctx, s, in, &wg)
+			idx.cfg.IndexBatchSize = batchSize
+			idx.cfg.IndexFlushInterval = 0
+
+			b.ResetTimer()
+			idx.firstPassage()
+			b.StopTimer()
+
+			if indexed := idx.Summary().Indexed; indexed != int64(b.N) {
+				b.Fatalf("indexed %d of %d documents", indexed, b.N)
+			}
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "docs/s")
+		})
+	}
+}
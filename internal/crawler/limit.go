@@ -0,0 +1,213 @@
+// Package crawler contains per-host concurrency limiting for the web crawler.
+package crawler
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostLimiter bounds the number of concurrent fetches to any single host, and
+// optionally spaces out the start of consecutive fetches to the same host.
+// Each host gets its own semaphore, created lazily on first use.
+type hostLimiter struct {
+	mu     sync.Mutex               // Guards the sems, next and crawl maps and rng
+	sems   map[string]chan struct{} // Per-host semaphores
+	limit  int                      // Maximum in-flight fetches per host
+	delay  time.Duration            // Politeness delay between fetch starts to one host; 0 disables
+	delays map[string]time.Duration // Per-domain overrides of delay, see delayFor
+	crawl  map[string]time.Duration // Crawl-delay requested by each host's robots.txt
+	jitter float64                  // Most each delay is randomly lengthened by, as a fraction of it; never shortened
+	next   map[string]time.Time     // Earliest start time of the next fetch per host
+	rng    *rand.Rand               // Source of jitter
+}
+
+// newHostLimiter creates a hostLimiter allowing at most limit fetches per host,
+// started delay to delay + jitter*delay apart, with delay overridden per domain by
+// delays. Jitter only ever lengthens a delay, never shortens it, so the delay is a
+// floor and a host never sees more than one fetch start per delay however many
+// fetches may be in flight. A limit below 1 is treated as 1, and jitter is clamped
+// to [0, 1]. The jitter is drawn from an RNG seeded with seed, so a fixed seed gives
+// a reproducible schedule; a seed of 0 picks one at random.
+func newHostLimiter(limit int, delay time.Duration, delays map[string]time.Duration, jitter float64, seed int64) *hostLimiter {
+	if limit < 1 {
+		limit = 1
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &hostLimiter{
+		sems:   make(map[string]chan struct{}),
+		limit:  limit,
+		delay:  delay,
+		delays: delays,
+		crawl:  make(map[string]time.Duration),
+		jitter: min(max(jitter, 0), 1),
+		next:   make(map[string]time.Time),
+		rng:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// semFor returns the semaphore for a host, creating it if needed.
+func (l *hostLimiter) semFor(host string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sem, ok := l.sems[host]
+	if !ok {
+		sem = make(chan struct{}, l.limit)
+		l.sems[host] = sem
+	}
+	return sem
+}
+
+// SetCrawlDelay records the Crawl-delay a host's robots.txt asks for. Fetches to the
+// host are spaced by the longer of it and the configured delay, unless the host has
+// its own override, see delayFor.
+func (l *hostLimiter) SetCrawlDelay(host string, delay time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if delay > 0 {
+		l.crawl[host] = delay
+	} else {
+		delete(l.crawl, host)
+	}
+}
+
+// delayFor returns the politeness delay for a host. An override set for the host or
+// one of its parent domains takes precedence over its robots.txt Crawl-delay, so an
+// operator can crawl a site they run faster or slower than it asks; otherwise it is
+// the longer of the Crawl-delay and the "*" or global delay. Callers must hold l.mu.
+func (l *hostLimiter) delayFor(host string) time.Duration {
+	delay, overridden := l.configuredDelay(host)
+	if overridden {
+		return delay
+	}
+	return max(l.crawl[host], delay)
+}
+
+// configuredDelay returns the configured politeness delay for a host, and whether it
+// is an override for the host or one of its parent domains. The most specific override
+// wins: the exact host, then "*.example.com" for any subdomain of example.com, then
+// the "*" wildcard, and finally the global delay.
+func (l *hostLimiter) configuredDelay(host string) (time.Duration, bool) {
+	if delay, ok := l.delays[host]; ok {
+		return delay, true
+	}
+	for domain := host; ; {
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok {
+			break
+		}
+		if delay, ok := l.delays["*."+parent]; ok {
+			return delay, true
+		}
+		domain = parent
+	}
+	if delay, ok := l.delays["*"]; ok {
+		return delay, false
+	}
+	return l.delay, false
+}
+
+// reserve books the next politeness slot for a host and returns how long to wait for it.
+// Slots are handed out in order, so concurrent fetchers to one host queue up behind
+// each other instead of all firing when the previous delay ends.
+func (l *hostLimiter) reserve(host string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delay := l.delayFor(host)
+	if delay <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	start := l.next[host]
+	if start.Before(now) {
+		start = now
+	}
+	factor := 1 + l.jitter*l.rng.Float64()
+	l.next[host] = start.Add(time.Duration(float64(delay) * factor))
+	return start.Sub(now)
+}
+
+// Acquire blocks until a fetch slot for the host is available and its politeness
+// delay has passed, or the context is done.
+func (l *hostLimiter) Acquire(ctx context.Context, host string) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case l.semFor(host) <- struct{}{}:
+	}
+
+	wait := l.reserve(host)
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		l.Release(host)
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Release returns a previously acquired fetch slot for the host.
+func (l *hostLimiter) Release(host string) {
+	<-l.semFor(host)
+}
+
+// Fetch retrieves a URL from host with fetcher while holding one of the host's
+// fetch slots. On success the slot is held until the response body has been read to
+// the end or closed, so the limit bounds downloads rather than just requests.
+func (l *hostLimiter) Fetch(ctx context.Context, fetcher Fetcher, host, url string) (Response, error) {
+	if err := l.Acquire(ctx, host); err != nil {
+		return Response{}, err
+	}
+
+	resp, err := fetcher.Fetch(ctx, url)
+	if err != nil {
+		l.Release(host)
+		return Response{}, err
+	}
+	resp.Body = l.holdUntilRead(host, resp.Body)
+	return resp, nil
+}
+
+// holdUntilRead wraps a response body so the host's fetch slot, already acquired, is
+// released once the body returns an error or EOF, or is closed, whichever is first.
+func (l *hostLimiter) holdUntilRead(host string, body io.Reader) io.Reader {
+	return &slotBody{Reader: body, release: func() { l.Release(host) }}
+}
+
+// slotBody releases a fetch slot when its body is done with. It forwards Close so the
+// body is still released.
+type slotBody struct {
+	io.Reader
+	once    sync.Once // Releases the slot exactly once
+	release func()    // Releases the slot
+}
+
+// Read implements io.Reader.
+func (b *slotBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err != nil {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+// Close closes the underlying body and releases the slot.
+func (b *slotBody) Close() error {
+	defer b.once.Do(b.release)
+	if closer, ok := b.Reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrencyFetcher is a fake Fetcher recording the most fetches in flight to each
+// host at once, a fetch lasting until its body has been read.
+type concurrencyFetcher struct {
+	mu       sync.Mutex
+	inFlight map[string]int
+	peak     map[string]int
+}
+
+func newConcurrencyFetcher() *concurrencyFetcher {
+	return &concurrencyFetcher{inFlight: make(map[string]int), peak: make(map[string]int)}
+}
+
+func (f *concurrencyFetcher) Fetch(ctx context.Context, url string) (Response, error) {
+	host := strings.Split(strings.TrimPrefix(url, "https://"), "/")[0]
+	f.mu.Lock()
+	f.inFlight[host]++
+	f.peak[host] = max(f.peak[host], f.inFlight[host])
+	f.mu.Unlock()
+
+	// Give other fetches to the host a chance to overlap with this one
+	time.Sleep(time.Millisecond)
+	body := &doneReader{Reader: strings.NewReader("<html></html>"), done: func() {
+		f.mu.Lock()
+		f.inFlight[host]--
+		f.mu.Unlock()
+	}}
+	return Response{Url: url, Body: body}, nil
+}
+
+// doneReader calls done once it has been read to the end.
+type doneReader struct {
+	io.Reader
+	once sync.Once
+	done func()
+}
+
+func (r *doneReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil {
+		r.once.Do(r.done)
+	}
+	return n, err
+}
+
+func TestHostLimiterConcurrency(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		hosts   []string
+		fetches int
+	}{
+		{"one per host", 1, []string{"a.example"}, 20},
+		{"two per host", 2, []string{"a.example"}, 20},
+		{"four per host across hosts", 4, []string{"a.example", "b.example", "c.example"}, 30},
+		{"limit below one", 0, []string{"a.example"}, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newHostLimiter(tt.limit, 0, nil, 0, 1)
+			fetcher := newConcurrencyFetcher()
+
+			var wg sync.WaitGroup
+			errs := make(chan error, len(tt.hosts)*tt.fetches)
+			for _, host := range tt.hosts {
+				for i := range tt.fetches {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						resp, err := limiter.Fetch(context.Background(), fetcher, host, fmt.Sprintf("https://%s/%d", host, i))
+						if err != nil {
+							errs <- err
+							return
+						}
+						io.Copy(io.Discard, resp.Body)
+					}()
+				}
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Fatal(err)
+			}
+
+			want := max(tt.limit, 1)
+			for _, host := range tt.hosts {
+				if peak := fetcher.peak[host]; peak > want {
+					t.Errorf("%s: %d concurrent fetches, want at most %d", host, peak, want)
+				}
+				if inFlight := fetcher.inFlight[host]; inFlight != 0 {
+					t.Errorf("%s: %d fetches never finished", host, inFlight)
+				}
+			}
+		})
+	}
+}
+
+func TestHostLimiterReleasesOnCancel(t *testing.T) {
+	limiter := newHostLimiter(1, 0, nil, 0, 1)
+	if err := limiter.Acquire(context.Background(), "a.example"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Acquire(ctx, "a.example"); err == nil {
+		t.Fatal("acquired a second slot past the limit")
+	}
+
+	limiter.Release("a.example")
+	if err := limiter.Acquire(context.Background(), "a.example"); err != nil {
+		t.Fatal(err)
+	}
+}
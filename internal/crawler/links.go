@@ -0,0 +1,72 @@
+// Package crawler provides resolution and cleanup of extracted links.
+package crawler
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/jdpolicano/go-search/internal/extract"
+	"github.com/jdpolicano/go-search/internal/store"
+)
+
+// resolveLinks turns the raw links and meta refresh target of a page fetched from
+// pageURL into absolute URLs, resolved against the page's <base href> when it has
+// one. Links that aren't http(s), such as javascript: and mailto:, are dropped, as
+// are links that fail to resolve, and links are de-duplicated by their normalized
+// form so the frontier isn't flooded with variants of the same URL. A refresh target
+// that doesn't resolve to an http(s) URL is cleared.
+func resolveLinks(pageURL string, extracted extract.Extracted) extract.Extracted {
+	base := pageURL
+	if extracted.Base != "" {
+		if resolved, ok := resolveLink(pageURL, extracted.Base); ok {
+			base = resolved
+		}
+	}
+
+	seen := make(map[string]struct{}, len(extracted.Links))
+	links := make([]string, 0, len(extracted.Links))
+	for _, link := range extracted.Links {
+		resolved, ok := resolveLink(base, link)
+		if !ok {
+			continue
+		}
+		norm, err := store.NormalizeURL(resolved)
+		if err != nil {
+			continue
+		}
+		if _, ok := seen[norm]; ok {
+			continue
+		}
+		seen[norm] = struct{}{}
+		links = append(links, resolved)
+	}
+	extracted.Links = links
+
+	if extracted.Refresh != "" {
+		extracted.Refresh, _ = resolveLink(base, extracted.Refresh)
+	}
+	return extracted
+}
+
+// resolveLink resolves href against base with store.MakeUrl, dropping any fragment.
+// ok is false when it doesn't resolve to an absolute http(s) URL with a host.
+func resolveLink(base, href string) (string, bool) {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return "", false
+	}
+	resolved, err := store.MakeUrl(base, href)
+	if err != nil {
+		return "", false
+	}
+	u, err := url.Parse(resolved)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	if scheme := strings.ToLower(u.Scheme); scheme != "http" && scheme != "https" {
+		return "", false
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String(), true
+}
@@ -2,28 +2,325 @@
 package crawler
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// GetReaderFromUrl fetches content from a URL and returns it as an io.Reader.
-// It sets appropriate headers and handles HTTP status codes.
-func getReaderFromUrl(url string) (io.Reader, error) {
-	client := &http.Client{}
-	// Create a new request with proper headers
-	req, _ := http.NewRequest("GET", url, nil)
+// Response is the result of fetching a URL.
+type Response struct {
+	Url    string      // URL the content was fetched from, after any redirects
+	Header http.Header // Response headers
+	Body   io.Reader   // Response body
+}
+
+// Fetcher retrieves the content of a URL. The crawler depends on this interface
+// rather than on net/http directly so it can be driven by canned fixtures.
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) (Response, error)
+}
+
+// StatusError is returned when a server answers with a status other than 200 OK.
+type StatusError struct {
+	Code       int           // HTTP status code
+	RetryAfter time.Duration // Delay requested by a Retry-After header in seconds; 0 if absent
+}
+
+// Error implements error.
+func (e StatusError) Error() string {
+	return fmt.Sprintf("status error %v", e.Code)
+}
+
+// retryAfter parses a Retry-After header given in seconds. HTTP dates are ignored.
+func retryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// ErrorTooManyRedirects is returned when a redirect chain exceeds maxRedirects.
+var ErrorTooManyRedirects = errors.New("too many redirects")
+
+// ErrorRedirectLoop is returned when a redirect leads back to a URL already visited in the chain.
+var ErrorRedirectLoop = errors.New("redirect loop")
+
+// ErrorDisqualified is returned when a pre-flight HEAD request shows a URL isn't worth fetching.
+var ErrorDisqualified = errors.New("resource disqualified by pre-flight check")
+
+// ErrorBodyTooLarge is returned when a response body is longer than FetchConfig.MaxBodySize.
+var ErrorBodyTooLarge = errors.New("response body too large")
+
+// FetchConfig holds the settings for the default HttpFetcher.
+type FetchConfig struct {
+	HeadPreflight       bool     // Send a HEAD request first and skip the GET for disqualified resources
+	AllowedContentTypes []string // Media types worth fetching, checked during pre-flight; empty allows all
+	MaxContentLength    int64    // Largest advertised Content-Length worth fetching, checked during pre-flight; 0 is unlimited
+
+	// MaxBodySize caps the bytes read from a decompressed response body, so a huge
+	// page or a server streaming forever can't exhaust memory while it is parsed.
+	// Reading past it fails with ErrorBodyTooLarge rather than truncating the page.
+	// 0 is unlimited.
+	MaxBodySize int64
+
+	// Timeout bounds each request, from dialing until the body has been read, so a
+	// slow or hanging server can't stall a worker. 0 waits indefinitely; either way
+	// requests are also aborted when the fetch context is canceled.
+	Timeout time.Duration
+
+	// Headers are sent with every request, after the default User-Agent, so they may
+	// replace it. They are global: every host the crawl reaches receives them, so
+	// credentials and API keys must never go in Headers. DomainHeaders are sent only
+	// to the exact host they are keyed by and take precedence over Headers, e.g. an
+	// Authorization header for an internal wiki.
+	Headers       map[string]string
+	DomainHeaders map[string]map[string]string
+	CookieJar     http.CookieJar // Stores and sends session cookies; nil sends no cookies
+
+	// Renderer, if set, fetches pages as rendered by a browser instead of the raw
+	// response, for sites built with JavaScript. RenderDomains limits it to those
+	// hosts; when empty every page is rendered.
+	Renderer      Renderer
+	RenderDomains []string
+}
+
+// DefaultFetchConfig returns a FetchConfig populated with safe defaults.
+// The pre-flight HEAD is off by default since it doubles the requests for pages that pass.
+func DefaultFetchConfig() FetchConfig {
+	return FetchConfig{
+		AllowedContentTypes: []string{"text/html", "application/xhtml+xml"},
+		MaxContentLength:    5 << 20,
+		MaxBodySize:         5 << 20,
+		Timeout:             30 * time.Second,
+	}
+}
+
+// HttpFetcher is the default Fetcher, retrieving pages over HTTP.
+type HttpFetcher struct {
+	client *http.Client // HTTP client used for every request
+	cfg    FetchConfig  // Fetch configuration
+}
+
+// DefaultUserAgent is sent with every request unless a configured header replaces it.
+// Its product token, MyGoScraper, selects our rules in robots.txt files.
+const DefaultUserAgent = "MyGoScraper/1.0 (jdpolicano@gmail.com)"
+
+// maxRedirects matches the net/http default redirect limit, which a custom CheckRedirect replaces.
+const maxRedirects = 10
+
+// NewHttpFetcher creates a new HttpFetcher with the given configuration. Its client
+// is shared by every request, so connections to a host are reused.
+func NewHttpFetcher(cfg FetchConfig) *HttpFetcher {
+	f := &HttpFetcher{cfg: cfg}
+	f.client = &http.Client{Jar: cfg.CookieJar, CheckRedirect: f.checkRedirect, Timeout: cfg.Timeout}
+	return f
+}
+
+// setHeaders applies the User-Agent and configured headers for the request's host.
+func (f *HttpFetcher) setHeaders(req *http.Request) {
 	// Set a User-Agent header (required by Wikipedia and many sites)
 	// Format: <MyBotName>/<Version> (contact information)
-	req.Header.Set("User-Agent", "MyGoScraper/1.0 (jdpolicano@gmail.com)")
-	response, ioErr := client.Do(req)
+	req.Header.Set("User-Agent", DefaultUserAgent)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	for name, value := range f.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range f.cfg.DomainHeaders[req.URL.Hostname()] {
+		req.Header.Set(name, value)
+	}
+}
+
+// checkRedirect caps redirect chains at maxRedirects and rejects loops, then keeps
+// domain specific headers from following a redirect to another host.
+// net/http copies headers onto redirects, dropping Authorization and Cookie when the
+// host changes but not arbitrary headers like API keys, so the previous host's domain
+// headers are removed and the new host's applied. Headers is not reapplied, so any
+// sensitive header net/http stripped stays stripped.
+func (f *HttpFetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("%w: stopped after %d redirects from %s", ErrorTooManyRedirects, maxRedirects, via[0].URL)
+	}
+	next := req.URL.String()
+	for _, prev := range via {
+		if prev.URL.String() == next {
+			return fmt.Errorf("%w: %s", ErrorRedirectLoop, redirectChain(via, req))
+		}
+	}
+
+	from := via[len(via)-1].URL.Hostname()
+	to := req.URL.Hostname()
+	if to == from {
+		return nil
+	}
+	for name := range f.cfg.DomainHeaders[from] {
+		req.Header.Del(name)
+		// Restore a global value the domain header overrode, unless net/http stripped it
+		if value, ok := f.cfg.Headers[name]; ok && !isSensitiveHeader(name) {
+			req.Header.Set(name, value)
+		}
+	}
+	for name, value := range f.cfg.DomainHeaders[to] {
+		req.Header.Set(name, value)
+	}
+	return nil
+}
+
+// redirectChain formats the URLs of a redirect chain, e.g. "a -> b -> a".
+func redirectChain(via []*http.Request, req *http.Request) string {
+	urls := make([]string, 0, len(via)+1)
+	for _, prev := range via {
+		urls = append(urls, prev.URL.String())
+	}
+	return strings.Join(append(urls, req.URL.String()), " -> ")
+}
+
+// isSensitiveHeader reports whether net/http strips a header on cross-host redirects.
+func isSensitiveHeader(name string) bool {
+	switch http.CanonicalHeaderKey(name) {
+	case "Authorization", "Www-Authenticate", "Cookie", "Cookie2":
+		return true
+	}
+	return false
+}
+
+// Fetch fetches content from a URL and returns it as a Response.
+// It sets appropriate headers and handles HTTP status codes. URLs selected for
+// rendering are delegated to the configured Renderer. Either way the body is
+// limited to MaxBodySize.
+func (f *HttpFetcher) Fetch(ctx context.Context, url string) (Response, error) {
+	if f.shouldRender(url) {
+		resp, err := f.cfg.Renderer.Render(ctx, url)
+		if err != nil {
+			return Response{}, err
+		}
+		resp.Body = limitBody(resp.Body, f.cfg.MaxBodySize)
+		return resp, nil
+	}
+
+	if f.cfg.HeadPreflight {
+		if err := f.preflight(ctx, url); err != nil {
+			return Response{}, err
+		}
+	}
+
+	// Create a new request with proper headers
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Response{}, err
+	}
+	f.setHeaders(req)
+	response, ioErr := f.client.Do(req)
 	if ioErr != nil {
-		return nil, ioErr
+		return Response{}, ioErr
 	}
 
 	if response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("status error %v", response.StatusCode)
+		response.Body.Close()
+		return Response{}, StatusError{response.StatusCode, retryAfter(response.Header)}
 	}
 
-	return response.Body, nil
+	if err := decodeBody(response); err != nil {
+		response.Body.Close()
+		return Response{}, fmt.Errorf("decoding %s body: %w", response.Header.Get("Content-Encoding"), err)
+	}
+
+	// Fail early when the server announces a body over the limit
+	if f.cfg.MaxBodySize > 0 && response.ContentLength > f.cfg.MaxBodySize {
+		response.Body.Close()
+		return Response{}, fmt.Errorf("%w: content length %d exceeds %d", ErrorBodyTooLarge, response.ContentLength, f.cfg.MaxBodySize)
+	}
+
+	body := limitBody(response.Body, f.cfg.MaxBodySize)
+	return Response{Url: response.Request.URL.String(), Header: response.Header, Body: body}, nil
+}
+
+// limitedBody reads a response body up to a limit, failing with ErrorBodyTooLarge
+// once the body goes past it. It forwards Close so the body is still released.
+type limitedBody struct {
+	io.LimitedReader       // Limited to one byte past the limit, to tell a body of exactly the limit from a longer one
+	limit            int64 // Most bytes the body may have
+}
+
+// limitBody wraps body so reading more than limit bytes fails; a limit of 0 leaves it as is.
+func limitBody(body io.Reader, limit int64) io.Reader {
+	if limit <= 0 {
+		return body
+	}
+	return &limitedBody{io.LimitedReader{R: body, N: limit + 1}, limit}
+}
+
+// Read implements io.Reader.
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.LimitedReader.Read(p)
+	if b.N <= 0 {
+		return n, fmt.Errorf("%w: exceeds %d bytes", ErrorBodyTooLarge, b.limit)
+	}
+	return n, err
+}
+
+// Close closes the underlying body.
+func (b *limitedBody) Close() error {
+	if closer, ok := b.R.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// preflight issues a HEAD request and returns ErrorDisqualified if the advertised
+// content type or length rules the resource out. Servers that reject or fail the
+// HEAD are given the benefit of the doubt, so the GET still happens.
+func (f *HttpFetcher) preflight(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+	f.setHeaders(req)
+	response, err := f.client.Do(req)
+	if err != nil {
+		return nil
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	if contentType := response.Header.Get("Content-Type"); contentType != "" && len(f.cfg.AllowedContentTypes) > 0 {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err == nil && !slices.Contains(f.cfg.AllowedContentTypes, mediaType) {
+			return fmt.Errorf("%w: content type %q", ErrorDisqualified, mediaType)
+		}
+	}
+
+	if f.cfg.MaxContentLength > 0 && response.ContentLength > f.cfg.MaxContentLength {
+		return fmt.Errorf("%w: content length %d exceeds %d", ErrorDisqualified, response.ContentLength, f.cfg.MaxContentLength)
+	}
+
+	return nil
+}
+
+// FakeFetcher is a Fetcher that serves canned bodies from memory, for tests.
+// URLs without an entry fail with a 404 status error.
+type FakeFetcher map[string]string
+
+// Fetch returns the canned body for a URL.
+func (f FakeFetcher) Fetch(ctx context.Context, url string) (Response, error) {
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+	body, ok := f[url]
+	if !ok {
+		return Response{}, StatusError{Code: http.StatusNotFound}
+	}
+	header := http.Header{"Content-Type": []string{"text/html; charset=utf-8"}}
+	return Response{Url: url, Header: header, Body: bytes.NewReader([]byte(body))}, nil
 }
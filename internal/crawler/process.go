@@ -2,20 +2,27 @@
 package crawler
 
 import (
+	"cmp"
 	"context"
 	"io"
 	"log/slog"
+	"net/http"
+	"slices"
 	"sync"
+	"sync/atomic"
 
 	"github.com/jdpolicano/go-search/internal/extract"
 	"github.com/jdpolicano/go-search/internal/extract/language"
 	"github.com/jdpolicano/go-search/internal/store"
+	"golang.org/x/net/html"
 )
 
 // ProcessorMessage represents a message containing fetched web content to be processed.
 type ProcessorMessage struct {
 	fi     store.FrontierItem // Frontier item metadata
+	url    string             // URL the content was fetched from, after any redirects
 	reader io.Reader          // Fetched content reader
+	header http.Header        // Response headers, e.g. Content-Language; may be nil
 }
 
 // Processor handles the extraction and processing of web content.
@@ -27,21 +34,29 @@ type Processor struct {
 	wg     *sync.WaitGroup           // WaitGroup for goroutine management
 	parser *extract.HtmlParser       // HTML parser for content extraction
 	s      store.Store               // Database store
+	hooks  *Hooks                    // Optional pipeline observation hooks
+	stats  *crawlStats               // Counters for the crawl summary
+	cfg    CrawlerConfig             // Crawler configuration
+	thin   atomic.Int64              // Number of documents skipped for having too little content
+	mu     sync.RWMutex              // Held for reading during sends, for writing while closing
+	closed bool                      // Whether the output channels have been closed
 	ctx    context.Context           // Context for cancellation
 	cancel context.CancelFunc        // Cancel function for stopping the processor
 	logger *slog.Logger              // Structured logger
 }
 
 // NewProcessor creates a new Processor instance with the given configuration.
-func NewProcessor(ctx context.Context, cancel context.CancelFunc, s store.Store, in chan ProcessorMessage, queue chan []store.FrontierItem, langs []language.Language, wg *sync.WaitGroup, logger *slog.Logger) *Processor {
+func NewProcessor(ctx context.Context, cancel context.CancelFunc, s store.Store, in chan ProcessorMessage, queue chan []store.FrontierItem, langs []language.Language, cfg CrawlerConfig, stats *crawlStats, hooks *Hooks, wg *sync.WaitGroup, logger *slog.Logger) *Processor {
 	index := make(chan IndexMessage)
 	parser := extract.NewHtmlParser(langs)
-	return &Processor{in, queue, index, wg, parser, s, ctx, cancel, logger}
+	return &Processor{in, queue, index, wg, parser, s, hooks, stats, cfg, atomic.Int64{}, sync.RWMutex{}, false, ctx, cancel, logger}
 }
 
 // Run starts the processor's main loop, handling incoming content from the crawler.
+// Its output channels are closed when it returns, so the queue and index drain.
 func (p *Processor) Run() {
 	defer p.wg.Done()
+	defer p.closeOutputs()
 	for {
 		select {
 		case <-p.ctx.Done():
@@ -50,7 +65,6 @@ func (p *Processor) Run() {
 		case pc, ok := <-p.in:
 			if !ok {
 				p.logger.Info("Processor \"in\" channel closed")
-				p.cancel()
 				return
 			}
 			p.processMessage(pc)
@@ -59,21 +73,48 @@ func (p *Processor) Run() {
 }
 
 // processMessage handles a single processor message by parsing HTML and coordinating outputs.
+// The response body is closed once it has been processed, so a page rejected before
+// its body was read to the end still releases its connection.
 func (p *Processor) processMessage(pm ProcessorMessage) {
-	// Parse the HTML content
-	doc, parseErr := p.parser.Parse(pm.reader)
-	if parseErr != nil {
-		p.handleError(pm, parseErr)
-		return
+	if closer, ok := pm.reader.(io.Closer); ok {
+		defer closer.Close()
 	}
 
-	// Extract text, links, and metadata from the parsed document
-	extracted, err := extract.ProcessHtmlDocument(doc)
+	// Parse and extract text, links, and metadata from the document
+	extracted, err := p.extract(pm)
 	if err != nil {
 		p.handleError(pm, err)
 		return
 	}
 
+	// Follow meta refresh redirects like any other link
+	if extracted.Refresh != "" {
+		extracted.Links = append(extracted.Links, extracted.Refresh)
+		if p.cfg.SkipRefreshStubs {
+			p.logger.Info("Skipping meta refresh stub", "url", pm.fi.Url, "target", extracted.Refresh)
+			p.stats.recordSkip(skipRefreshStub)
+			p.completeWithoutIndexing(pm, extracted)
+			return
+		}
+	}
+
+	// A page with no text and nowhere to go is almost certainly not what a browser sees
+	if extracted.Len == 0 && len(extracted.Links) == 0 {
+		p.handleError(pm, extract.ErrorNoContent)
+		return
+	}
+
+	// Thin pages (empty templates, nav-only shells) would only skew corpus statistics,
+	// so record them as crawled without indexing, but still follow their links. Pages
+	// with no terms at all are never indexed, whatever the threshold.
+	if extracted.Len == 0 || extracted.Len < p.cfg.MinDocumentTerms {
+		skipped := p.thin.Add(1)
+		p.stats.recordSkip(skipThin)
+		p.logger.Info("Skipping thin document", "url", pm.fi.Url, "terms", extracted.Len, "min", p.cfg.MinDocumentTerms, "skipped", skipped)
+		p.completeWithoutIndexing(pm, extracted)
+		return
+	}
+
 	// Send extracted content to both index and queue concurrently
 	var wg sync.WaitGroup
 	wg.Add(2)
@@ -85,78 +126,177 @@ func (p *Processor) processMessage(pm ProcessorMessage) {
 	wg.Wait()
 }
 
+// extract parses a document and runs the configured content extraction over it.
+func (p *Processor) extract(pm ProcessorMessage) (extract.Extracted, error) {
+	pageURL := pm.fi.Url
+	if pm.url != "" {
+		pageURL = pm.url
+	}
+	return extractPage(p.parser, p.cfg, pageURL, pm.reader, pm.header)
+}
+
+// extractPage parses a document fetched from pageURL and served with the given
+// response headers, and runs the content extraction cfg selects over it. The body is
+// transcoded to UTF-8 first, according to its declared charset, and header may be
+// nil. Links and the refresh target are returned resolved against the page, see
+// resolveLinks.
+func extractPage(parser *extract.HtmlParser, cfg CrawlerConfig, pageURL string, reader io.Reader, header http.Header) (extract.Extracted, error) {
+	reader = decodeCharset(reader, header.Get("Content-Type"))
+	contentLanguage := header.Get("Content-Language")
+
+	var extracted extract.Extracted
+	var err error
+	if cfg.StreamingExtraction {
+		extracted, err = parser.ProcessStream(reader, contentLanguage, cfg.Extract)
+	} else {
+		var doc *html.Node
+		doc, err = parser.ParseWithContentLanguage(reader, contentLanguage)
+		if err != nil {
+			return extract.Extracted{}, err
+		}
+		if cfg.Readability {
+			extracted, err = extract.ProcessMainContent(doc, cfg.Extract)
+		} else {
+			extracted, err = extract.ProcessHtmlDocumentWithOptions(doc, cfg.Extract)
+		}
+	}
+	if err != nil {
+		return extract.Extracted{}, err
+	}
+	return resolveLinks(pageURL, extracted), nil
+}
+
+// completeWithoutIndexing marks a document as completed without indexing it,
+// while still queueing the links it contains.
+func (p *Processor) completeWithoutIndexing(pm ProcessorMessage, extracted extract.Extracted) {
+	conn, err := p.s.Pool.Acquire(p.ctx)
+	if err != nil {
+		p.logger.Error("Error acquiring connection to update status", "url", pm.fi.UrlNorm, "error", err)
+	} else {
+		if err := store.UpdateFIStatus(p.ctx, conn, pm.fi.UrlNorm, store.StatusCompleted); err != nil {
+			p.logger.Error("Error updating status to completed", "url", pm.fi.UrlNorm, "error", err)
+		}
+		conn.Release()
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	p.sendToQueue(pm, extracted, &wg)
+}
+
 // handleError processes errors that occur during content processing.
+// The failure reason is recorded on the frontier item.
 func (p *Processor) handleError(pm ProcessorMessage, err error) {
-	p.logger.Error("Content processing error", "url", pm.fi.Url, "error", err)
+	reason := failureReason(err, reasonParse)
+	p.stats.recordFailure(reason)
+	p.logger.Error("Content processing error", "url", pm.fi.Url, "reason", reason, "error", err)
+	p.hooks.failed(pm.fi.Url, err)
 	conn, e := p.s.Pool.Acquire(p.ctx)
 	if e != nil {
 		p.logger.Error("Error acquiring connection to update status", "url", pm.fi.UrlNorm, "error", e)
 		return
 	}
 	defer conn.Release()
-	e = store.UpdateFIStatus(p.ctx, conn, pm.fi.UrlNorm, store.StatusFailed)
+	e = store.MarkFIFailed(p.ctx, conn, pm.fi.UrlNorm, reason)
 	if e != nil {
 		p.logger.Error("Error updating status to failed", "url", pm.fi.UrlNorm, "error", e)
 	}
 }
 
-// getIndexEntry creates an index entry from processed content.
-func (p *Processor) getIndexEntry(pm ProcessorMessage, extracted extract.Extracted) (store.IndexEntry, error) {
-	url := pm.fi.Url
-	hash := extracted.Hash
-	len := extracted.Len
-	termFreqs := extracted.TermFreqs
-	return store.NewIndexEntry(url, hash, len, termFreqs)
-}
-
 // getFrontierMessages creates frontier items from extracted links for queue processing.
+// Links are already absolute, see resolveLinks, and the page's final URL is recorded
+// as their parent.
 func (p *Processor) getFrontierMessages(pc ProcessorMessage, links []string) []store.FrontierItem {
+	parent := pc.fi
+	if pc.url != "" {
+		parent.Url = pc.url
+	}
 	items := make([]store.FrontierItem, 0, len(links))
 	for _, link := range links {
-		item, err := store.NewFrontierItemFromParent(pc.fi, link)
+		item, err := store.NewFrontierItemFromParent(parent, link, p.cfg.PriorityWeights)
 		if err != nil {
 			p.logger.Warn("Error creating frontier item from link", "url", pc.fi.Url, "link", link, "error", err)
 			continue
 		}
+		if !p.cfg.URLFilters.Keep(item.UrlNorm, item.Depth) {
+			p.logger.Debug("Link rejected by URL filters", "url", pc.fi.Url, "link", item.UrlNorm)
+			continue
+		}
 		items = append(items, item)
 	}
 
+	// Keep only the highest priority links of link-dense hub pages, ties in page order
+	if limit := p.cfg.MaxLinksPerPage; limit > 0 && len(items) > limit {
+		p.logger.Info("Truncating page links", "url", pc.fi.Url, "links", len(items), "max", limit)
+		slices.SortStableFunc(items, func(a, b store.FrontierItem) int {
+			return cmp.Compare(b.Priority, a.Priority)
+		})
+		items = items[:limit]
+	}
+
 	return items
 }
 
 // sendToIndex sends processed content to the index for storage.
-func (p *Processor) sendToIndex(pm ProcessorMessage, extracted extract.Extracted, wg *sync.WaitGroup) error {
-	entry, err := p.getIndexEntry(pm, extracted)
-	if err != nil {
-		return err
+func (p *Processor) sendToIndex(pm ProcessorMessage, extracted extract.Extracted, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	// Hold the read lock for the whole send so Close can't close the channel under us.
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		p.logger.Info("Processor closed, not sending to index")
+		return
 	}
-	msg := IndexMessage{entry: entry}
+
+	msg := IndexMessage{fi: pm.fi, url: pm.url, extracted: extracted}
 	select {
 	case <-p.ctx.Done():
 		p.logger.Info("Processor context done, not sending to index")
 	case p.index <- msg:
 		p.logger.Info("Processor sent to index", "url", pm.fi.Url)
 	}
-	wg.Done()
-	return nil
 }
 
 // sendToQueue sends extracted links to the queue for future crawling.
 func (p *Processor) sendToQueue(pm ProcessorMessage, ex extract.Extracted, wg *sync.WaitGroup) {
+	defer wg.Done()
 	msgs := p.getFrontierMessages(pm, ex.Links)
+
+	// Hold the read lock for the whole send so Close can't close the channel under us.
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		p.logger.Info("Processor closed, not sending to queue")
+		return
+	}
+
 	select {
 	case <-p.ctx.Done():
 		p.logger.Info("Processor context done, not sending to queue")
 	case p.queue <- msgs:
 		p.logger.Info("Processor sent new URLs to queue", "url", pm.fi.Url, "count", len(msgs))
 	}
-	wg.Done()
 }
 
-// Close gracefully shuts down the processor by closing channels and signaling completion.
+// Close gracefully shuts down the processor by closing its output channels.
+// It cancels the context first so pending sends give up, then waits for them to
+// finish before closing, so a send can never hit a closed channel. Close is
+// safe to call more than once.
 func (p *Processor) Close() {
 	p.logger.Info("Closing Processor")
+	p.cancel()
+	p.closeOutputs()
+}
+
+// closeOutputs closes the output channels, once no send is in progress.
+func (p *Processor) closeOutputs() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
 	close(p.queue)
 	close(p.index)
-	p.wg.Done()
 }
@@ -0,0 +1,144 @@
+package crawler
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jdpolicano/go-search/internal/extract"
+	"github.com/jdpolicano/go-search/internal/extract/language"
+	"github.com/jdpolicano/go-search/internal/store"
+	"github.com/jdpolicano/go-search/internal/store/testutil"
+)
+
+func TestProcessorSendsRaceClose(t *testing.T) {
+	tests := []struct {
+		name    string
+		senders int
+		drain   bool // Whether the index and queue read what is sent
+	}{
+		{"blocked sends", 16, false},
+		{"draining sends", 16, true},
+		{"single send", 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			queue := make(chan []store.FrontierItem)
+			var wg sync.WaitGroup
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			p := NewProcessor(ctx, cancel, store.Store{}, make(chan ProcessorMessage), queue, []language.Language{language.English},
+				DefaultCrawlerConfig(), newCrawlStats(), nil, &wg, logger)
+
+			if tt.drain {
+				go func() {
+					for range queue {
+					}
+				}()
+				go func() {
+					for range p.index {
+					}
+				}()
+			}
+
+			var senders sync.WaitGroup
+			senders.Add(2 * tt.senders)
+			for range tt.senders {
+				pm := ProcessorMessage{fi: store.FrontierItem{Url: "https://example.com/", UrlNorm: "https://example.com/"}}
+				go p.sendToIndex(pm, extract.Extracted{}, &senders)
+				go p.sendToQueue(pm, extract.Extracted{}, &senders)
+			}
+
+			// Close while sends are in flight; none may send on a closed channel
+			time.Sleep(time.Millisecond)
+			p.Close()
+			p.Close()
+
+			done := make(chan struct{})
+			go func() {
+				senders.Wait()
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("sends still blocked after Close")
+			}
+		})
+	}
+}
+
+func TestProcessorSkipsPagesWithoutTerms(t *testing.T) {
+	dsn, err := testutil.TestDSN()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	const navOnly = `<html lang="en"><head><title></title></head><body><nav><a href="/about"></a><a href="/contact"></a></nav></body></html>`
+	tests := []struct {
+		name     string
+		minTerms int
+	}{
+		{"threshold off", 0},
+		{"threshold one", 1},
+		{"threshold ten", 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			s, cleanup, err := testutil.NewTempStore(ctx, dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cleanup()
+
+			fi, err := store.NewFrontierItemFromSeed("https://example.com/", store.DefaultPriorityWeights())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.InsertFIBatch(ctx, s.Pool, []store.FrontierItem{fi}); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := DefaultCrawlerConfig()
+			cfg.MinDocumentTerms = tt.minTerms
+			queue := make(chan []store.FrontierItem, 1)
+			var wg sync.WaitGroup
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			p := NewProcessor(ctx, cancel, s, make(chan ProcessorMessage), queue, []language.Language{language.English},
+				cfg, newCrawlStats(), nil, &wg, logger)
+
+			// Index whatever the processor sends on, so a page that is not skipped gets a doc row
+			wg.Add(1)
+			idx := newTestIndex(ctx, s, p.index, &wg)
+			go idx.firstPassage()
+			p.processMessage(ProcessorMessage{fi: fi, reader: strings.NewReader(navOnly)})
+			p.closeOutputs()
+			wg.Wait()
+
+			var docs int
+			if err := s.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM docs").Scan(&docs); err != nil {
+				t.Fatal(err)
+			}
+			if docs != 0 {
+				t.Errorf("%d doc rows, want 0", docs)
+			}
+			completed, err := store.GetFICountByStatus(ctx, s.Pool, store.StatusCompleted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if completed != 1 {
+				t.Errorf("%d frontier items completed, want 1", completed)
+			}
+			if links := <-queue; len(links) != 2 {
+				t.Errorf("%d links queued, want 2", len(links))
+			}
+		})
+	}
+}
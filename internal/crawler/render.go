@@ -0,0 +1,77 @@
+// Package crawler contains pluggable rendering of JavaScript-driven pages.
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// Renderer fetches the rendered DOM of a page, after its scripts have run, as HTML.
+// Implementations might drive a local headless browser (e.g. chromedp) or call a
+// remote rendering service; RemoteRenderer covers the latter.
+type Renderer interface {
+	Render(ctx context.Context, url string) (Response, error)
+}
+
+// RemoteRenderer is a Renderer backed by an HTTP rendering service that takes the
+// page to render in a "url" query parameter and responds with the rendered HTML.
+type RemoteRenderer struct {
+	Endpoint string       // Base URL of the rendering service
+	Client   *http.Client // Client used to call the service; nil uses http.DefaultClient
+}
+
+// Render asks the service to render a page and returns its HTML. The response's Url
+// is the page's, not the service's, so links resolve against the rendered page.
+// The service's headers describe its own response rather than the page's, so only
+// its Content-Type, giving the rendered HTML's charset, is kept; the page's language
+// then comes from its lang attribute or detection, not a Content-Language header.
+func (r RemoteRenderer) Render(ctx context.Context, pageUrl string) (Response, error) {
+	endpoint, err := url.Parse(r.Endpoint)
+	if err != nil {
+		return Response{}, err
+	}
+	query := endpoint.Query()
+	query.Set("url", pageUrl)
+	endpoint.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return Response{}, err
+	}
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(req)
+	if err != nil {
+		return Response{}, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return Response{}, fmt.Errorf("render status error %v", response.StatusCode)
+	}
+
+	header := http.Header{}
+	if contentType := response.Header.Get("Content-Type"); contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return Response{Url: pageUrl, Header: header, Body: response.Body}, nil
+}
+
+// shouldRender reports whether a URL is fetched through the configured Renderer:
+// always when RenderDomains is empty, otherwise only for the hosts it lists.
+func (f *HttpFetcher) shouldRender(rawUrl string) bool {
+	if f.cfg.Renderer == nil {
+		return false
+	}
+	if len(f.cfg.RenderDomains) == 0 {
+		return true
+	}
+	u, err := url.Parse(rawUrl)
+	return err == nil && slices.Contains(f.cfg.RenderDomains, u.Hostname())
+}
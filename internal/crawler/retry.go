@@ -0,0 +1,62 @@
+// Package crawler contains retrying of transient fetch failures.
+package crawler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// fetchRetryable reports whether a failed fetch is worth trying again: network errors,
+// 429 Too Many Requests and 5xx responses. Other 4xx responses, disqualified or
+// oversized resources, redirect problems and cancellation are permanent.
+func fetchRetryable(err error) bool {
+	var statusErr StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code == http.StatusTooManyRequests || statusErr.Code >= 500
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrorDisqualified) || errors.Is(err, ErrorBodyTooLarge) ||
+		errors.Is(err, ErrorTooManyRedirects) || errors.Is(err, ErrorRedirectLoop) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// fetchWithRetry fetches a URL, retrying transient failures with exponential backoff
+// as configured by cfg.FetchRetry. A Retry-After header lengthens the wait, up to the
+// policy's maximum delay. The host's fetch slot is released while waiting.
+func (c *Crawler) fetchWithRetry(url string) (Response, error) {
+	policy := c.cfg.FetchRetry
+	attempts := max(policy.MaxAttempts, 1)
+	delay := policy.BaseDelay
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.fetch(url)
+		if err == nil || attempt >= attempts || !fetchRetryable(err) || c.ctx.Err() != nil {
+			return resp, err
+		}
+
+		wait := delay
+		var statusErr StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > wait {
+			wait = statusErr.RetryAfter
+		}
+		if policy.MaxDelay > 0 {
+			wait = min(wait, policy.MaxDelay)
+		}
+		c.logger.Warn("Retrying fetch after transient error", "url", url, "attempt", attempt, "maxAttempts", attempts, "delay", wait, "error", err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-c.ctx.Done():
+			timer.Stop()
+			return Response{}, c.ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,248 @@
+// Package crawler contains robots.txt fetching, parsing and caching for the web crawler.
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jdpolicano/go-search/internal/store"
+	"golang.org/x/sync/singleflight"
+)
+
+// maxRobotsSize is how much of a robots.txt is read; RFC 9309 requires parsing at least 500 KiB.
+const maxRobotsSize = 500 << 10
+
+// robotsRule is a single Allow or Disallow path pattern.
+type robotsRule struct {
+	pattern string // Path pattern, possibly with '*' wildcards and a trailing '$' anchor
+	allow   bool   // Whether the rule allows rather than disallows matching paths
+}
+
+// robotsRules are the rules of the robots.txt group that applies to our user agent.
+// The zero value allows everything.
+type robotsRules struct {
+	rules      []robotsRule  // Allow and Disallow rules in file order
+	crawlDelay time.Duration // Crawl-delay directive; 0 if absent
+}
+
+// allowAll is used for hosts without a usable robots.txt.
+var allowAll = robotsRules{}
+
+// Allowed reports whether a path (with its query) may be crawled. The longest matching
+// rule wins, Allow winning ties, and a path no rule matches is allowed.
+func (r robotsRules) Allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > longest || (n == longest && rule.allow) {
+			allowed, longest = rule.allow, n
+		}
+	}
+	return allowed
+}
+
+// robotsMatch reports whether path matches a robots.txt pattern, where '*' matches any
+// run of characters and a trailing '$' anchors the pattern to the end of the path.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	parts := strings.Split(strings.TrimSuffix(pattern, "$"), "*")
+
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	if len(parts) == 1 {
+		return !anchored || rest == ""
+	}
+	// Match the middle parts leftmost-first, leaving as much as possible for the last
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	last := parts[len(parts)-1]
+	if anchored {
+		return strings.HasSuffix(rest, last)
+	}
+	return strings.Contains(rest, last)
+}
+
+// parseRobots parses a robots.txt, keeping the group for agent (a product token such as
+// "MyGoScraper", matched case-insensitively) or, if there is none, the "*" group.
+// Consecutive User-agent lines share the rules that follow them.
+func parseRobots(r io.Reader, agent string) robotsRules {
+	agent = strings.ToLower(agent)
+
+	var specific, wildcard robotsRules
+	var foundSpecific bool
+	var inSpecific, inWildcard, inRules bool
+
+	scanner := bufio.NewScanner(io.LimitReader(r, maxRobotsSize))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// A User-agent line after rules starts a new group
+			if inRules {
+				inSpecific, inWildcard, inRules = false, false, false
+			}
+			name := strings.ToLower(value)
+			if name == "*" {
+				inWildcard = true
+			} else if name == agent {
+				inSpecific, foundSpecific = true, true
+			}
+		case "allow", "disallow":
+			inRules = true
+			// An empty Disallow allows everything, which is the default anyway
+			if value == "" {
+				continue
+			}
+			rule := robotsRule{pattern: value, allow: key == "allow"}
+			if inSpecific {
+				specific.rules = append(specific.rules, rule)
+			}
+			if inWildcard {
+				wildcard.rules = append(wildcard.rules, rule)
+			}
+		case "crawl-delay":
+			inRules = true
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			delay := time.Duration(seconds * float64(time.Second))
+			if inSpecific {
+				specific.crawlDelay = delay
+			}
+			if inWildcard {
+				wildcard.crawlDelay = delay
+			}
+		}
+	}
+
+	if foundSpecific {
+		return specific
+	}
+	return wildcard
+}
+
+// robotsEntry is a cached robots.txt.
+type robotsEntry struct {
+	rules   robotsRules // Parsed rules for our user agent
+	expires time.Time   // When the entry must be fetched again
+}
+
+// RobotsCache fetches, parses and caches robots.txt per origin (scheme and host), so
+// the crawler can skip disallowed URLs and honor Crawl-delay. Hosts whose robots.txt
+// can't be fetched, including 404 and 500 responses, are treated as allowing
+// everything. A nil RobotsCache allows everything.
+type RobotsCache struct {
+	fetcher Fetcher                // Fetches robots.txt files
+	agent   string                 // Product token our rules are selected by
+	ttl     time.Duration          // How long a fetched robots.txt is trusted
+	mu      sync.Mutex             // Guards entries
+	entries map[string]robotsEntry // Cached rules by origin
+	group   singleflight.Group     // Collapses concurrent fetches of one origin
+}
+
+// NewRobotsCache creates a RobotsCache fetching with fetcher and selecting the rules
+// for the product token of userAgent, e.g. "MyGoScraper" for "MyGoScraper/1.0 (...)".
+// Entries expire after ttl; a ttl of 0 never expires them.
+func NewRobotsCache(fetcher Fetcher, userAgent string, ttl time.Duration) *RobotsCache {
+	agent, _, _ := strings.Cut(userAgent, "/")
+	agent, _, _ = strings.Cut(agent, " ")
+	return &RobotsCache{fetcher: fetcher, agent: agent, ttl: ttl, entries: make(map[string]robotsEntry)}
+}
+
+// Allowed reports whether rawURL may be crawled, and the Crawl-delay its host asks for.
+func (c *RobotsCache) Allowed(ctx context.Context, rawURL string) (bool, time.Duration, error) {
+	if c == nil {
+		return true, 0, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false, 0, err
+	}
+
+	rules, err := c.rulesFor(ctx, u.Scheme+"://"+u.Host)
+	if err != nil {
+		return false, 0, err
+	}
+	path := u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return rules.Allowed(path), rules.crawlDelay, nil
+}
+
+// allowedByRobots reports whether rawURL may be crawled under robots, passing its
+// host's Crawl-delay to limiter. A nil robots allows everything.
+func allowedByRobots(ctx context.Context, robots *RobotsCache, limiter *hostLimiter, rawURL string) (bool, error) {
+	allowed, crawlDelay, err := robots.Allowed(ctx, rawURL)
+	if err != nil {
+		return false, err
+	}
+	if host, err := store.GetHostame(rawURL); err == nil && robots != nil {
+		limiter.SetCrawlDelay(host, crawlDelay)
+	}
+	return allowed, nil
+}
+
+// rulesFor returns the cached rules for an origin, fetching them if missing or expired.
+func (c *RobotsCache) rulesFor(ctx context.Context, origin string) (robotsRules, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[origin]
+	c.mu.Unlock()
+	if ok && (c.ttl <= 0 || time.Now().Before(entry.expires)) {
+		return entry.rules, nil
+	}
+
+	v, err, _ := c.group.Do(origin, func() (any, error) {
+		rules, err := c.fetch(ctx, origin)
+		if err != nil {
+			return robotsRules{}, err
+		}
+		c.mu.Lock()
+		c.entries[origin] = robotsEntry{rules, time.Now().Add(c.ttl)}
+		c.mu.Unlock()
+		return rules, nil
+	})
+	return v.(robotsRules), err
+}
+
+// fetch retrieves and parses an origin's robots.txt. Only cancellation is an error:
+// any failure to fetch the file means there are no rules to follow.
+func (c *RobotsCache) fetch(ctx context.Context, origin string) (robotsRules, error) {
+	resp, err := c.fetcher.Fetch(ctx, origin+"/robots.txt")
+	if err != nil {
+		if ctx.Err() != nil {
+			return robotsRules{}, ctx.Err()
+		}
+		return allowAll, nil
+	}
+	if closer, ok := resp.Body.(io.Closer); ok {
+		defer closer.Close()
+	}
+	return parseRobots(resp.Body, c.agent), nil
+}
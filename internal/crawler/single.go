@@ -0,0 +1,47 @@
+// Package crawler provides one-off fetching and extraction of a single page.
+package crawler
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/jdpolicano/go-search/internal/extract"
+	"github.com/jdpolicano/go-search/internal/extract/language"
+)
+
+// Page is a single fetched and extracted page.
+type Page struct {
+	extract.Extracted
+	Url    string      // URL the content was fetched from, after any redirects
+	Header http.Header // Response headers
+}
+
+// FetchAndExtract fetches a single URL and extracts it as the crawler would with
+// DefaultCrawlerConfig, without touching the frontier or the index. It is meant for
+// debugging extraction on a specific page and for embedding.
+func FetchAndExtract(ctx context.Context, url string, langs []language.Language) (Page, error) {
+	return FetchAndExtractWith(ctx, DefaultCrawlerConfig(), url, langs)
+}
+
+// FetchAndExtractWith is FetchAndExtract using cfg's fetcher and extraction settings.
+func FetchAndExtractWith(ctx context.Context, cfg CrawlerConfig, url string, langs []language.Language) (Page, error) {
+	resp, err := cfg.fetcher().Fetch(ctx, url)
+	if err != nil {
+		return Page{}, err
+	}
+	if closer, ok := resp.Body.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	parser := extract.NewHtmlParser(langs)
+	pageURL := resp.Url
+	if pageURL == "" {
+		pageURL = url
+	}
+	extracted, err := extractPage(parser, cfg, pageURL, resp.Body, resp.Header)
+	if err != nil {
+		return Page{}, err
+	}
+	return Page{extracted, resp.Url, resp.Header}, nil
+}
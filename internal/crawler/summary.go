@@ -0,0 +1,166 @@
+// Package crawler contains the counters behind the crawl completion summary.
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jdpolicano/go-search/internal/store"
+)
+
+// Skip reasons counted in the crawl summary for pages deliberately not indexed.
+const (
+	skipBudget           = "domain_budget"
+	skipThin             = "thin_document"
+	skipRefreshStub      = "refresh_stub"
+	skipRobots           = "robots_txt"
+	skipDuplicate        = "duplicate_content"
+	skipRedirectFiltered = "redirect_filtered"
+)
+
+// CrawlSummary reports what a crawl run did, for auditing and comparing runs.
+type CrawlSummary struct {
+	Fetched  int64                   // Pages fetched successfully
+	Indexed  int64                   // Pages committed to the index
+	Skipped  map[string]int64        // Pages deliberately not fetched or indexed, by reason
+	Failed   map[string]int64        // Pages that failed, by failure reason
+	Bytes    int64                   // Response body bytes read
+	Domains  int                     // Unique hosts fetched from
+	Duration time.Duration           // Wall-clock time since the crawl was set up
+	Writes   store.WriteLimiterStats // Index write throttling at the time of the summary
+}
+
+// LogAttrs returns the summary as slog key-value pairs.
+func (cs CrawlSummary) LogAttrs() []any {
+	return []any{
+		"fetched", cs.Fetched,
+		"indexed", cs.Indexed,
+		"skipped", cs.Skipped,
+		"failed", cs.Failed,
+		"bytes", cs.Bytes,
+		"domains", cs.Domains,
+		"duration", cs.Duration,
+		"write_limit", cs.Writes.Limit,
+		"write_contended", cs.Writes.Contended,
+		"write_waits", cs.Writes.Waits,
+	}
+}
+
+// WriteTable writes the summary to w as a human readable table.
+func (cs CrawlSummary) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "duration\t%s\n", cs.Duration.Round(time.Second))
+	fmt.Fprintf(tw, "fetched\t%d\n", cs.Fetched)
+	fmt.Fprintf(tw, "indexed\t%d\n", cs.Indexed)
+	fmt.Fprintf(tw, "bytes downloaded\t%d\n", cs.Bytes)
+	fmt.Fprintf(tw, "unique domains\t%d\n", cs.Domains)
+	fmt.Fprintf(tw, "write limit\t%d/%d\n", cs.Writes.Limit, cs.Writes.Max)
+	fmt.Fprintf(tw, "write contention\t%d\n", cs.Writes.Contended)
+	fmt.Fprintf(tw, "shared write waits\t%d\n", cs.Writes.Waits)
+	writeReasons(tw, "skipped", cs.Skipped)
+	writeReasons(tw, "failed", cs.Failed)
+	return tw.Flush()
+}
+
+// writeReasons writes a total row followed by one indented row per reason, in name order.
+func writeReasons(w io.Writer, label string, counts map[string]int64) {
+	var total int64
+	for _, n := range counts {
+		total += n
+	}
+	fmt.Fprintf(w, "%s\t%d\n", label, total)
+	for _, reason := range slices.Sorted(maps.Keys(counts)) {
+		fmt.Fprintf(w, "  %s\t%d\n", reason, counts[reason])
+	}
+}
+
+// crawlStats accumulates a CrawlSummary as the pipeline runs. It is shared by the
+// crawler, processor and index, so every method is safe for concurrent use.
+type crawlStats struct {
+	start   time.Time
+	fetched atomic.Int64
+	indexed atomic.Int64
+	bytes   atomic.Int64
+	mu      sync.Mutex // Guards the maps below
+	skipped map[string]int64
+	failed  map[string]int64
+	domains map[string]struct{}
+}
+
+// newCrawlStats creates an empty crawlStats starting its clock now.
+func newCrawlStats() *crawlStats {
+	return &crawlStats{
+		start:   time.Now(),
+		skipped: make(map[string]int64),
+		failed:  make(map[string]int64),
+		domains: make(map[string]struct{}),
+	}
+}
+
+// recordFetch counts a successful fetch from host.
+func (s *crawlStats) recordFetch(host string) {
+	s.fetched.Add(1)
+	s.mu.Lock()
+	s.domains[host] = struct{}{}
+	s.mu.Unlock()
+}
+
+// recordSkip counts a page skipped for reason.
+func (s *crawlStats) recordSkip(reason string) {
+	s.mu.Lock()
+	s.skipped[reason]++
+	s.mu.Unlock()
+}
+
+// recordFailure counts a page failed for reason.
+func (s *crawlStats) recordFailure(reason string) {
+	s.mu.Lock()
+	s.failed[reason]++
+	s.mu.Unlock()
+}
+
+// countReader wraps a response body so the bytes read from it are counted.
+func (s *crawlStats) countReader(r io.Reader) io.Reader {
+	return &countingReader{r, &s.bytes}
+}
+
+// summary returns a snapshot of the counters.
+func (s *crawlStats) summary() CrawlSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return CrawlSummary{
+		Fetched:  s.fetched.Load(),
+		Indexed:  s.indexed.Load(),
+		Skipped:  maps.Clone(s.skipped),
+		Failed:   maps.Clone(s.failed),
+		Bytes:    s.bytes.Load(),
+		Domains:  len(s.domains),
+		Duration: time.Since(s.start),
+	}
+}
+
+// countingReader adds the bytes read through it to n. It forwards Close so the
+// wrapped response body is still released.
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+func (c *countingReader) Close() error {
+	if closer, ok := c.r.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -17,41 +17,84 @@ type CrawlQueue struct {
 	in     chan []store.FrontierItem       // Input channel for new URLs (BFS queue)
 	out    chan CrawlerMessage             // Output channel for URLs to crawl
 	wg     *sync.WaitGroup                 // WaitGroup for goroutine management
+	hooks  *Hooks                          // Optional pipeline observation hooks
 	ctx    context.Context                 // Context for cancellation
 	cancel context.CancelFunc              // Cancel function for stopping the queue
+	stop   chan struct{}                   // Closed by Close to stop dispatching URLs
+	once   sync.Once                       // Closes stop exactly once
 	logger *slog.Logger                    // Structured logger
 }
 
 // NewCrawlQueue creates a new CrawlQueue instance with the given configuration.
-func NewCrawlQueue(ctx context.Context, cancel context.CancelFunc, q queue.Queue[store.FrontierItem], wg *sync.WaitGroup, logger *slog.Logger) *CrawlQueue {
+func NewCrawlQueue(ctx context.Context, cancel context.CancelFunc, q queue.Queue[store.FrontierItem], hooks *Hooks, wg *sync.WaitGroup, logger *slog.Logger) *CrawlQueue {
 	in, out := make(chan []store.FrontierItem), make(chan CrawlerMessage)
-	return &CrawlQueue{q, in, out, wg, ctx, cancel, logger}
+	return &CrawlQueue{q, in, out, wg, hooks, ctx, cancel, make(chan struct{}), sync.Once{}, logger}
 }
 
 // Run starts the crawl queue's main loop, managing URL dequeuing and enqueuing.
+// When it stops dispatching, because of Close, an error or an empty frontier at
+// start, it closes its output so the crawler drains, then keeps saving the links of
+// pages still in flight to the frontier until the processor closes its input.
 func (cq *CrawlQueue) Run() {
 	defer cq.wg.Done()
+	defer cq.closeQueue()
+
+	inClosed := cq.dispatch()
+	close(cq.out)
+	if !inClosed {
+		cq.drain()
+	}
+}
+
+// dispatch sends URLs to the crawler and enqueues discovered links until the queue
+// is stopped, canceled or fails. It reports whether the input channel was closed.
+func (cq *CrawlQueue) dispatch() bool {
 	if l, err := cq.queue.Len(); err != nil || l == 0 {
-		return
+		return false
 	}
 
+	// A dequeued item waits here until the crawler takes it, so it isn't lost when
+	// new links arrive first
+	var pending chan CrawlerMessage
+	var top CrawlerMessage
 	for {
-		activeOut, top, err := cq.prepareNextMessage()
-
-		if err != nil {
-			break
+		if pending == nil {
+			var err error
+			if pending, top, err = cq.prepareNextMessage(); err != nil {
+				return false
+			}
 		}
 
 		select {
 		case <-cq.ctx.Done():
 			cq.logger.Info("CrawlQueue work canceled, returning")
-			return
-		case activeOut <- top:
+			return false
+		case <-cq.stop:
+			cq.logger.Info("CrawlQueue stopped, no longer dispatching urls")
+			return false
+		case pending <- top:
 			cq.handleOutgoingMessage(top)
+			pending = nil
+		case items, ok := <-cq.in:
+			if !ok {
+				cq.handleInputChannelClosed()
+				return true
+			}
+			cq.enqueueItems(items)
+		}
+	}
+}
+
+// drain enqueues links sent by the processor until it closes the input channel or the
+// queue is canceled, so pages crawled during shutdown still contribute their links.
+func (cq *CrawlQueue) drain() {
+	for {
+		select {
+		case <-cq.ctx.Done():
+			return
 		case items, ok := <-cq.in:
 			if !ok {
 				cq.handleInputChannelClosed()
-				cq.cancel()
 				return
 			}
 			cq.enqueueItems(items)
@@ -90,25 +133,41 @@ func (cq *CrawlQueue) handleInputChannelClosed() {
 	}
 }
 
-// enqueueItems adds multiple frontier items to the queue, handling unique violations.
+// enqueueItems adds multiple frontier items to the queue. Already known URLs are
+// skipped by the store, so a failure here is a real error for that item only.
 func (cq *CrawlQueue) enqueueItems(items []store.FrontierItem) {
+	dropped := 0
+	defer func() {
+		if dropped > 0 {
+			cq.logger.Warn("Frontier full, dropping new urls", "dropped", dropped)
+		}
+	}()
+
 	for _, item := range items {
 		err := cq.queue.Enqueue(item)
+		if err == queue.ErrorFrontierFull {
+			dropped++
+			continue
+		}
 		if err != nil {
-			if !store.ErrorIsUniqueViolation(err) {
-				cq.logger.Error("Error enqueueing url", "url", item.Url, "error", err)
-			}
+			cq.logger.Error("Error enqueueing url", "url", item.Url, "error", err)
 			continue
 		}
+		cq.hooks.enqueued(item)
 	}
 }
 
-// Close gracefully shuts down the crawl queue by closing the underlying queue and channels.
+// Close gracefully shuts down the crawl queue: it stops dispatching URLs, which
+// cascades through the pipeline as each stage closes its output after its input.
+// Close is safe to call more than once.
 func (cq *CrawlQueue) Close() {
 	cq.logger.Info("Closing UrlQueue")
+	cq.once.Do(func() { close(cq.stop) })
+}
+
+// closeQueue closes the underlying queue once Run is done with it.
+func (cq *CrawlQueue) closeQueue() {
 	if err := cq.queue.Close(); err != nil {
 		cq.logger.Error("Error closing queue", "error", err)
 	}
-	close(cq.out)
-	cq.wg.Done()
 }
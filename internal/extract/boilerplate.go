@@ -0,0 +1,91 @@
+// Package extract provides skipping of page chrome such as navigation and footers.
+package extract
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DefaultBoilerplateTags returns the elements skipped by default when
+// Options.SkipBoilerplate is set.
+func DefaultBoilerplateTags() []string {
+	return []string{"nav", "header", "footer"}
+}
+
+// DefaultBoilerplateRoles returns the ARIA roles skipped by default when
+// Options.SkipBoilerplate is set.
+func DefaultBoilerplateRoles() []string {
+	return []string{"navigation", "banner", "contentinfo"}
+}
+
+// boilerplateFilter decides which text is page chrome rather than content. A nil
+// filter skips nothing.
+type boilerplateFilter struct {
+	tags  map[string]struct{} // Lowercase element names whose text is skipped
+	roles map[string]struct{} // Lowercase role values whose text is skipped
+}
+
+// newBoilerplateFilter builds the filter opts describe, or nil if boilerplate isn't skipped.
+func newBoilerplateFilter(opts Options) *boilerplateFilter {
+	if !opts.SkipBoilerplate {
+		return nil
+	}
+	f := &boilerplateFilter{
+		tags:  make(map[string]struct{}, len(opts.BoilerplateTags)),
+		roles: make(map[string]struct{}, len(opts.BoilerplateRoles)),
+	}
+	for _, tag := range opts.BoilerplateTags {
+		f.tags[strings.ToLower(tag)] = struct{}{}
+	}
+	for _, role := range opts.BoilerplateRoles {
+		f.roles[strings.ToLower(role)] = struct{}{}
+	}
+	return f
+}
+
+// isBoilerplateElement reports whether an element is itself page chrome, by name or role.
+func (f *boilerplateFilter) isBoilerplateElement(node *html.Node) bool {
+	if node.Type != html.ElementNode {
+		return false
+	}
+	if _, ok := f.tags[node.Data]; ok {
+		return true
+	}
+	for _, attr := range node.Attr {
+		if attr.Key != "role" {
+			continue
+		}
+		// role may list fallbacks, e.g. role="navigation menu"
+		for _, role := range strings.Fields(strings.ToLower(attr.Val)) {
+			if _, ok := f.roles[role]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// skipsText reports whether a text node sits inside page chrome. Chrome inside
+// <main> or <article>, such as an article's own header, is content and is kept.
+func (f *boilerplateFilter) skipsText(node *html.Node) bool {
+	if f == nil {
+		return false
+	}
+	skip := false
+	for n := node.Parent; n != nil; n = n.Parent {
+		if isContentRoot(n.DataAtom) {
+			return false
+		}
+		if !skip && f.isBoilerplateElement(n) {
+			skip = true
+		}
+	}
+	return skip
+}
+
+// isContentRoot reports whether an element holds a page's main content.
+func isContentRoot(a atom.Atom) bool {
+	return a == atom.Main || a == atom.Article
+}
@@ -0,0 +1,51 @@
+// Package extract provides the content hash algorithms used for duplicate detection.
+package extract
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// HashAlgorithm names the algorithm used to hash a document's words for deduplication.
+type HashAlgorithm string
+
+const (
+	HashSHA256 HashAlgorithm = "sha256" // Default; matches hashes stored by earlier crawls
+	HashSHA1   HashAlgorithm = "sha1"   // Cheaper cryptographic hash
+	HashXXHash HashAlgorithm = "xxhash" // Fast non-cryptographic hash, plenty for dedup
+)
+
+// Validate reports an error if the algorithm is unknown. The empty algorithm is SHA256.
+func (a HashAlgorithm) Validate() error {
+	_, err := a.newHash()
+	return err
+}
+
+// newHash returns a fresh hash.Hash for the algorithm, or an error if it is unknown.
+func (a HashAlgorithm) newHash() (hash.Hash, error) {
+	switch a {
+	case HashSHA256, "":
+		return sha256.New(), nil
+	case HashSHA1:
+		return sha1.New(), nil
+	case HashXXHash:
+		return xxhash.New(), nil
+	}
+	return nil, fmt.Errorf("unknown hash algorithm %q", string(a))
+}
+
+// encode formats a digest for storage. Digests other than SHA256 are prefixed with
+// their algorithm, so hashes from crawls with different settings never compare equal
+// while existing SHA256 hashes stay valid.
+func (a HashAlgorithm) encode(sum []byte) string {
+	digest := hex.EncodeToString(sum)
+	if a == HashSHA256 || a == "" {
+		return digest
+	}
+	return string(a) + ":" + digest
+}
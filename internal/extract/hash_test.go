@@ -0,0 +1,47 @@
+package extract
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jdpolicano/go-search/internal/extract/language"
+)
+
+// hashAlgorithms are the content hash algorithms compared by the benchmarks.
+var hashAlgorithms = []HashAlgorithm{HashSHA256, HashSHA1, HashXXHash}
+
+func BenchmarkHashTextWith(b *testing.B) {
+	text := strings.Repeat("the crawler fetches pages and the index stores their words ", 20000)
+	for _, algo := range hashAlgorithms {
+		b.Run(string(algo), func(b *testing.B) {
+			b.SetBytes(int64(len(text)))
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := HashTextWith(text, algo); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkProcessHtmlDocumentHash measures full extraction of a large document with
+// each hash algorithm, to show how much of it the content hash costs.
+func BenchmarkProcessHtmlDocumentHash(b *testing.B) {
+	doc, err := NewHtmlParser([]language.Language{language.English}).Parse(strings.NewReader(largePage(5000)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, algo := range hashAlgorithms {
+		b.Run(string(algo), func(b *testing.B) {
+			opts := DefaultOptions()
+			opts.Hash = algo
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := ProcessHtmlDocumentWithOptions(doc, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// Package extract provides term match positions for result highlighting.
+package extract
+
+import "unicode/utf8"
+
+// Range is a half-open [Start, End) span of rune offsets into a text.
+// Rune offsets, rather than byte offsets, index directly into the characters a
+// frontend renders.
+type Range struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// HighlightRanges returns the spans of text whose words tokenize to one of terms,
+// in order. Each word is normalized by the same scanner used for documents and
+// queries, so a span matches exactly when the indexed term would.
+func HighlightRanges(text string, terms []string) []Range {
+	want := make(map[string]struct{}, len(terms))
+	for _, term := range terms {
+		want[term] = struct{}{}
+	}
+
+	var ranges []Range
+	numbers := keepNumbers.Load()
+	runeIdx, byteIdx := 0, 0
+	for byteIdx < len(text) {
+		start, end := nextWordSpan(text, byteIdx, numbers)
+		if start < 0 {
+			break
+		}
+		wordStart := runeIdx + utf8.RuneCountInString(text[byteIdx:start])
+		runeIdx = wordStart + utf8.RuneCountInString(text[start:end])
+		byteIdx = end
+
+		words, err := ScanWordsFromString(text[start:end])
+		if err == nil && len(words) == 1 {
+			if _, ok := want[words[0]]; ok {
+				ranges = append(ranges, Range{wordStart, runeIdx})
+			}
+		}
+	}
+
+	return ranges
+}
@@ -2,6 +2,7 @@
 package extract
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"slices"
@@ -15,6 +16,27 @@ import (
 // ErrorNotSupportedLanguage is returned when a document's language is not supported.
 var ErrorNotSupportedLanguage = errors.New("Language is not supported")
 
+// ErrorEmptyDocument is returned when a document's body is empty or only whitespace.
+var ErrorEmptyDocument = errors.New("document is empty")
+
+// ErrorNoContent is returned for a document with neither visible text nor links,
+// such as a page rendered entirely by JavaScript.
+var ErrorNoContent = errors.New("document has no content")
+
+// contentReader wraps a reader, recording whether anything but whitespace was read.
+type contentReader struct {
+	r          io.Reader
+	hasContent bool
+}
+
+func (cr *contentReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	if !cr.hasContent && len(bytes.TrimSpace(p[:n])) > 0 {
+		cr.hasContent = true
+	}
+	return n, err
+}
+
 // HtmlParser parses HTML documents and validates language support.
 type HtmlParser struct {
 	langs []language.Language // Supported languages for content extraction
@@ -26,22 +48,66 @@ func NewHtmlParser(langs []language.Language) *HtmlParser {
 }
 
 // Parse parses an HTML document from the given reader and validates language support.
+// It returns ErrorEmptyDocument for an empty body and ErrorNotSupportedLanguage for
+// a document declared in a language that isn't supported.
 func (p *HtmlParser) Parse(reader io.Reader) (*html.Node, error) {
-	doc, parseErr := html.Parse(reader)
+	return p.ParseWithContentLanguage(reader, "")
+}
+
+// ParseWithContentLanguage is Parse for a document served with the given Content-Language
+// header value. A header naming a recognized language takes precedence over the <html>
+// lang attribute, since it is set by the server rather than copied from a template;
+// otherwise the attribute decides. For a document with neither, the language is
+// detected from a sample of its visible text, and the document is rejected only if
+// it is confidently detected to be in an unsupported language.
+func (p *HtmlParser) ParseWithContentLanguage(reader io.Reader, contentLanguage string) (*html.Node, error) {
+	cr := &contentReader{r: reader}
+	doc, parseErr := html.Parse(cr)
 	if parseErr != nil {
 		return nil, parseErr
 	}
 
-	if !p.isSupportedLanguageNode(doc) {
+	if !cr.hasContent {
+		return nil, ErrorEmptyDocument
+	}
+
+	if supported, known := p.isSupportedContentLanguage(contentLanguage); known {
+		if !supported {
+			return nil, ErrorNotSupportedLanguage
+		}
+	} else if supported, declared := p.isSupportedLanguageNode(doc); declared {
+		if !supported {
+			return nil, ErrorNotSupportedLanguage
+		}
+	} else if !p.isSupportedDetectedLanguage(visibleTextSample(doc)) {
 		return nil, ErrorNotSupportedLanguage
 	}
 
 	return doc, nil
 }
 
+// isSupportedContentLanguage checks a Content-Language header value, which may list
+// several language tags (e.g. "en-US, fr"). The document is supported if any
+// recognized language is. known is false when no tag names a recognized language,
+// in which case the header gives no answer either way.
+func (p *HtmlParser) isSupportedContentLanguage(contentLanguage string) (supported, known bool) {
+	for _, tag := range strings.Split(contentLanguage, ",") {
+		lang, ok := language.FromCode(primarySubtag(tag))
+		if !ok {
+			continue
+		}
+		known = true
+		if slices.Contains(p.langs, lang) {
+			return true, true
+		}
+	}
+	return false, known
+}
+
 // isSupportedLanguageNode checks the html tag for a "lang" attribute and validates language support.
-// The default is to return true, so this does not guarantee that the doc is in a supported language.
-func (p *HtmlParser) isSupportedLanguageNode(node *html.Node) bool {
+// declared is false when there is no lang attribute, in which case supported gives no answer and
+// the language has to be detected from the text instead.
+func (p *HtmlParser) isSupportedLanguageNode(node *html.Node) (supported, declared bool) {
 	var htmlTagNode *html.Node = nil
 
 	// Find the HTML tag node
@@ -57,35 +123,87 @@ func (p *HtmlParser) isSupportedLanguageNode(node *html.Node) bool {
 	}
 
 	if htmlTagNode == nil {
-		// We can't determine language support yet.
-		// Future enhancement: use natural language processing to detect language from text content.
-		return true
+		return false, false
 	}
 
 	// Check for lang attribute and validate against supported languages
 	for _, attr := range htmlTagNode.Attr {
 		if attr.Key == "lang" {
-			// ISO 639-1 - two letter language codes
-			if len(attr.Val) == 2 {
-				isoCode639_1 := language.GetIsoCode639_1FromValue(attr.Val)
-				attrLang := language.GetLanguageFromIsoCode639_1(isoCode639_1)
-				return slices.Contains(p.langs, attrLang)
+			// Match on the ISO 639-1 or 639-3 primary subtag, e.g. "en" of "en-US"
+			attrLang, ok := language.FromCode(primarySubtag(attr.Val))
+			if !ok {
+				// Lang attribute exists but we don't recognize it, so deny the document.
+				return false, true
 			}
+			return slices.Contains(p.langs, attrLang), true
+		}
+	}
 
-			// ISO 639-3 - three letter language codes
-			if len(attr.Val) == 3 {
-				isoCode639_3 := language.GetIsoCode639_3FromValue(attr.Val)
-				attrLang := language.GetLanguageFromIsoCode639_3(isoCode639_3)
-				return slices.Contains(p.langs, attrLang)
-			}
+	return false, false
+}
 
-			// Lang attribute exists but we don't recognize it.
-			// Future enhancement: use NLP to detect language, but for now deny the document.
-			return false
-		}
+// primarySubtag returns the primary language subtag of a language tag such as
+// "en-US" or "pt_BR". FromCode matches it case-insensitively.
+func primarySubtag(tag string) string {
+	primary, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	primary, _, _ = strings.Cut(primary, "_")
+	return primary
+}
+
+// isSupportedDetectedLanguage detects the language of a sample of a document's text.
+// A document is only rejected when its language is detected with confidence and
+// isn't supported; when detection gives no answer it is allowed, as before detection.
+func (p *HtmlParser) isSupportedDetectedLanguage(sample string) bool {
+	lang, ok := language.Detect(sample)
+	return !ok || slices.Contains(p.langs, lang)
+}
+
+// languageSampleBytes caps how much visible text is sampled for language detection.
+// A few kilobytes is plenty for stop word ratios and keeps detection cheap on large pages.
+const languageSampleBytes = 4096
+
+// languageSample collects up to languageSampleBytes of a document's visible text.
+type languageSample struct {
+	text strings.Builder
+}
+
+// addText appends visible text to the sample, ignoring it once the sample is full.
+func (ls *languageSample) addText(text string) {
+	if ls.full() {
+		return
 	}
+	if ls.text.Len() > 0 {
+		ls.text.WriteByte(' ')
+	}
+	ls.text.WriteString(text)
+}
 
-	return true // Default to true when no lang attribute is found
+// full reports whether the sample has reached languageSampleBytes.
+func (ls *languageSample) full() bool {
+	return ls.text.Len() >= languageSampleBytes
+}
+
+// String returns the sampled text.
+func (ls *languageSample) String() string {
+	return ls.text.String()
+}
+
+// errSampleFull stops the tree walk in visibleTextSample once enough text is collected.
+var errSampleFull = errors.New("language sample full")
+
+// visibleTextSample returns the start of a document's visible text for language detection.
+func visibleTextSample(root *html.Node) string {
+	var sample languageSample
+	DfsNodes(root, func(node *html.Node) error {
+		if isVisibleText(node) {
+			sample.addText(normalizeText(node.Data))
+		}
+		if sample.full() {
+			return errSampleFull
+		}
+		return nil
+	})
+	return sample.String()
 }
 
 // isATag checks if a node is an HTML anchor (<a>) tag.
@@ -118,6 +236,14 @@ func isVisibleText(n *html.Node) bool {
 	return true
 }
 
+// normalizeText prepares decoded text node content for extraction. The HTML parser
+// and tokenizer already decode entities such as &amp; and &#39;, but &nbsp; decodes
+// to U+00A0, which is turned into a plain space so stored text and snippets don't
+// carry invisible non-breaking spaces between words.
+func normalizeText(data string) string {
+	return strings.ReplaceAll(data, "\u00a0", " ")
+}
+
 // DfsNodes performs a depth-first traversal of HTML nodes, calling the callback for each node.
 func DfsNodes(n *html.Node, cb func(node *html.Node) error) error {
 	if n == nil {
@@ -0,0 +1,80 @@
+package extract
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jdpolicano/go-search/internal/extract/language"
+	"golang.org/x/net/html"
+)
+
+func TestParseWithContentLanguage(t *testing.T) {
+	tests := []struct {
+		name            string
+		contentLanguage string
+		lang            string // <html lang>; empty for none
+		wantErr         error
+	}{
+		{"neither", "", "", nil},
+		{"header only supported", "en-US", "", nil},
+		{"header only unsupported", "fr", "", ErrorNotSupportedLanguage},
+		{"header lists a supported language", "fr, en", "", nil},
+		{"attribute only supported", "", "en", nil},
+		{"attribute only unsupported", "", "fr", ErrorNotSupportedLanguage},
+		{"header wins over unsupported attribute", "en", "fr", nil},
+		{"header wins over supported attribute", "de", "en", ErrorNotSupportedLanguage},
+		{"unrecognized header defers to attribute", "x-klingon", "fr", ErrorNotSupportedLanguage},
+		{"unrecognized header and supported attribute", "x-klingon", "en", nil},
+	}
+	parser := NewHtmlParser([]language.Language{language.English})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			open := "<html>"
+			if tt.lang != "" {
+				open = `<html lang="` + tt.lang + `">`
+			}
+			page := open + "<body><p>The weather in the city was warm and the streets were busy with people.</p></body></html>"
+
+			if _, err := parser.ParseWithContentLanguage(strings.NewReader(page), tt.contentLanguage); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseWithContentLanguage: err = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := parser.ProcessStream(strings.NewReader(page), tt.contentLanguage, DefaultOptions()); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ProcessStream: err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsSupportedLanguageNode(t *testing.T) {
+	tests := []struct {
+		name          string
+		langs         []language.Language
+		lang          string
+		wantSupported bool
+		wantDeclared  bool
+	}{
+		{"bare en", []language.Language{language.English}, "en", true, true},
+		{"en-GB", []language.Language{language.English}, "en-GB", true, true},
+		{"pt-BR supported", []language.Language{language.English, language.Portuguese}, "pt-BR", true, true},
+		{"pt-BR unsupported", []language.Language{language.English}, "pt-BR", false, true},
+		{"uppercase", []language.Language{language.English}, "EN", true, true},
+		{"mixed case region", []language.Language{language.English}, "En-gb", true, true},
+		{"underscore separator", []language.Language{language.Portuguese}, "pt_BR", true, true},
+		{"three letter code", []language.Language{language.English}, "eng", true, true},
+		{"surrounding whitespace", []language.Language{language.English}, " en-US ", true, true},
+		{"unrecognized", []language.Language{language.English}, "x-klingon", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader(`<html lang="` + tt.lang + `"><p>text</p></html>`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			supported, declared := NewHtmlParser(tt.langs).isSupportedLanguageNode(doc)
+			if supported != tt.wantSupported || declared != tt.wantDeclared {
+				t.Errorf("lang %q: supported %t, declared %t, want %t, %t", tt.lang, supported, declared, tt.wantSupported, tt.wantDeclared)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// Package language provides detection of a text's language from its script and stop words.
+package language
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Thresholds for a confident detection. They err towards no answer, since a wrong
+// guess rejects a document that would otherwise have been indexed.
+const (
+	minScriptLetters = 50  // Letters needed before the script decides the language
+	minScriptShare   = 0.6 // Share of letters the dominant script needs
+	minKanaShare     = 0.1 // Share of kana that marks Han text as Japanese rather than Chinese
+	minLatinWords    = 20  // Words needed before stop words decide a Latin-script language
+	minStopWordShare = 0.1 // Share of words that must be the winning language's stop words
+	minStopWordLead  = 1.5 // How many times more stop words the winner needs than the runner up
+)
+
+// latinStopWords are the most frequent words of each Latin-script language,
+// which make up a large share of any running text in that language.
+var latinStopWords = map[Language][]string{
+	English: {
+		"the", "and", "of", "to", "is", "in", "that", "it", "was", "for", "with", "as", "on",
+		"be", "at", "by", "this", "are", "have", "from", "or", "an", "not", "but", "which",
+		"you", "they", "were", "their", "has", "his", "her", "we", "will", "would", "can",
+		"been", "more", "there", "what", "about", "when",
+	},
+	French: {
+		"le", "la", "les", "de", "des", "du", "et", "est", "un", "une", "que", "qui", "dans",
+		"pour", "pas", "sur", "au", "aux", "avec", "ce", "cette", "il", "elle", "sont", "ne",
+		"se", "par", "plus", "nous", "vous", "mais", "ou", "été", "être", "leur", "comme",
+		"son", "sa", "ses",
+	},
+	German: {
+		"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "den", "von", "mit",
+		"sich", "des", "auf", "für", "im", "dem", "auch", "es", "an", "als", "wird", "sind",
+		"bei", "oder", "nach", "wie", "aus", "einer", "werden", "wurde", "hat", "dass", "sie",
+		"er", "ich", "noch", "nur", "über",
+	},
+	Spanish: {
+		"el", "la", "los", "las", "de", "del", "y", "que", "en", "un", "una", "es", "por",
+		"con", "para", "no", "se", "su", "sus", "al", "lo", "como", "más", "pero", "fue",
+		"este", "esta", "son", "ha", "muy", "también", "entre", "cuando", "sobre", "ya",
+		"porque", "desde", "hay",
+	},
+	Italian: {
+		"il", "lo", "la", "gli", "le", "di", "del", "della", "che", "e", "è", "un", "una",
+		"per", "non", "con", "sono", "si", "da", "in", "al", "alla", "dei", "delle", "nel",
+		"nella", "ma", "come", "anche", "più", "questo", "questa", "ha", "sul", "essere",
+		"suo", "loro",
+	},
+	Portuguese: {
+		"o", "a", "os", "as", "de", "do", "da", "dos", "das", "e", "que", "em", "um", "uma",
+		"para", "com", "não", "no", "na", "por", "se", "mais", "como", "mas", "foi", "ao",
+		"ele", "ela", "seu", "sua", "são", "está", "também", "pelo", "pela", "nos", "isso",
+		"muito",
+	},
+	Dutch: {
+		"de", "het", "een", "en", "van", "is", "dat", "niet", "op", "te", "zijn", "met",
+		"voor", "die", "er", "aan", "ook", "als", "bij", "maar", "om", "dan", "wordt", "door",
+		"nog", "naar", "uit", "heeft", "werd", "hij", "zij", "wij", "ze", "deze", "kan",
+		"tot", "geen", "over",
+	},
+}
+
+// stopWordLanguages maps each stop word to the languages it belongs to.
+var stopWordLanguages = func() map[string][]Language {
+	m := make(map[string][]Language)
+	for lang, words := range latinStopWords {
+		for _, word := range words {
+			m[word] = append(m[word], lang)
+		}
+	}
+	return m
+}()
+
+// Detect guesses the language of a sample of plain text. Text mostly in a script
+// used by a single known language, such as Greek or Hangul, is taken to be in that
+// language; Cyrillic is taken to be Russian. Latin-script text is matched against
+// each language's most frequent words. ok is false when the sample is too short or
+// too mixed for a confident answer.
+func Detect(text string) (lang Language, ok bool) {
+	scripts := make(map[Language]int)
+	latin, kana, letters := 0, 0, 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.Is(unicode.Cyrillic, r):
+			scripts[Russian]++
+		case unicode.Is(unicode.Greek, r):
+			scripts[Greek]++
+		case unicode.Is(unicode.Arabic, r):
+			scripts[Arabic]++
+		case unicode.Is(unicode.Hangul, r):
+			scripts[Korean]++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+			scripts[Japanese]++
+		case unicode.Is(unicode.Han, r):
+			scripts[Chinese]++
+		}
+	}
+	if letters < minScriptLetters {
+		return -1, false
+	}
+
+	// Japanese mixes kanji with kana, so count its Han characters towards it
+	if float64(kana) >= minKanaShare*float64(letters) {
+		scripts[Japanese] += scripts[Chinese]
+		delete(scripts, Chinese)
+	}
+
+	for script, n := range scripts {
+		if float64(n) >= minScriptShare*float64(letters) {
+			return script, true
+		}
+	}
+	if float64(latin) < minScriptShare*float64(letters) {
+		return -1, false
+	}
+	return detectLatin(text)
+}
+
+// detectLatin picks the language whose stop words make up the largest share of the
+// text's words, if that share is high enough and clearly ahead of the next language.
+func detectLatin(text string) (Language, bool) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if len(words) < minLatinWords {
+		return -1, false
+	}
+
+	hits := make(map[Language]int)
+	for _, word := range words {
+		for _, lang := range stopWordLanguages[word] {
+			hits[lang]++
+		}
+	}
+
+	var best Language = -1
+	bestHits, runnerUp := 0, 0
+	for lang, n := range hits {
+		switch {
+		case n > bestHits:
+			best, bestHits, runnerUp = lang, n, bestHits
+		case n > runnerUp:
+			runnerUp = n
+		}
+	}
+	if float64(bestHits) < minStopWordShare*float64(len(words)) ||
+		float64(bestHits) < minStopWordLead*float64(runnerUp) {
+		return -1, false
+	}
+	return best, true
+}
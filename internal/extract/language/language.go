@@ -1,12 +1,28 @@
 // Package language provides language enumeration and ISO code utilities for the search engine.
 package language
 
+import "strings"
+
 // Language represents supported languages for content processing.
 type Language int
 
-// Supported languages, English correlates with ISO codes EN and ENG.
+// Known languages, each correlating with the ISO codes of the same position below,
+// e.g. English with EN and ENG. Languages beyond English can be recognized and
+// detected, but stop words and stemming only suit English.
 const (
 	English Language = iota
+	French
+	German
+	Spanish
+	Italian
+	Portuguese
+	Dutch
+	Russian
+	Greek
+	Arabic
+	Chinese
+	Japanese
+	Korean
 )
 
 // IsoCode639_1 represents ISO 639-1 two-letter language codes.
@@ -15,6 +31,18 @@ type IsoCode639_1 int
 // ISO 639-1 language codes supported by the search engine.
 const (
 	EN IsoCode639_1 = iota // "en" - English
+	FR                     // "fr" - French
+	DE                     // "de" - German
+	ES                     // "es" - Spanish
+	IT                     // "it" - Italian
+	PT                     // "pt" - Portuguese
+	NL                     // "nl" - Dutch
+	RU                     // "ru" - Russian
+	EL                     // "el" - Greek
+	AR                     // "ar" - Arabic
+	ZH                     // "zh" - Chinese
+	JA                     // "ja" - Japanese
+	KO                     // "ko" - Korean
 )
 
 // IsoCode639_3 represents ISO 639-3 three-letter language codes.
@@ -23,6 +51,18 @@ type IsoCode639_3 int
 // ISO 639-3 language codes supported by the search engine.
 const (
 	ENG IsoCode639_3 = iota // "eng" - English
+	FRA                     // "fra" - French
+	DEU                     // "deu" - German
+	SPA                     // "spa" - Spanish
+	ITA                     // "ita" - Italian
+	POR                     // "por" - Portuguese
+	NLD                     // "nld" - Dutch
+	RUS                     // "rus" - Russian
+	ELL                     // "ell" - Greek
+	ARA                     // "ara" - Arabic
+	ZHO                     // "zho" - Chinese
+	JPN                     // "jpn" - Japanese
+	KOR                     // "kor" - Korean
 )
 
 // String returns the string representation of ISO 639-1 language codes.
@@ -30,6 +70,30 @@ func (iso1 IsoCode639_1) String() string {
 	switch iso1 {
 	case EN:
 		return "en"
+	case FR:
+		return "fr"
+	case DE:
+		return "de"
+	case ES:
+		return "es"
+	case IT:
+		return "it"
+	case PT:
+		return "pt"
+	case NL:
+		return "nl"
+	case RU:
+		return "ru"
+	case EL:
+		return "el"
+	case AR:
+		return "ar"
+	case ZH:
+		return "zh"
+	case JA:
+		return "ja"
+	case KO:
+		return "ko"
 	default:
 		return ""
 	}
@@ -40,6 +104,30 @@ func (iso3 IsoCode639_3) String() string {
 	switch iso3 {
 	case ENG:
 		return "eng"
+	case FRA:
+		return "fra"
+	case DEU:
+		return "deu"
+	case SPA:
+		return "spa"
+	case ITA:
+		return "ita"
+	case POR:
+		return "por"
+	case NLD:
+		return "nld"
+	case RUS:
+		return "rus"
+	case ELL:
+		return "ell"
+	case ARA:
+		return "ara"
+	case ZHO:
+		return "zho"
+	case JPN:
+		return "jpn"
+	case KOR:
+		return "kor"
 	default:
 		return ""
 	}
@@ -50,6 +138,30 @@ func GetLanguageFromIsoCode639_1(iso1 IsoCode639_1) Language {
 	switch iso1 {
 	case EN:
 		return English
+	case FR:
+		return French
+	case DE:
+		return German
+	case ES:
+		return Spanish
+	case IT:
+		return Italian
+	case PT:
+		return Portuguese
+	case NL:
+		return Dutch
+	case RU:
+		return Russian
+	case EL:
+		return Greek
+	case AR:
+		return Arabic
+	case ZH:
+		return Chinese
+	case JA:
+		return Japanese
+	case KO:
+		return Korean
 	default:
 		return -1
 	}
@@ -60,6 +172,30 @@ func GetLanguageFromIsoCode639_3(iso3 IsoCode639_3) Language {
 	switch iso3 {
 	case ENG:
 		return English
+	case FRA:
+		return French
+	case DEU:
+		return German
+	case SPA:
+		return Spanish
+	case ITA:
+		return Italian
+	case POR:
+		return Portuguese
+	case NLD:
+		return Dutch
+	case RUS:
+		return Russian
+	case ELL:
+		return Greek
+	case ARA:
+		return Arabic
+	case ZHO:
+		return Chinese
+	case JPN:
+		return Japanese
+	case KOR:
+		return Korean
 	default:
 		return -1
 	}
@@ -70,6 +206,30 @@ func GetIsoCode639_1FromValue(val string) IsoCode639_1 {
 	switch val {
 	case "en":
 		return EN
+	case "fr":
+		return FR
+	case "de":
+		return DE
+	case "es":
+		return ES
+	case "it":
+		return IT
+	case "pt":
+		return PT
+	case "nl":
+		return NL
+	case "ru":
+		return RU
+	case "el":
+		return EL
+	case "ar":
+		return AR
+	case "zh":
+		return ZH
+	case "ja":
+		return JA
+	case "ko":
+		return KO
 	default:
 		return -1
 	}
@@ -80,7 +240,45 @@ func GetIsoCode639_3FromValue(val string) IsoCode639_3 {
 	switch val {
 	case "eng":
 		return ENG
+	case "fra":
+		return FRA
+	case "deu":
+		return DEU
+	case "spa":
+		return SPA
+	case "ita":
+		return ITA
+	case "por":
+		return POR
+	case "nld":
+		return NLD
+	case "rus":
+		return RUS
+	case "ell":
+		return ELL
+	case "ara":
+		return ARA
+	case "zho":
+		return ZHO
+	case "jpn":
+		return JPN
+	case "kor":
+		return KOR
 	default:
 		return -1
 	}
 }
+
+// FromCode maps an ISO 639-1 or 639-3 code, in any case, to its Language, reporting
+// whether it is recognized.
+func FromCode(code string) (Language, bool) {
+	code = strings.ToLower(code)
+	var lang Language = -1
+	switch len(code) {
+	case 2:
+		lang = GetLanguageFromIsoCode639_1(GetIsoCode639_1FromValue(code))
+	case 3:
+		lang = GetLanguageFromIsoCode639_3(GetIsoCode639_3FromValue(code))
+	}
+	return lang, lang != -1
+}
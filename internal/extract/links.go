@@ -0,0 +1,71 @@
+// Package extract provides link extraction from HTML documents.
+package extract
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// linkSet collects the href values of anchor tags, cleaned and de-duplicated in
+// document order, along with the document's <base href>. It is shared by every
+// link extraction entry point so they always agree on the links a page has.
+type linkSet struct {
+	seen  map[string]struct{} // Links already collected
+	links []string            // Collected links in document order
+	base  string              // href of the first <base> element that has one
+}
+
+// newLinkSet creates an empty linkSet.
+func newLinkSet() *linkSet {
+	return &linkSet{seen: make(map[string]struct{})}
+}
+
+// addNode collects the href of an anchor node and records the first <base href>;
+// other nodes are ignored.
+func (ls *linkSet) addNode(node *html.Node) {
+	if ls.base == "" && node.Type == html.ElementNode && node.DataAtom == atom.Base {
+		for _, attr := range node.Attr {
+			if attr.Key == "href" {
+				ls.base = strings.TrimSpace(attr.Val)
+			}
+		}
+	}
+	if !isATag(node) {
+		return
+	}
+	for _, attr := range node.Attr {
+		if attr.Key == "href" {
+			ls.add(attr.Val)
+		}
+	}
+}
+
+// add cleans a raw href and collects it unless it is empty, fragment-only, or
+// already collected. Fragments are dropped since they address the same page.
+func (ls *linkSet) add(href string) {
+	href = strings.TrimSpace(href)
+	if i := strings.IndexByte(href, '#'); i >= 0 {
+		href = href[:i]
+	}
+	if href == "" {
+		return
+	}
+	if _, ok := ls.seen[href]; ok {
+		return
+	}
+	ls.seen[href] = struct{}{}
+	ls.links = append(ls.links, href)
+}
+
+// GetLinks returns the de-duplicated links of a document, for callers that only
+// need links. It yields the same links as ProcessHtmlDocument.
+func GetLinks(root *html.Node) []string {
+	ls := newLinkSet()
+	DfsNodes(root, func(node *html.Node) error {
+		ls.addNode(node)
+		return nil
+	})
+	return ls.links
+}
@@ -0,0 +1,52 @@
+package extract
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jdpolicano/go-search/internal/extract/language"
+)
+
+func TestLinksEntryPointsAgree(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"no links", `<p>nothing to follow</p>`, nil},
+		{"duplicates", `<a href="/a">a</a><a href="/b">b</a><a href="/a">again</a>`, []string{"/a", "/b"}},
+		{"fragments", `<a href="/a#top">a</a><a href="/a#bottom">a</a><a href="#only">here</a>`, []string{"/a"}},
+		{"whitespace", `<a href="  /a ">a</a><a href="/a">a</a><a href="   ">blank</a>`, []string{"/a"}},
+		{"document order", `<a href="/c">c</a><div><a href="/a">a</a></div><a href="/b#x">b</a><a href="/c#y">c</a>`, []string{"/c", "/a", "/b"}},
+		{"non-anchor hrefs", `<link href="/style.css"><a href="/a">a</a>`, []string{"/a"}},
+	}
+	parser := NewHtmlParser([]language.Language{language.English})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := `<html lang="en"><body>` + tt.body + `<p>some english text for the page</p></body></html>`
+			doc, err := parser.Parse(strings.NewReader(page))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := GetLinks(doc); !slices.Equal(got, tt.want) {
+				t.Errorf("GetLinks = %q, want %q", got, tt.want)
+			}
+			processed, err := ProcessHtmlDocument(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(processed.Links, tt.want) {
+				t.Errorf("ProcessHtmlDocument links = %q, want %q", processed.Links, tt.want)
+			}
+			streamed, err := parser.ProcessStream(strings.NewReader(page), "", DefaultOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(streamed.Links, tt.want) {
+				t.Errorf("ProcessStream links = %q, want %q", streamed.Links, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Package extract provides indexing of page metadata that isn't visible text.
+package extract
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// metadataText returns the text a node contributes as metadata under opts: the
+// content of a meta description or keywords tag, or an image's alt text. ok is
+// false for every other node and for sources opts leaves out.
+func metadataText(node *html.Node, opts Options) (text string, ok bool) {
+	if node.Type != html.ElementNode {
+		return "", false
+	}
+	switch {
+	case opts.MetaDescription && isMetaNamed(node, "description"):
+		return metaContent(node), true
+	case opts.MetaKeywords && isMetaNamed(node, "keywords"):
+		return metaContent(node), true
+	case opts.ImageAlt && node.DataAtom == atom.Img:
+		for _, attr := range node.Attr {
+			if attr.Key == "alt" {
+				return attr.Val, true
+			}
+		}
+	}
+	return "", false
+}
+
+// addMetadataTerms tokenizes metadata text into termFreqs, counting each word
+// weight times, and returns the number of terms added. Metadata is left out of the
+// content hash, so enabling it doesn't change which pages are duplicates.
+func addMetadataTerms(termFreqs map[string]int, text string, weight int) (int, error) {
+	words, err := ScanWordsFromString(text)
+	if err != nil {
+		return 0, err
+	}
+	weight = max(weight, 1)
+	for _, word := range words {
+		termFreqs[word] += weight
+	}
+	return len(words) * weight, nil
+}
+
+// isMetaNamed checks if a node is a <meta name="..."> tag with the given name.
+func isMetaNamed(node *html.Node, name string) bool {
+	if node.Type != html.ElementNode || node.DataAtom != atom.Meta {
+		return false
+	}
+	for _, attr := range node.Attr {
+		if strings.EqualFold(attr.Key, "name") && strings.EqualFold(strings.TrimSpace(attr.Val), name) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,50 +2,117 @@
 package extract
 
 import (
-	"crypto"
-	"encoding/hex"
+	"strings"
 
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 )
 
 // Extracted contains the processed content from an HTML document.
 type Extracted struct {
-	Links     []string       // Extracted links (href attributes)
-	TermFreqs map[string]int // Term frequency map for the document
-	Hash      string         // SHA256 hash of all words for content deduplication
-	Len       int            // Total number of words in the document
+	Links     []string         // Extracted links (href attributes), de-duplicated and without fragments
+	Base      string           // Raw href of the first <base> element, which links resolve against; empty if none
+	TermFreqs map[string]int   // Term frequency map for the document
+	Positions map[string][]int // Positions of each term among the document's body words, for phrase queries; metadata terms have none
+	Hash      string           // Hex digest of all words by Options.Hash, for deduplication; digests other than SHA256 carry an "algo:" prefix, e.g. "xxhash:"
+	Len       int              // Total number of words in the document
+	Text      string           // Visible text of the document, space separated
+	Refresh   string           // Raw target of a zero-delay meta refresh redirect, empty if none
+	Title     string           // Text of the <title> tag, or the first <h1> without one; empty if neither
+	Snippet   string           // Meta description, or the start of the visible text without one
 }
 
-// ProcessHtmlDocument extracts links, text, and metadata from an HTML document.
-// It performs a depth-first traversal to collect href attributes and visible text.
+// Options tunes how documents are extracted.
+type Options struct {
+	Hash             HashAlgorithm // Algorithm used for the content hash
+	SkipBoilerplate  bool          // Leave text in page chrome out of terms, text and hash; links are still extracted
+	BoilerplateTags  []string      // Elements whose text SkipBoilerplate leaves out, unless inside <main> or <article>
+	BoilerplateRoles []string      // Role attribute values whose text SkipBoilerplate leaves out, likewise
+	MetaDescription  bool          // Add the words of <meta name="description"> to the term frequencies
+	MetaKeywords     bool          // Add the words of <meta name="keywords"> to the term frequencies
+	ImageAlt         bool          // Add the words of <img alt> text to the term frequencies
+	MetadataWeight   int           // Times each metadata word is counted, to boost it over body text; below 1 counts once
+}
+
+// DefaultOptions returns the Options used by ProcessHtmlDocument. Boilerplate is
+// kept, but the default tags and roles are filled in for when it is skipped.
+func DefaultOptions() Options {
+	return Options{
+		Hash:             HashSHA256,
+		BoilerplateTags:  DefaultBoilerplateTags(),
+		BoilerplateRoles: DefaultBoilerplateRoles(),
+		MetadataWeight:   1,
+	}
+}
+
+// ProcessHtmlDocument extracts links, text, and metadata from an HTML document using DefaultOptions.
 func ProcessHtmlDocument(root *html.Node) (Extracted, error) {
-	links := make([]string, 0)
+	return ProcessHtmlDocumentWithOptions(root, DefaultOptions())
+}
+
+// ProcessHtmlDocumentWithOptions extracts links, text, and metadata from an HTML document.
+// It performs a depth-first traversal to collect href attributes and visible text.
+func ProcessHtmlDocumentWithOptions(root *html.Node, opts Options) (Extracted, error) {
+	links := newLinkSet()
 	termFreqs := make(map[string]int)
-	hash := crypto.SHA256.New()
+	positions := make(map[string][]int)
+	hash, err := opts.Hash.newHash()
+	if err != nil {
+		return Extracted{}, err
+	}
 	len := 0
+	position := 0
+	var text strings.Builder
+	refresh := ""
+	var titles titleFinder
+	var snippet snippetFinder
+	chrome := newBoilerplateFilter(opts)
 
 	// Traverse the HTML document and extract content
 	dfsErr := DfsNodes(root, func(node *html.Node) error {
 		// Extract links from anchor tags
-		if isATag(node) {
-			for _, attr := range node.Attr {
-				if attr.Key == "href" {
-					links = append(links, attr.Val)
-				}
+		links.addNode(node)
+
+		// Record the title and first heading
+		titles.addNode(node)
+		snippet.addNode(node)
+
+		// Count metadata words the options include
+		if meta, ok := metadataText(node, opts); ok && !chrome.skipsText(node) {
+			n, err := addMetadataTerms(termFreqs, meta, opts.MetadataWeight)
+			if err != nil {
+				return err
 			}
+			len += n
+		}
+
+		// Record the first immediate meta refresh redirect
+		if refresh == "" && isMetaRefresh(node) {
+			refresh = metaRefreshTarget(node)
 		}
 
 		// Process visible text content
-		if isVisibleText(node) {
-			words, scanErr := ScanWordsFromString(node.Data)
+		if isVisibleText(node) && !chrome.skipsText(node) {
+			data := normalizeText(node.Data)
+			words, scanErr := ScanWordsFromString(data)
 			if scanErr != nil {
 				return scanErr
 			}
 
-			// Update term frequencies and hash
+			if text.Len() > 0 {
+				text.WriteByte(' ')
+			}
+			text.WriteString(strings.TrimSpace(data))
+			if node.Parent == nil || node.Parent.DataAtom != atom.Title {
+				snippet.addText(data)
+			}
+
+			// Update term frequencies, positions and hash
 			for _, word := range words {
 				hash.Write([]byte(word))
 				termFreqs[word] += 1
+				positions[word] = append(positions[word], position)
+				position += 1
 				len += 1
 			}
 		}
@@ -58,9 +125,38 @@ func ProcessHtmlDocument(root *html.Node) (Extracted, error) {
 	}
 
 	return Extracted{
-		Links:     links,
+		Links:     links.links,
+		Base:      links.base,
 		TermFreqs: termFreqs,
-		Hash:      hex.EncodeToString(hash.Sum(nil)),
+		Positions: positions,
+		Hash:      opts.Hash.encode(hash.Sum(nil)),
 		Len:       len,
+		Text:      text.String(),
+		Refresh:   refresh,
+		Title:     titles.result(),
+		Snippet:   snippet.result(),
 	}, nil
 }
+
+// HashText computes the content hash of plain text exactly as ProcessHtmlDocument
+// does for a page's visible text, so hashes can be recomputed from stored text.
+func HashText(text string) (string, error) {
+	return HashTextWith(text, HashSHA256)
+}
+
+// HashTextWith is HashText using the given hash algorithm.
+func HashTextWith(text string, algo HashAlgorithm) (string, error) {
+	words, err := ScanWordsFromString(text)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := algo.newHash()
+	if err != nil {
+		return "", err
+	}
+	for _, word := range words {
+		hash.Write([]byte(word))
+	}
+	return algo.encode(hash.Sum(nil)), nil
+}
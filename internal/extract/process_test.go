@@ -0,0 +1,56 @@
+package extract
+
+import (
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/jdpolicano/go-search/internal/extract/language"
+)
+
+func TestProcessEntities(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantTerms map[string]int
+		wantText  string
+	}{
+		{"ampersand", "<p>salt &amp; pepper</p>", map[string]int{"salt": 1, "pepper": 1}, "salt & pepper"},
+		{"numeric apostrophe", "<p>chef&#39;s knife</p>", map[string]int{"chef": 1, "knife": 1}, "chef's knife"},
+		{"quotes", "<p>&quot;fresh&quot; &ldquo;herbs&rdquo;</p>", map[string]int{"fresh": 1, "herbs": 1}, "\"fresh\" “herbs”"},
+		{"nbsp splits words", "<p>olive&nbsp;oil&nbsp;&nbsp;garlic</p>", map[string]int{"olive": 1, "oil": 1, "garlic": 1}, "olive oil  garlic"},
+		{"hex and named", "<p>cr&#xE8;me br&ucirc;l&eacute;e&hellip;</p>", map[string]int{"crème": 1, "brûlée": 1}, "crème brûlée…"},
+		{"entity-heavy document", "<h1>Tom&amp;Jerry&#39;s&nbsp;Caf&eacute;</h1><p>&copy;&nbsp;2024&nbsp;&mdash;&nbsp;soup&nbsp;&amp;&nbsp;bread&#x21;</p>", map[string]int{"tom": 1, "jerry": 1, "café": 1, "soup": 1, "bread": 1}, "Tom&Jerry's Café © 2024 — soup & bread!"},
+		{"escaped markup", "<p>&lt;b&gt;bold&lt;/b&gt; tags</p>", map[string]int{"bold": 1, "tags": 1}, "<b>bold</b> tags"},
+	}
+	parser := NewHtmlParser([]language.Language{language.English})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := `<html lang="en"><body>` + tt.body + `</body></html>`
+			doc, err := parser.Parse(strings.NewReader(page))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tree, err := ProcessHtmlDocument(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			streamed, err := parser.ProcessStream(strings.NewReader(page), "", DefaultOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, extracted := range []Extracted{tree, streamed} {
+				if !maps.Equal(extracted.TermFreqs, tt.wantTerms) {
+					t.Errorf("terms %v, want %v", extracted.TermFreqs, tt.wantTerms)
+				}
+				if extracted.Text != tt.wantText {
+					t.Errorf("text %q, want %q", extracted.Text, tt.wantText)
+				}
+			}
+			if tree.Hash != streamed.Hash {
+				t.Errorf("tree hash %s differs from streamed hash %s", tree.Hash, streamed.Hash)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+// Package extract provides main content detection for article-like pages.
+package extract
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ErrorNoMainContent is returned by ExtractMainContent when no element stands out as the main content.
+var ErrorNoMainContent = errors.New("no main content found")
+
+// minMainContentRunes is the least amount of paragraph text the main content must have.
+const minMainContentRunes = 250
+
+// minParagraphRunes is the least amount of text a paragraph needs to count towards its container.
+const minParagraphRunes = 25
+
+// ExtractMainContent finds the element holding the main article body of a page using
+// a simplified readability heuristic: every paragraph scores its length, credited in
+// full to its parent and half to its grandparent, containers lose the share of their
+// text that is link text, and the best scoring container wins. Navigation, headers,
+// footers and asides are never candidates.
+//
+// It returns ErrorNoMainContent when the best container has too little text to be
+// an article, in which case callers should fall back to the whole document.
+func ExtractMainContent(root *html.Node) (*html.Node, error) {
+	scores := make(map[*html.Node]float64)
+
+	DfsNodes(root, func(node *html.Node) error {
+		if !isParagraph(node) || isBoilerplate(node) {
+			return nil
+		}
+
+		text := strings.TrimSpace(visibleText(node))
+		length := utf8.RuneCountInString(text)
+		if length < minParagraphRunes {
+			return nil
+		}
+
+		// Commas are a cheap signal for prose rather than lists of links or labels
+		score := 1 + float64(length)/100 + float64(strings.Count(text, ","))
+		if parent := node.Parent; parent != nil && parent.Type == html.ElementNode {
+			scores[parent] += score
+			if grandparent := parent.Parent; grandparent != nil && grandparent.Type == html.ElementNode {
+				scores[grandparent] += score / 2
+			}
+		}
+		return nil
+	})
+
+	var best *html.Node
+	bestScore := 0.0
+	for node, score := range scores {
+		score *= 1 - linkDensity(node)
+		if node.DataAtom == atom.Article || node.DataAtom == atom.Main {
+			score *= 1.25
+		}
+		if score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+
+	if best == nil || utf8.RuneCountInString(visibleText(best)) < minMainContentRunes {
+		return nil, ErrorNoMainContent
+	}
+	return best, nil
+}
+
+// ProcessMainContent extracts a document like ProcessHtmlDocumentWithOptions, but takes
+// terms, text and hash only from its main content when ExtractMainContent finds one.
+// Links, the base URL, meta refresh targets and the title always come from the whole
+// document, as do the snippet when the document has a meta description and any meta
+// description and keywords terms the options include.
+func ProcessMainContent(root *html.Node, opts Options) (Extracted, error) {
+	main, err := ExtractMainContent(root)
+	if errors.Is(err, ErrorNoMainContent) {
+		return ProcessHtmlDocumentWithOptions(root, opts)
+	}
+	if err != nil {
+		return Extracted{}, err
+	}
+
+	content, err := ProcessHtmlDocumentWithOptions(main, opts)
+	if err != nil {
+		return Extracted{}, err
+	}
+	whole, err := processWholeDocument(root, content.TermFreqs, opts)
+	if err != nil {
+		return Extracted{}, err
+	}
+	content.Links = whole.Links
+	content.Base = whole.Base
+	content.Refresh = whole.Refresh
+	content.Title = whole.Title
+	content.Len += whole.Len
+	if whole.Snippet != "" {
+		content.Snippet = whole.Snippet
+	}
+	return content, nil
+}
+
+// processWholeDocument collects in a single pass what ProcessMainContent takes from
+// the whole document: links, base URL, refresh target, title and meta description,
+// the last returned as Snippet. Meta description and keywords terms the options
+// include are added to termFreqs and counted in Len; image alt text is left to the
+// main content.
+func processWholeDocument(root *html.Node, termFreqs map[string]int, opts Options) (Extracted, error) {
+	links := newLinkSet()
+	var titles titleFinder
+	var snippet snippetFinder
+	var whole Extracted
+	opts.ImageAlt = false
+
+	err := DfsNodes(root, func(node *html.Node) error {
+		links.addNode(node)
+		titles.addNode(node)
+		snippet.addNode(node)
+		if whole.Refresh == "" && isMetaRefresh(node) {
+			whole.Refresh = metaRefreshTarget(node)
+		}
+		if meta, ok := metadataText(node, opts); ok {
+			n, err := addMetadataTerms(termFreqs, meta, opts.MetadataWeight)
+			if err != nil {
+				return err
+			}
+			whole.Len += n
+		}
+		return nil
+	})
+	if err != nil {
+		return Extracted{}, err
+	}
+
+	whole.Links = links.links
+	whole.Base = links.base
+	whole.Title = titles.result()
+	whole.Snippet = snippet.description
+	return whole, nil
+}
+
+// isParagraph reports whether a node is a block of prose that can score its container.
+func isParagraph(node *html.Node) bool {
+	if node.Type != html.ElementNode {
+		return false
+	}
+	switch node.DataAtom {
+	case atom.P, atom.Pre, atom.Blockquote, atom.Td:
+		return true
+	}
+	return false
+}
+
+// isBoilerplate reports whether a node sits inside page chrome rather than content.
+func isBoilerplate(node *html.Node) bool {
+	for n := node; n != nil; n = n.Parent {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		switch n.DataAtom {
+		case atom.Nav, atom.Header, atom.Footer, atom.Aside, atom.Form:
+			return true
+		}
+	}
+	return false
+}
+
+// visibleText returns the concatenated visible text under a node.
+func visibleText(node *html.Node) string {
+	var text strings.Builder
+	DfsNodes(node, func(n *html.Node) error {
+		if isVisibleText(n) {
+			text.WriteString(n.Data)
+		}
+		return nil
+	})
+	return text.String()
+}
+
+// linkDensity returns the fraction of a node's visible text that is inside links.
+func linkDensity(node *html.Node) float64 {
+	total := utf8.RuneCountInString(visibleText(node))
+	if total == 0 {
+		return 0
+	}
+
+	linked := 0
+	DfsNodes(node, func(n *html.Node) error {
+		if isATag(n) {
+			linked += utf8.RuneCountInString(visibleText(n))
+		}
+		return nil
+	})
+	return min(float64(linked)/float64(total), 1)
+}
@@ -0,0 +1,54 @@
+// Package extract provides meta-refresh redirect detection.
+package extract
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// isMetaRefresh checks if a node is a <meta http-equiv="refresh"> tag.
+func isMetaRefresh(node *html.Node) bool {
+	if node.Type != html.ElementNode || node.DataAtom != atom.Meta {
+		return false
+	}
+	for _, attr := range node.Attr {
+		if strings.EqualFold(attr.Key, "http-equiv") && strings.EqualFold(strings.TrimSpace(attr.Val), "refresh") {
+			return true
+		}
+	}
+	return false
+}
+
+// metaRefreshTarget returns the raw target URL of a meta refresh tag that redirects
+// immediately. Refreshes with a non-zero delay reload rather than redirect in
+// practice, so they, and malformed content attributes, yield an empty string.
+func metaRefreshTarget(node *html.Node) string {
+	return parseRefreshContent(metaContent(node))
+}
+
+// parseRefreshContent parses a refresh content value such as `0; url='/next'`.
+func parseRefreshContent(content string) string {
+	delay, target, found := strings.Cut(content, ";")
+	if !found {
+		delay, target, found = strings.Cut(content, ",")
+	}
+	if !found {
+		return ""
+	}
+
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(delay), 64)
+	if err != nil || seconds != 0 {
+		return ""
+	}
+
+	// Both sides of the '=' may be padded, e.g. `0; URL = /next`
+	target = strings.TrimSpace(target)
+	if key, value, ok := strings.Cut(target, "="); ok && strings.EqualFold(strings.TrimSpace(key), "url") {
+		target = strings.TrimSpace(value)
+	}
+	target = strings.Trim(target, `"'`)
+	return strings.TrimSpace(target)
+}
@@ -4,29 +4,139 @@ package extract
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
 	_ "embed"
+	"encoding/hex"
+	"fmt"
 	"io"
+	"maps"
+	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"unicode"
 	"unicode/utf8"
 )
 
 //go:embed stop_words.txt
 var stopWordsData string
-var stopWords = initStopWords()
-
-// initStopWords initializes the stop words map from the embedded file.
-func initStopWords() map[string]any {
-	lines := strings.Split(stopWordsData, "\n")
-	stopWords := make(map[string]any, len(lines))
-	for _, line := range lines {
-		word := strings.TrimSpace(line)
-		if word != "" {
-			stopWords[word] = nil
+
+// stopWords holds the active stop word set. The set itself is never mutated once
+// published; SetStopWords swaps in a new set atomically, so tokenizers running on
+// other goroutines always see either the old or the new set in full.
+var stopWords atomic.Pointer[map[string]struct{}]
+
+// StopWordsEnv names the environment variable read by StopWordsFromEnv: the path of
+// a stop word file, or StopWordsNone to keep every word.
+const StopWordsEnv = "GOSEARCH_STOP_WORDS"
+
+// StopWordsNone is the StopWordsEnv value that disables stop word removal.
+const StopWordsNone = "none"
+
+func init() {
+	words := DefaultStopWords()
+	stopWords.Store(&words)
+}
+
+// DefaultStopWords returns a new copy of the embedded English stop word set.
+func DefaultStopWords() map[string]struct{} {
+	// The embedded list is plain words, so reading it can't fail.
+	words, _ := ReadStopWords(strings.NewReader(stopWordsData))
+	return words
+}
+
+// ReadStopWords reads a stop word set with one word per line. Blank lines and lines
+// starting with '#' are ignored, and words are lowercased.
+func ReadStopWords(r io.Reader) (map[string]struct{}, error) {
+	words := make(map[string]struct{})
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
 		}
+		words[strings.ToLower(word)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return words, nil
+}
+
+// LoadStopWords reads a stop word file in the format ReadStopWords accepts.
+func LoadStopWords(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	words, err := ReadStopWords(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return words, nil
+}
+
+// StopWordsFromEnv returns the stop word set named by StopWordsEnv: DefaultStopWords
+// when it is unset, an empty set when it is StopWordsNone, and otherwise the set
+// loaded from the file it names. Crawlers and servers sharing an index should
+// agree on it, or stop words dropped from queries may have been indexed.
+func StopWordsFromEnv() (map[string]struct{}, error) {
+	switch value := os.Getenv(StopWordsEnv); value {
+	case "":
+		return DefaultStopWords(), nil
+	case StopWordsNone:
+		return map[string]struct{}{}, nil
+	default:
+		return LoadStopWords(value)
 	}
-	return stopWords
+}
+
+// SetStopWords replaces the active stop word set; an empty set disables stop word
+// removal. It is safe to call while other goroutines are tokenizing; the given map
+// is copied, so the caller may keep using it.
+func SetStopWords(words map[string]struct{}) {
+	set := make(map[string]struct{}, len(words))
+	for word := range words {
+		set[strings.ToLower(word)] = struct{}{}
+	}
+	stopWords.Store(&set)
+}
+
+// StopWordsHash returns a hash identifying the active stop word set, independent of
+// the order the words were read in, so an index can record the set it was built with;
+// store.CheckIndexStopWords compares it.
+func StopWordsHash() string {
+	h := sha256.New()
+	for _, word := range slices.Sorted(maps.Keys(*stopWords.Load())) {
+		h.Write([]byte(word))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// keepNumbers reports whether ScanWords keeps numeric words. Like the stop word set
+// it is read once per scan.
+var keepNumbers atomic.Bool
+
+// SetKeepNumbers controls whether numbers are indexed and searched. By default
+// integer words are dropped, so "python 3" searches only for "python". When
+// enabled, integers are kept and a '.' between digits no longer splits a word, so
+// decimals and versions such as "3.11" or "1.2.3" become single terms.
+//
+// Numbers add many terms that rarely repeat (years, counts, ids, prices), which
+// grows the terms and postings tables noticeably. Words mixing letters and digits,
+// such as "utf8" or "h264", are kept either way. Documents and queries must be
+// tokenized with the same setting; store.CheckIndexKeepNumbers records it.
+func SetKeepNumbers(enabled bool) {
+	keepNumbers.Store(enabled)
+}
+
+// KeepNumbersEnabled reports whether ScanWords currently keeps numbers.
+func KeepNumbersEnabled() bool {
+	return keepNumbers.Load()
 }
 
 // isAlphaNumericRune checks if a rune is a letter or number.
@@ -37,6 +147,17 @@ func isAlphaNumericRune(r rune) bool {
 // ScanAlphaNumericWord is a bufio.SplitFunc that scans for alphanumeric words.
 // It skips non-alphanumeric characters and returns the next word in lowercase.
 func ScanAlphaNumericWord(data []byte, isEof bool) (int, []byte, error) {
+	return scanWord(data, false)
+}
+
+// ScanNumericWord is ScanAlphaNumericWord, but also keeps a '.' between two digits
+// inside a word, so decimals and versions such as "3.11" or "1.2.3" stay one word.
+func ScanNumericWord(data []byte, isEof bool) (int, []byte, error) {
+	return scanWord(data, true)
+}
+
+// scanWord splits the next word from data, joining digits around a '.' when numbers is set.
+func scanWord(data []byte, numbers bool) (int, []byte, error) {
 	start := 0
 	// Skip anything that isn't alphanumeric to begin.
 	for start < len(data) {
@@ -48,12 +169,17 @@ func ScanAlphaNumericWord(data []byte, isEof bool) (int, []byte, error) {
 	}
 
 	end := start
+	prev := rune(-1)
 	for end < len(data) {
 		r, size := utf8.DecodeRune(data[end:])
-		// We've reached the end of our sequence
+		// We've reached the end of our sequence, unless this is a decimal point
 		if !isAlphaNumericRune(r) {
-			return end + size, bytes.ToLower(data[start:end]), nil
+			next, _ := utf8.DecodeRune(data[end+size:])
+			if !(numbers && isNumberPoint(prev, r, next)) {
+				return end + size, bytes.ToLower(data[start:end]), nil
+			}
 		}
+		prev = r
 		end += size
 	}
 
@@ -66,17 +192,79 @@ func ScanAlphaNumericWord(data []byte, isEof bool) (int, []byte, error) {
 	return end, nil, nil
 }
 
+// isNumberPoint reports whether r is a '.' between the digits prev and next.
+func isNumberPoint(prev, r, next rune) bool {
+	return r == '.' && unicode.IsDigit(prev) && unicode.IsDigit(next)
+}
+
+// nextWordSpan returns the byte offsets [start, end) of the first word of s at or
+// after i, split as scanWord does, or start -1 if there is none.
+func nextWordSpan(s string, i int, numbers bool) (start, end int) {
+	start = -1
+	prev := rune(-1)
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if isAlphaNumericRune(r) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			next, _ := utf8.DecodeRuneInString(s[i+size:])
+			if !(numbers && isNumberPoint(prev, r, next)) {
+				return start, i
+			}
+		}
+		prev = r
+		i += size
+	}
+	if start < 0 {
+		return -1, len(s)
+	}
+	return start, len(s)
+}
+
+// wordFilter applies stop word removal, number filtering and stemming to scanned
+// words. It snapshots the settings when created, so a concurrent SetStopWords,
+// SetStemming or SetKeepNumbers can't change them mid-document.
+type wordFilter struct {
+	stop    map[string]struct{} // Stop words to drop
+	stem    bool                // Reduce kept words to their stems
+	numbers bool                // Keep numbers, including decimals and versions
+}
+
+// newWordFilter creates a wordFilter with the active settings.
+func newWordFilter() wordFilter {
+	return wordFilter{stop: *stopWords.Load(), stem: stemming.Load(), numbers: keepNumbers.Load()}
+}
+
+// apply returns the indexed form of a lowercase word, or false if it is dropped.
+func (f wordFilter) apply(word string) (string, bool) {
+	if _, isStopWord := f.stop[word]; isStopWord || (!f.numbers && isIntegerWord(word)) {
+		return "", false
+	}
+	if f.stem {
+		word = Stem(word)
+	}
+	return word, true
+}
+
 // ScanWords scans text from an io.Reader and returns filtered words.
-// It removes stop words and integer words, returning lowercase results.
+// It removes stop words and integer words, returning lowercase results, reduced
+// to their stems when stemming is enabled with SetStemming. Integers are kept,
+// along with decimals and versions, when enabled with SetKeepNumbers.
 func ScanWords(reader io.Reader) ([]string, error) {
+	filter := newWordFilter()
 	scanner := bufio.NewScanner(reader)
-	scanner.Split(ScanAlphaNumericWord)
+	if filter.numbers {
+		scanner.Split(ScanNumericWord)
+	} else {
+		scanner.Split(ScanAlphaNumericWord)
+	}
 
 	words := make([]string, 0, 1024)
 	for scanner.Scan() {
-		word := scanner.Text()
-		if _, isStopWord := stopWords[word]; !isStopWord && !isIntegerWord(word) {
-			words = append(words, strings.ToLower(word))
+		if word, ok := filter.apply(scanner.Text()); ok {
+			words = append(words, word)
 		}
 	}
 
@@ -87,9 +275,25 @@ func ScanWords(reader io.Reader) ([]string, error) {
 	return words, nil
 }
 
-// ScanWordsFromString scans text from a string and returns filtered words.
+// ScanWordsFromString is ScanWords for text already in memory. It splits words
+// exactly as ScanWords does, but walks the string directly instead of
+// going through a reader and scanner, since it is called for every text node of a
+// document and every word of a highlighted snippet. The error is always nil; it is
+// kept so callers can treat both functions alike.
 func ScanWordsFromString(s string) ([]string, error) {
-	return ScanWords(strings.NewReader(s))
+	filter := newWordFilter()
+	var words []string
+	for i := 0; i < len(s); {
+		start, end := nextWordSpan(s, i, filter.numbers)
+		if start < 0 {
+			break
+		}
+		if word, ok := filter.apply(strings.ToLower(s[start:end])); ok {
+			words = append(words, word)
+		}
+		i = end
+	}
+	return words, nil
 }
 
 // isIntegerWord checks if a word represents an integer value.
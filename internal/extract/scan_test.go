@@ -0,0 +1,125 @@
+package extract
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// toSet returns a stop word set of words.
+func toSet(words ...string) map[string]struct{} {
+	set := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		set[word] = struct{}{}
+	}
+	return set
+}
+
+func TestSetStopWordsConcurrentScan(t *testing.T) {
+	t.Cleanup(func() { SetStopWords(DefaultStopWords()) })
+
+	const text = "the cat and the dog sat on a mat"
+	tests := []struct {
+		name string
+		sets []map[string]struct{}
+		want [][]string // Acceptable scans, one per set
+	}{
+		{
+			"two sets",
+			[]map[string]struct{}{toSet("the"), toSet("cat", "dog")},
+			[][]string{
+				{"cat", "and", "dog", "sat", "on", "a", "mat"},
+				{"the", "and", "the", "sat", "on", "a", "mat"},
+			},
+		},
+		{
+			"set and none",
+			[]map[string]struct{}{toSet("the", "and", "on", "a"), toSet()},
+			[][]string{
+				{"cat", "dog", "sat", "mat"},
+				{"the", "cat", "and", "the", "dog", "sat", "on", "a", "mat"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetStopWords(tt.sets[0])
+
+			var writer, scanners sync.WaitGroup
+			stop := make(chan struct{})
+			writer.Add(1)
+			go func() {
+				defer writer.Done()
+				for i := 0; ; i++ {
+					select {
+					case <-stop:
+						return
+					default:
+						SetStopWords(tt.sets[i%len(tt.sets)])
+					}
+				}
+			}()
+
+			// Every scan sees exactly one of the sets, never a mix of them
+			for range 8 {
+				scanners.Add(1)
+				go func() {
+					defer scanners.Done()
+					for range 500 {
+						words, err := ScanWordsFromString(text)
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						if !slices.ContainsFunc(tt.want, func(want []string) bool { return slices.Equal(words, want) }) {
+							t.Errorf("scanned %q, want one of %q", words, tt.want)
+							return
+						}
+					}
+				}()
+			}
+			scanners.Wait()
+			close(stop)
+			writer.Wait()
+		})
+	}
+}
+
+func TestScanWordsFromString(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"whitespace only", " \t\n ", nil},
+		{"punctuation only", "... !? -- ()", nil},
+		{"punctuation between words", "hello,world! foo-bar (baz)", []string{"hello", "world", "foo", "bar", "baz"}},
+		{"apostrophes split words", "don't stop", []string{"don", "stop"}},
+		{"case folded", "Hello WORLD", []string{"hello", "world"}},
+		{"accented letters", "Café naïve résumé", []string{"café", "naïve", "résumé"}},
+		{"non-latin scripts", "Привет мир 你好", []string{"привет", "мир", "你好"}},
+		{"unicode punctuation", "«quoted» — dash… finish", []string{"quoted", "dash", "finish"}},
+		{"stop words and integers", "the 2024 release of utf8", []string{"release", "utf8"}},
+		{"decimal split", "version 3.11", []string{"version"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ScanWordsFromString(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ScanWordsFromString(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+			read, err := ScanWords(strings.NewReader(tt.text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(read, got) {
+				t.Errorf("ScanWords(%q) = %q, ScanWordsFromString gave %q", tt.text, read, got)
+			}
+		})
+	}
+}
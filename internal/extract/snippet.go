@@ -0,0 +1,108 @@
+// Package extract provides snippet extraction and formatting utilities.
+package extract
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// snippetEllipsis is appended to snippets that were shortened.
+const snippetEllipsis = "…"
+
+// MaxSnippetRunes caps the length of the static snippet stored for a document.
+const MaxSnippetRunes = 200
+
+// snippetFinder builds a document's static snippet from its meta description,
+// falling back to the start of its visible text.
+type snippetFinder struct {
+	description string          // Content of the first non-empty meta description
+	text        strings.Builder // Leading visible words, a little past MaxSnippetRunes
+	textRunes   int             // Runes written to text
+}
+
+// addNode records the content of the first non-empty meta description.
+func (sf *snippetFinder) addNode(node *html.Node) {
+	if sf.description == "" && isMetaNamed(node, "description") {
+		sf.description = CleanSnippet(metaContent(node))
+	}
+}
+
+// addText records visible text, a word at a time, until there is more than enough
+// for a snippet, so truncation can tell whether it cuts anything.
+func (sf *snippetFinder) addText(text string) {
+	for _, word := range strings.Fields(text) {
+		if sf.textRunes > MaxSnippetRunes {
+			return
+		}
+		if sf.textRunes > 0 {
+			sf.text.WriteByte(' ')
+			sf.textRunes++
+		}
+		sf.text.WriteString(word)
+		sf.textRunes += utf8.RuneCountInString(word)
+	}
+}
+
+// result returns the snippet, or an empty string if the document has no text.
+func (sf *snippetFinder) result() string {
+	if sf.description != "" {
+		return sf.description
+	}
+	return CleanSnippet(sf.text.String())
+}
+
+// CleanSnippet turns raw text into a static snippet: leftover HTML entities are
+// decoded, whitespace is collapsed and the result is capped at MaxSnippetRunes.
+func CleanSnippet(raw string) string {
+	text := strings.Join(strings.Fields(html.UnescapeString(raw)), " ")
+	return TruncateSnippet(text, MaxSnippetRunes)
+}
+
+// metaContent returns the content attribute of a meta tag.
+func metaContent(node *html.Node) string {
+	for _, attr := range node.Attr {
+		if strings.EqualFold(attr.Key, "content") {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// TruncateSnippet shortens text to at most maxRunes runes, including the trailing
+// ellipsis added when anything is cut. It prefers to cut at the last word boundary
+// that fits, and never splits a multi-byte rune. Text that already fits is returned
+// unchanged, and a non-positive maxRunes yields an empty string.
+func TruncateSnippet(text string, maxRunes int) string {
+	if maxRunes <= 0 {
+		return ""
+	}
+
+	runes := []rune(text)
+	if len(runes) <= maxRunes {
+		return text
+	}
+
+	// Leave room for the ellipsis.
+	keep := maxRunes - 1
+	if keep <= 0 {
+		return snippetEllipsis
+	}
+
+	// Back up to the last whitespace so we don't cut a word in half, unless the
+	// next rune is already a boundary.
+	cut := keep
+	if !unicode.IsSpace(runes[keep]) {
+		for cut > 0 && !unicode.IsSpace(runes[cut-1]) {
+			cut--
+		}
+		// A single word longer than the limit gets a hard cut instead.
+		if cut == 0 {
+			cut = keep
+		}
+	}
+
+	return strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace) + snippetEllipsis
+}
@@ -0,0 +1,43 @@
+package extract
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateSnippet(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		maxRunes int
+		want     string
+	}{
+		{"fits", "short text", 20, "short text"},
+		{"exact fit", "exactly ten", 11, "exactly ten"},
+		{"cut at word boundary", "the quick brown fox", 12, "the quick…"},
+		{"limit mid word", "the quick brown fox", 14, "the quick…"},
+		{"next rune is a space", "the quick brown fox", 10, "the quick…"},
+		{"single long word", "supercalifragilistic", 8, "superca…"},
+		{"multibyte runes", "naïve café crème brûlée", 12, "naïve café…"},
+		{"multibyte mid word", "日本語のテキストです", 5, "日本語の…"},
+		{"emoji", "🙂🙂🙂 🙂🙂🙂", 5, "🙂🙂🙂…"},
+		{"only ellipsis fits", "hello world", 1, "…"},
+		{"zero limit", "hello", 0, ""},
+		{"negative limit", "hello", -3, ""},
+		{"empty text", "", 5, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateSnippet(tt.text, tt.maxRunes)
+			if got != tt.want {
+				t.Errorf("TruncateSnippet(%q, %d) = %q, want %q", tt.text, tt.maxRunes, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("TruncateSnippet(%q, %d) split a rune: %q", tt.text, tt.maxRunes, got)
+			}
+			if tt.maxRunes > 0 && utf8.RuneCountInString(got) > tt.maxRunes {
+				t.Errorf("TruncateSnippet(%q, %d) = %q, longer than the limit", tt.text, tt.maxRunes, got)
+			}
+		})
+	}
+}
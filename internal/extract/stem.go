@@ -0,0 +1,296 @@
+// Package extract provides Porter stemming of scanned words.
+package extract
+
+import "sync/atomic"
+
+// stemming reports whether ScanWords reduces words to their Porter stem. Like the
+// stop word set it is read once per scan, so a concurrent SetStemming never
+// changes it mid-document.
+var stemming atomic.Bool
+
+// SetStemming turns Porter stemming of scanned words on or off. Documents and
+// queries must be tokenized with the same setting, or stemmed queries won't match
+// unstemmed terms; store.CheckIndexStemming records it.
+// Stemming is off by default, and only suits English text.
+func SetStemming(enabled bool) {
+	stemming.Store(enabled)
+}
+
+// StemmingEnabled reports whether ScanWords currently stems words.
+func StemmingEnabled() bool {
+	return stemming.Load()
+}
+
+// Stem reduces a lowercase English word to its stem with the Porter algorithm, so
+// that e.g. "running", "runs" and "run" share the stem "run". Words of two letters
+// or fewer, and words containing anything but ASCII letters, are returned unchanged.
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+
+	s := &stemmer{b: []byte(word)}
+	s.step1ab()
+	if len(s.b) <= 1 {
+		return string(s.b)
+	}
+	s.step1c()
+	s.step2()
+	s.step3()
+	s.step4()
+	s.step5()
+	return string(s.b)
+}
+
+// stemmer holds a word being stemmed. j marks the end of the stem when a suffix
+// has been matched by ends, so measure and the vowel checks look only at the stem.
+type stemmer struct {
+	b []byte
+	j int
+}
+
+// isConsonant reports whether b[i] is a consonant. 'y' is a consonant at the start
+// of a word or after a vowel.
+func (s *stemmer) isConsonant(i int) bool {
+	switch s.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !s.isConsonant(i-1)
+	}
+	return true
+}
+
+// measure counts the vowel-consonant sequences in b[:j+1], the m of the paper:
+// [C](VC)^m[V].
+func (s *stemmer) measure() int {
+	n, i := 0, 0
+	for {
+		if i > s.j {
+			return n
+		}
+		if !s.isConsonant(i) {
+			break
+		}
+		i++
+	}
+	i++
+	for {
+		for {
+			if i > s.j {
+				return n
+			}
+			if s.isConsonant(i) {
+				break
+			}
+			i++
+		}
+		i++
+		n++
+		for {
+			if i > s.j {
+				return n
+			}
+			if !s.isConsonant(i) {
+				break
+			}
+			i++
+		}
+		i++
+	}
+}
+
+// vowelInStem reports whether b[:j+1] contains a vowel.
+func (s *stemmer) vowelInStem() bool {
+	for i := 0; i <= s.j; i++ {
+		if !s.isConsonant(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doubleConsonant reports whether b[i-1:i+1] is a double consonant.
+func (s *stemmer) doubleConsonant(i int) bool {
+	if i < 1 || s.b[i] != s.b[i-1] {
+		return false
+	}
+	return s.isConsonant(i)
+}
+
+// cvc reports whether b[i-2:i+1] is consonant-vowel-consonant with the last
+// consonant not w, x or y, e.g. "hop" but not "snow".
+func (s *stemmer) cvc(i int) bool {
+	if i < 2 || !s.isConsonant(i) || s.isConsonant(i-1) || !s.isConsonant(i-2) {
+		return false
+	}
+	switch s.b[i] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+// ends reports whether the word ends with suffix, setting j to the end of the stem if so.
+func (s *stemmer) ends(suffix string) bool {
+	k := len(s.b) - 1
+	if len(suffix) > k+1 || string(s.b[k+1-len(suffix):]) != suffix {
+		return false
+	}
+	s.j = k - len(suffix)
+	return true
+}
+
+// setTo replaces the matched suffix, everything after j, with replacement.
+func (s *stemmer) setTo(replacement string) {
+	s.b = append(s.b[:s.j+1], replacement...)
+}
+
+// replace replaces the matched suffix if the stem has a measure above zero.
+func (s *stemmer) replace(replacement string) {
+	if s.measure() > 0 {
+		s.setTo(replacement)
+	}
+}
+
+// step1ab removes plurals and -ed or -ing, e.g. caresses -> caress, ponies -> poni,
+// agreed -> agree, hopping -> hop, filing -> file.
+func (s *stemmer) step1ab() {
+	if s.b[len(s.b)-1] == 's' {
+		switch {
+		case s.ends("sses"):
+			s.b = s.b[:len(s.b)-2]
+		case s.ends("ies"):
+			s.setTo("i")
+		case len(s.b) > 1 && s.b[len(s.b)-2] != 's':
+			s.b = s.b[:len(s.b)-1]
+		}
+	}
+
+	if s.ends("eed") {
+		if s.measure() > 0 {
+			s.b = s.b[:len(s.b)-1]
+		}
+		return
+	}
+	if !(s.ends("ed") || s.ends("ing")) || !s.vowelInStem() {
+		return
+	}
+
+	s.b = s.b[:s.j+1]
+	switch {
+	case s.ends("at"):
+		s.setTo("ate")
+	case s.ends("bl"):
+		s.setTo("ble")
+	case s.ends("iz"):
+		s.setTo("ize")
+	case s.doubleConsonant(len(s.b) - 1):
+		switch s.b[len(s.b)-1] {
+		case 'l', 's', 'z':
+		default:
+			s.b = s.b[:len(s.b)-1]
+		}
+	default:
+		s.j = len(s.b) - 1
+		if s.measure() == 1 && s.cvc(len(s.b)-1) {
+			s.b = append(s.b, 'e')
+		}
+	}
+}
+
+// step1c turns a terminal y into i when there is another vowel in the stem.
+func (s *stemmer) step1c() {
+	if s.ends("y") && s.vowelInStem() {
+		s.b[len(s.b)-1] = 'i'
+	}
+}
+
+// suffixRule maps a suffix to its replacement.
+type suffixRule struct {
+	suffix      string
+	replacement string
+}
+
+// step2Rules map double suffixes to single ones, e.g. -ization -> -ize.
+var step2Rules = []suffixRule{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+	{"izer", "ize"}, {"bli", "ble"}, {"alli", "al"}, {"entli", "ent"},
+	{"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"},
+	{"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+	{"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+	{"logi", "log"},
+}
+
+// step3Rules handle -ic-, -full, -ness and similar.
+var step3Rules = []suffixRule{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+	{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+// step4Suffixes are removed from stems with a measure above one.
+var step4Suffixes = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment",
+	"ent", "ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+// step2 applies the first matching step2Rules entry.
+func (s *stemmer) step2() {
+	s.applyRules(step2Rules)
+}
+
+// step3 applies the first matching step3Rules entry.
+func (s *stemmer) step3() {
+	s.applyRules(step3Rules)
+}
+
+// applyRules replaces the first suffix in rules the word ends with, if its stem
+// has a measure above zero. Only the first match is considered.
+func (s *stemmer) applyRules(rules []suffixRule) {
+	for _, rule := range rules {
+		if s.ends(rule.suffix) {
+			s.replace(rule.replacement)
+			return
+		}
+	}
+}
+
+// step4 removes -ant, -ence and the like from stems with a measure above one.
+// -ion is only removed after s or t.
+func (s *stemmer) step4() {
+	for _, suffix := range step4Suffixes {
+		if !s.ends(suffix) {
+			continue
+		}
+		if suffix == "ion" && (s.j < 0 || (s.b[s.j] != 's' && s.b[s.j] != 't')) {
+			return
+		}
+		if s.measure() > 1 {
+			s.b = s.b[:s.j+1]
+		}
+		return
+	}
+}
+
+// step5 removes a final -e from stems with a measure above one, or of one when
+// not preceded by cvc, and reduces a final -ll to -l when the measure is above one.
+func (s *stemmer) step5() {
+	s.j = len(s.b) - 1
+	if s.b[s.j] == 'e' {
+		s.j--
+		m := s.measure()
+		if m > 1 || (m == 1 && !s.cvc(s.j)) {
+			s.b = s.b[:len(s.b)-1]
+		}
+	}
+
+	s.j = len(s.b) - 1
+	if s.b[s.j] == 'l' && s.doubleConsonant(s.j) && s.measure() > 1 {
+		s.b = s.b[:len(s.b)-1]
+	}
+}
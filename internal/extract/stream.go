@@ -0,0 +1,199 @@
+// Package extract provides streaming extraction for very large HTML documents.
+package extract
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// MaxStreamText is the most bytes of text ProcessStream keeps in Extracted.Text.
+const MaxStreamText = 1 << 20
+
+// ProcessStream extracts links, term frequencies and positions, hash and length
+// from an HTML document in a single pass over its tokens, without building a
+// document tree. Peak memory is bounded by the largest single token, the term
+// frequency and position maps and MaxStreamText, rather than the whole page,
+// which matters for pages that are many megabytes.
+//
+// The results match ProcessHtmlDocumentWithOptions except that Text is cut off
+// after MaxStreamText bytes, at a rune boundary, and
+// language support is checked from contentLanguage, the <html> tag's lang
+// attribute and detection from the start of the visible text as
+// ParseWithContentLanguage does.
+func (p *HtmlParser) ProcessStream(reader io.Reader, contentLanguage string, opts Options) (Extracted, error) {
+	supported, headerKnown := p.isSupportedContentLanguage(contentLanguage)
+	if headerKnown && !supported {
+		return Extracted{}, ErrorNotSupportedLanguage
+	}
+	// Without a header or lang attribute, the language is detected once the sample fills or the document ends
+	detect := !headerKnown
+	var sample languageSample
+
+	links := newLinkSet()
+	termFreqs := make(map[string]int)
+	positions := make(map[string][]int)
+	hash, err := opts.Hash.newHash()
+	if err != nil {
+		return Extracted{}, err
+	}
+	length := 0
+	position := 0
+	refresh := ""
+	var visible strings.Builder
+	var titles titleFinder
+	var snippet snippetFinder
+	chrome := newBoilerplateFilter(opts)
+
+	// Open elements, so text can be attributed to its immediate parent like isVisibleText does,
+	// and whether each is page chrome, so boilerplate can be skipped like skipsText does
+	var open []atom.Atom
+	var openChrome []bool
+
+	cr := &contentReader{r: reader}
+	z := html.NewTokenizer(cr)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if errors.Is(z.Err(), io.EOF) {
+				if !cr.hasContent {
+					return Extracted{}, ErrorEmptyDocument
+				}
+				if detect && !p.isSupportedDetectedLanguage(sample.String()) {
+					return Extracted{}, ErrorNotSupportedLanguage
+				}
+				return Extracted{
+					Links:     links.links,
+					Base:      links.base,
+					TermFreqs: termFreqs,
+					Positions: positions,
+					Hash:      opts.Hash.encode(hash.Sum(nil)),
+					Len:       length,
+					Text:      visible.String(),
+					Refresh:   refresh,
+					Title:     titles.result(),
+					Snippet:   snippet.result(),
+				}, nil
+			}
+			return Extracted{}, z.Err()
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			node := &html.Node{Type: html.ElementNode, DataAtom: tok.DataAtom, Data: tok.Data, Attr: tok.Attr}
+
+			if node.DataAtom == atom.Html && !headerKnown {
+				if supported, declared := p.isSupportedLanguageNode(node); declared {
+					if !supported {
+						return Extracted{}, ErrorNotSupportedLanguage
+					}
+					detect = false
+				}
+			}
+			links.addNode(node)
+			snippet.addNode(node)
+			inChrome := slices.Contains(openChrome, true) && !slices.ContainsFunc(open, isContentRoot)
+			if meta, ok := metadataText(node, opts); ok && !inChrome {
+				n, err := addMetadataTerms(termFreqs, meta, opts.MetadataWeight)
+				if err != nil {
+					return Extracted{}, err
+				}
+				length += n
+			}
+			if refresh == "" && isMetaRefresh(node) {
+				refresh = metaRefreshTarget(node)
+			}
+
+			if tok.Type == html.StartTagToken && !isVoidElement(node.DataAtom) {
+				open = append(open, node.DataAtom)
+				openChrome = append(openChrome, chrome != nil && chrome.isBoilerplateElement(node))
+			}
+
+		case html.EndTagToken:
+			// Pop up to the matching element, implicitly closing anything left open inside it
+			closing := z.Token().DataAtom
+			titles.closeElement(closing)
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == closing {
+					open = open[:i]
+					openChrome = openChrome[:i]
+					break
+				}
+			}
+
+		case html.TextToken:
+			if len(open) > 0 && isHiddenTag(open[len(open)-1]) {
+				continue
+			}
+			text := normalizeText(string(z.Text()))
+			if detect && strings.TrimSpace(text) != "" {
+				sample.addText(text)
+				if sample.full() {
+					if !p.isSupportedDetectedLanguage(sample.String()) {
+						return Extracted{}, ErrorNotSupportedLanguage
+					}
+					detect = false
+				}
+			}
+			titles.addText(open, text)
+			if slices.Contains(openChrome, true) && !slices.ContainsFunc(open, isContentRoot) {
+				continue
+			}
+			inTitle := len(open) > 0 && open[len(open)-1] == atom.Title
+			if !inTitle && strings.TrimSpace(text) != "" {
+				snippet.addText(text)
+			}
+			appendCapped(&visible, strings.TrimSpace(text), MaxStreamText)
+			words, err := ScanWordsFromString(text)
+			if err != nil {
+				return Extracted{}, err
+			}
+			for _, word := range words {
+				hash.Write([]byte(word))
+				termFreqs[word] += 1
+				positions[word] = append(positions[word], position)
+				position += 1
+				length += 1
+			}
+		}
+	}
+}
+
+// isVoidElement reports whether an element never has content or an end tag.
+func isVoidElement(a atom.Atom) bool {
+	switch a {
+	case atom.Area, atom.Base, atom.Br, atom.Col, atom.Embed, atom.Hr, atom.Img, atom.Input,
+		atom.Link, atom.Meta, atom.Source, atom.Track, atom.Wbr:
+		return true
+	}
+	return false
+}
+
+// isHiddenTag reports whether text directly inside the element is never visible, matching isVisibleText.
+func isHiddenTag(a atom.Atom) bool {
+	return a == atom.Script || a == atom.Style || a == atom.Head || a == atom.Noscript
+}
+
+// appendCapped appends s to b space separated, as ProcessHtmlDocumentWithOptions
+// builds Text, keeping b within limit bytes without splitting a rune.
+func appendCapped(b *strings.Builder, s string, limit int) {
+	if b.Len() > 0 && b.Len() < limit {
+		b.WriteByte(' ')
+	}
+	room := limit - b.Len()
+	if room <= 0 {
+		return
+	}
+	if len(s) > room {
+		cut := room
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		s = s[:cut]
+	}
+	b.WriteString(s)
+}
@@ -0,0 +1,104 @@
+package extract
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/jdpolicano/go-search/internal/extract/language"
+)
+
+// largePage returns an English HTML document of roughly n paragraphs.
+func largePage(n int) string {
+	var b strings.Builder
+	b.WriteString(`<html lang="en"><head><title>Large page</title></head><body>`)
+	for i := range n {
+		fmt.Fprintf(&b, `<p>Paragraph %d talks about the crawler, the index and <a href="/page/%d">another page</a>.</p>`, i, i)
+	}
+	b.WriteString(`</body></html>`)
+	return b.String()
+}
+
+func TestProcessStreamText(t *testing.T) {
+	parser := NewHtmlParser([]language.Language{language.English})
+	tests := []struct {
+		name string
+		html string
+	}{
+		{"simple", `<html lang="en"><head><title>Hello</title></head><body><p>The quick brown fox</p><script>var x;</script></body></html>`},
+		{"nested", `<html lang="en"><body><div>Jumps <b>over</b> the <i>lazy</i> dog</div></body></html>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parser.Parse(strings.NewReader(tt.html))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := ProcessHtmlDocument(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parser.ProcessStream(strings.NewReader(tt.html), "", DefaultOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(strings.Fields(got.Text), " ") != strings.Join(strings.Fields(want.Text), " ") {
+				t.Errorf("Text = %q, want %q", got.Text, want.Text)
+			}
+		})
+	}
+}
+
+func TestAppendCapped(t *testing.T) {
+	tests := []struct {
+		name  string
+		parts []string
+		limit int
+		want  string
+	}{
+		{"under limit", []string{"hello", "world"}, 20, "hello world"},
+		{"cut mid word", []string{"hello", "world"}, 8, "hello wo"},
+		{"full before space", []string{"hello", "world"}, 5, "hello"},
+		{"multibyte boundary", []string{"héllo"}, 2, "h"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			for _, part := range tt.parts {
+				appendCapped(&b, part, tt.limit)
+			}
+			if got := b.String(); got != tt.want || !utf8.ValidString(got) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkProcessStream(b *testing.B) {
+	parser := NewHtmlParser([]language.Language{language.English})
+	page := largePage(5000)
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := parser.ProcessStream(strings.NewReader(page), "en", DefaultOptions()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProcessHtmlDocument(b *testing.B) {
+	parser := NewHtmlParser([]language.Language{language.English})
+	page := largePage(5000)
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+	for b.Loop() {
+		doc, err := parser.Parse(strings.NewReader(page))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := ProcessHtmlDocument(doc); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Package extract provides document title extraction.
+package extract
+
+import (
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// MaxTitleRunes caps the length of an extracted title, so a page stuffing its
+// <title> with keywords can't bloat the docs table or the result UI.
+const MaxTitleRunes = 200
+
+// titleFinder collects a document's title from its <title> tag, falling back to
+// the text of its first <h1> when there is no non-empty <title>. It is fed either
+// whole elements by the tree walk or text tokens by the streaming extractor.
+type titleFinder struct {
+	title     strings.Builder // Text of the first <title>
+	h1        strings.Builder // Text of the first <h1>
+	titleDone bool            // The first <title> has been read
+	h1Done    bool            // The first <h1> has been read
+}
+
+// addNode records the text of the first <title> and <h1> elements.
+func (tf *titleFinder) addNode(node *html.Node) {
+	if node.Type != html.ElementNode {
+		return
+	}
+	switch {
+	case node.DataAtom == atom.Title && !tf.titleDone:
+		tf.titleDone = true
+		tf.title.WriteString(textContent(node))
+	case node.DataAtom == atom.H1 && !tf.h1Done:
+		tf.h1Done = true
+		tf.h1.WriteString(textContent(node))
+	}
+}
+
+// addText records a text token found inside the open elements, innermost last.
+func (tf *titleFinder) addText(open []atom.Atom, text string) {
+	if !tf.titleDone && len(open) > 0 && open[len(open)-1] == atom.Title {
+		tf.title.WriteString(text)
+		tf.title.WriteByte(' ')
+	}
+	if !tf.h1Done && slices.Contains(open, atom.H1) {
+		tf.h1.WriteString(text)
+		tf.h1.WriteByte(' ')
+	}
+}
+
+// closeElement marks the first <title> or <h1> as read once its end tag is seen.
+func (tf *titleFinder) closeElement(a atom.Atom) {
+	switch a {
+	case atom.Title:
+		tf.titleDone = true
+	case atom.H1:
+		tf.h1Done = true
+	}
+}
+
+// result returns the cleaned title, or an empty string if the document has none.
+func (tf *titleFinder) result() string {
+	if title := CleanTitle(tf.title.String()); title != "" {
+		return title
+	}
+	return CleanTitle(tf.h1.String())
+}
+
+// CleanTitle collapses whitespace in a raw title and caps it at MaxTitleRunes.
+func CleanTitle(raw string) string {
+	return TruncateSnippet(strings.Join(strings.Fields(raw), " "), MaxTitleRunes)
+}
+
+// textContent returns all text under a node, including text a browser would not
+// render, since a <title> lives in <head>.
+func textContent(node *html.Node) string {
+	var text strings.Builder
+	DfsNodes(node, func(n *html.Node) error {
+		if n.Type == html.TextNode {
+			text.WriteString(n.Data)
+			text.WriteByte(' ')
+		}
+		return nil
+	})
+	return text.String()
+}
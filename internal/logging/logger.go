@@ -2,6 +2,8 @@ package logging
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 )
@@ -15,16 +17,64 @@ const (
 var defaultLogger *slog.Logger
 
 func init() {
-	defaultLogger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	}))
+	defaultLogger = NewLogger(slog.LevelInfo)
 	slog.SetDefault(defaultLogger)
 }
 
+// LogFormat selects how log records are written.
+type LogFormat string
+
+const (
+	FormatJSON LogFormat = "json" // One JSON object per record, the default for production
+	FormatText LogFormat = "text" // Human readable key=value records for local use
+)
+
+// FormatEnv names the environment variable that sets the log format of every binary.
+const FormatEnv = "GOSEARCH_LOG_FORMAT"
+
+// ParseLogFormat parses a log format name, accepting "json" or "text".
+func ParseLogFormat(name string) (LogFormat, error) {
+	switch format := LogFormat(name); format {
+	case FormatJSON, FormatText:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown log format %q, valid formats: %s, %s", name, FormatJSON, FormatText)
+}
+
+// FormatFromEnv returns the log format named by FormatEnv, or FormatJSON when it is
+// unset or not a valid format.
+func FormatFromEnv() LogFormat {
+	format, err := ParseLogFormat(os.Getenv(FormatEnv))
+	if err != nil {
+		return FormatJSON
+	}
+	return format
+}
+
+// NewLogger creates a logger writing to stdout in the format named by FormatEnv,
+// sampled at the rate named by SampleEnv.
 func NewLogger(level slog.Level) *slog.Logger {
-	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: level,
-	}))
+	return NewSampledLogger(level, FormatFromEnv(), os.Stdout, SampleRateFromEnv())
+}
+
+// NewLoggerWith creates a logger writing records in the given format to w.
+func NewLoggerWith(level slog.Level, format LogFormat, w io.Writer) *slog.Logger {
+	return slog.New(newHandler(level, format, w))
+}
+
+// NewSampledLogger is NewLoggerWith, logging only 1 in rate info and debug records
+// per message; see SamplingHandler.
+func NewSampledLogger(level slog.Level, format LogFormat, w io.Writer, rate int) *slog.Logger {
+	return slog.New(NewSamplingHandler(newHandler(level, format, w), rate))
+}
+
+// newHandler creates the handler for the given format.
+func newHandler(level slog.Level, format LogFormat, w io.Writer) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == FormatText {
+		return slog.NewTextHandler(w, opts)
+	}
+	return slog.NewJSONHandler(w, opts)
 }
 
 func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
@@ -50,8 +100,6 @@ func Default() *slog.Logger {
 }
 
 func SetLevel(level slog.Level) {
-	defaultLogger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: level,
-	}))
+	defaultLogger = NewLogger(level)
 	slog.SetDefault(defaultLogger)
 }
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// SampleEnv names the environment variable that sets the sample rate used by NewLogger.
+const SampleEnv = "GOSEARCH_LOG_SAMPLE"
+
+// SamplingHandler wraps a handler, passing through only 1 in every rate records that
+// share a message, so per-URL lines like "Crawler handling url" don't flood the output.
+// The first record with each message always passes, and records at slog.LevelWarn or
+// above are never sampled.
+type SamplingHandler struct {
+	inner  slog.Handler
+	rate   uint64
+	counts *sampleCounts // Shared with handlers derived by WithAttrs and WithGroup
+}
+
+// sampleCounts tracks how many records have been seen per message.
+type sampleCounts struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+// NewSamplingHandler wraps inner to log 1 in rate records per message. A rate of 1
+// or less disables sampling and returns inner unchanged.
+func NewSamplingHandler(inner slog.Handler, rate int) slog.Handler {
+	if rate <= 1 {
+		return inner
+	}
+	return &SamplingHandler{inner, uint64(rate), &sampleCounts{counts: make(map[string]uint64)}}
+}
+
+// SampleRateFromEnv returns the sample rate named by SampleEnv, or 1 (no sampling)
+// when it is unset or not a positive integer.
+func SampleRateFromEnv() int {
+	rate, err := strconv.Atoi(os.Getenv(SampleEnv))
+	if err != nil || rate < 1 {
+		return 1
+	}
+	return rate
+}
+
+func (h *SamplingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *SamplingHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level < slog.LevelWarn && !h.counts.sample(record.Message, h.rate) {
+		return nil
+	}
+	return h.inner.Handle(ctx, record)
+}
+
+func (h *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SamplingHandler{h.inner.WithAttrs(attrs), h.rate, h.counts}
+}
+
+func (h *SamplingHandler) WithGroup(name string) slog.Handler {
+	return &SamplingHandler{h.inner.WithGroup(name), h.rate, h.counts}
+}
+
+// sample counts a record with the given message, reporting whether it should be logged.
+func (c *sampleCounts) sample(message string, rate uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.counts[message]
+	c.counts[message] = n + 1
+	return n%rate == 0
+}
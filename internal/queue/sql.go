@@ -11,6 +11,10 @@ import (
 // ErrorFrontierEmpty is returned when attempting to dequeue from an empty frontier queue.
 var ErrorFrontierEmpty = errors.New("frontier queue is empty")
 
+// ErrorFrontierFull is returned by Enqueue when the frontier is at its size limit and
+// the queue is configured to drop new URLs rather than evict existing ones.
+var ErrorFrontierFull = errors.New("frontier queue is full")
+
 // Queue defines the interface for queue operations used by the crawler.
 type Queue[T any] interface {
 	Enqueue(item ...T) error // Add items to the queue
@@ -22,35 +26,155 @@ type Queue[T any] interface {
 // SqlFrontierQueue implements a SQL-based queue for managing the crawler's URL frontier.
 // It uses an in-memory buffer for performance and persists to the database.
 type SqlFrontierQueue struct {
-	ctx     context.Context      // Context for operations and cancellation
-	s       store.Store          // Database store for persistence
-	buffer  []store.FrontierItem // In-memory buffer for performance
-	bufSize int                  // Maximum buffer size
+	ctx      context.Context       // Context for operations and cancellation
+	s        store.Store           // Database store for persistence
+	buffer   []store.FrontierItem  // In-memory buffer for performance
+	bufSize  int                   // Maximum buffer size
+	weights  store.PriorityWeights // Priorities of seeds, and the bonus per referring page
+	maxSize  int                   // Maximum number of unvisited items, 0 for unbounded
+	eviction store.TrimPolicy      // What to evict once maxSize is reached
+	size     int                   // Unvisited items in the database, tracked to avoid a COUNT per Enqueue
 }
 
+// seedBatchSize is the number of seeds inserted per statement when creating a queue.
+const seedBatchSize = 1000
+
 // NewSqlQueue creates a new SQL-based frontier queue with the given configuration.
-func NewSqlQueue(ctx context.Context, s store.Store, bufSize int, seeds []string) (*SqlFrontierQueue, error) {
+// Seeds are prioritized with weights, and each distinct page found linking to a queued
+// URL raises its priority by weights.Inlink.
+// A positive maxSize bounds the number of unvisited items; once it is reached new URLs
+// are dropped, or existing ones evicted if eviction is not store.TrimNone. Eviction
+// lets the frontier overshoot maxSize by a tenth before trimming it back, so the trim
+// runs once per batch of new URLs rather than on every Enqueue.
+// Seeds count towards maxSize too: without eviction only those that fit are queued, in
+// order, and with it the frontier is trimmed back to maxSize once they are inserted.
+func NewSqlQueue(ctx context.Context, s store.Store, bufSize int, seeds []string, weights store.PriorityWeights, maxSize int, eviction store.TrimPolicy) (*SqlFrontierQueue, error) {
 	if len(seeds) == 0 {
 		return nil, errors.New("seeds cannot be empty")
 	}
 
-	if len(seeds) > bufSize {
-		return nil, errors.New("number of seeds cannot exceed buffer size")
+	buffer := make([]store.FrontierItem, 0, bufSize)
+	q := &SqlFrontierQueue{ctx, s, buffer, bufSize, weights, maxSize, eviction, 0}
+
+	if maxSize > 0 {
+		if err := q.resync(); err != nil {
+			return nil, err
+		}
 	}
 
-	buffer := make([]store.FrontierItem, 0, bufSize)
-	return &SqlFrontierQueue{ctx, s, buffer, bufSize}, nil
+	// Seeds go straight to the frontier table; Dequeue pages them into the buffer
+	// bufSize at a time, so there may be any number of them.
+	for start := 0; start < len(seeds); start += seedBatchSize {
+		batch := seeds[start:min(start+seedBatchSize, len(seeds))]
+		if maxSize > 0 && eviction == store.TrimNone {
+			if q.size >= maxSize {
+				break
+			}
+			batch = batch[:min(len(batch), maxSize-q.size)]
+		}
+		inserted, err := q.insertSeeds(batch)
+		if err != nil {
+			return nil, err
+		}
+		q.size += inserted
+	}
+
+	if maxSize > 0 && eviction != store.TrimNone && q.size > maxSize {
+		conn, err := s.Pool.Acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Release()
+		if err := q.trim(conn); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
 }
 
-// Enqueue adds frontier items to the queue by persisting them to the database.
+// Enqueue adds frontier items to the queue by persisting them to the database,
+// and records the link from each item's parent page to it. If the frontier is full it
+// returns ErrorFrontierFull, or evicts items past the limit when an eviction policy is set.
 func (q *SqlFrontierQueue) Enqueue(items ...store.FrontierItem) error {
 	conn, err := q.s.Pool.Acquire(q.ctx)
 	if err != nil {
 		return err
 	}
 	defer conn.Release()
-	_, err = store.InsertFIBatch(q.ctx, conn, items)
-	return err
+
+	if q.maxSize > 0 && q.eviction == store.TrimNone && q.size >= q.maxSize {
+		return ErrorFrontierFull
+	}
+
+	inserted, err := store.InsertFIBatch(q.ctx, conn, items)
+	if err != nil {
+		return err
+	}
+	q.size += len(inserted)
+	if err := q.recordInlinks(conn, items); err != nil {
+		return err
+	}
+
+	if q.maxSize > 0 && q.eviction != store.TrimNone && q.size > q.highWater() {
+		return q.trim(conn)
+	}
+	return nil
+}
+
+// highWater returns the number of unvisited items at which an evicting queue trims
+// itself back to maxSize.
+func (q *SqlFrontierQueue) highWater() int {
+	return q.maxSize + max(q.maxSize/10, 1)
+}
+
+// trim evicts unvisited items past maxSize, then recounts them so the tracked size
+// picks up any changes made by others.
+func (q *SqlFrontierQueue) trim(db store.DBTX) error {
+	if _, err := store.TrimFrontierToSize(q.ctx, db, q.maxSize, q.eviction); err != nil {
+		return err
+	}
+	return q.resyncWith(db)
+}
+
+// resync sets the tracked size to the number of unvisited items in the database.
+func (q *SqlFrontierQueue) resync() error {
+	conn, err := q.s.Pool.Acquire(q.ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	return q.resyncWith(conn)
+}
+
+// resyncWith is resync using db.
+func (q *SqlFrontierQueue) resyncWith(db store.DBTX) error {
+	count, err := store.GetFICountByStatus(q.ctx, db, store.StatusUnvisited)
+	if err != nil {
+		return err
+	}
+	q.size = count
+	return nil
+}
+
+// recordInlinks stores the parent -> item link edges of a batch, ignoring seeds and self links.
+func (q *SqlFrontierQueue) recordInlinks(db store.DBTX, items []store.FrontierItem) error {
+	targets := make(map[string][]string)
+	for _, item := range items {
+		if item.ParentUrl == "" {
+			continue
+		}
+		if parentNorm, err := store.NormalizeURL(item.ParentUrl); err == nil && parentNorm == item.UrlNorm {
+			continue
+		}
+		targets[item.ParentUrl] = append(targets[item.ParentUrl], item.UrlNorm)
+	}
+
+	for parent, urlNorms := range targets {
+		if err := store.InsertInlinks(q.ctx, db, parent, urlNorms, q.weights.Inlink); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Dequeue removes and returns the next frontier item from the queue.
@@ -89,17 +213,28 @@ func (q *SqlFrontierQueue) Len() (int, error) {
 	return count + len(q.buffer), nil
 }
 
-// Close cleans up the frontier by removing processed items and closing resources.
+// Close cleans up the frontier by returning buffered items that were never handed out
+// to unvisited, removing processed items and closing resources.
 func (q *SqlFrontierQueue) Close() error {
 	conn, err := q.s.Pool.Acquire(q.ctx)
 	if err != nil {
 		return err
 	}
 	defer conn.Release()
+
+	urlNorms := make([]string, len(q.buffer))
+	for i, item := range q.buffer {
+		urlNorms[i] = item.UrlNorm
+	}
+	if err := store.ReleaseFIBatch(q.ctx, conn, urlNorms); err != nil {
+		return err
+	}
+	q.buffer = q.buffer[:0]
 	return store.CleanupFrontier(q.ctx, conn)
 }
 
-// refill populates the buffer with unvisited frontier items from the database.
+// refill populates the buffer with unvisited frontier items from the database, claiming
+// them so they aren't read again or evicted while buffered or being crawled.
 // Safety: This is only called internally, so we can safely assume the buffer is empty.
 func (q *SqlFrontierQueue) refill() error {
 	conn, err := q.s.Pool.Acquire(q.ctx)
@@ -109,20 +244,11 @@ func (q *SqlFrontierQueue) refill() error {
 	// Ensure connection is released even if we return early
 	defer conn.Release()
 
-	rows, err := store.GetFIByStatusDepthSorted(q.ctx, conn, store.StatusUnvisited, q.bufSize)
+	items, err := store.ClaimFIBatch(q.ctx, conn, q.bufSize)
 	if err != nil {
 		return err
 	}
-
-	defer rows.Close()
-	items := make([]store.FrontierItem, 0, q.bufSize)
-	for rows.Next() {
-		var fi store.FrontierItem
-		if err := fi.FromRows(rows); err != nil {
-			return err
-		}
-		items = append(items, fi)
-	}
+	q.size = max(q.size-len(items), 0)
 
 	if len(items) == 0 {
 		return ErrorFrontierEmpty
@@ -132,21 +258,22 @@ func (q *SqlFrontierQueue) refill() error {
 	return nil
 }
 
-// insertSeeds converts seed URLs to frontier items and inserts them into the database.
-func (q *SqlFrontierQueue) insertSeeds(seeds []string) error {
+// insertSeeds converts seed URLs to frontier items and inserts them into the database,
+// returning how many were new. Seeds already in the frontier are left as they are.
+func (q *SqlFrontierQueue) insertSeeds(seeds []string) (int, error) {
 	conn, err := q.s.Pool.Acquire(q.ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer conn.Release()
 	items := make([]store.FrontierItem, 0, len(seeds))
 	for _, seed := range seeds {
-		item, err := store.NewFrontierItemFromSeed(seed)
+		item, err := store.NewFrontierItemFromSeed(seed, q.weights)
 		if err != nil {
-			return err
+			return 0, err
 		}
 		items = append(items, item)
 	}
-	_, err = store.InsertFIBatch(q.ctx, conn, items)
-	return err
+	inserted, err := store.InsertFIBatch(q.ctx, conn, items)
+	return len(inserted), err
 }
@@ -0,0 +1,71 @@
+package queue_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jdpolicano/go-search/internal/queue"
+	"github.com/jdpolicano/go-search/internal/store"
+	"github.com/jdpolicano/go-search/internal/store/testutil"
+)
+
+func TestSqlQueueSeeds(t *testing.T) {
+	dsn, err := testutil.TestDSN()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name     string
+		seeds    int
+		bufSize  int
+		maxSize  int
+		eviction store.TrimPolicy
+		want     int
+	}{
+		{"fewer than buffer", 3, 10, 0, store.TrimNone, 3},
+		{"more than buffer", 25, 10, 0, store.TrimNone, 25},
+		{"more than seed batch", 2500, 500, 0, store.TrimNone, 2500},
+		{"capped without eviction", 25, 10, 12, store.TrimNone, 12},
+		{"capped with eviction", 25, 10, 12, store.TrimLowestPriority, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, cleanup, err := testutil.NewTempStore(ctx, dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cleanup()
+
+			seeds := make([]string, tt.seeds)
+			for i := range seeds {
+				seeds[i] = fmt.Sprintf("https://example.com/seed/%d", i)
+			}
+			q, err := queue.NewSqlQueue(ctx, s, tt.bufSize, seeds, store.DefaultPriorityWeights(), tt.maxSize, tt.eviction)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			seen := make(map[string]bool)
+			for {
+				item, err := q.Dequeue()
+				if errors.Is(err, queue.ErrorFrontierEmpty) {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if seen[item.UrlNorm] {
+					t.Fatalf("%s dequeued twice", item.UrlNorm)
+				}
+				seen[item.UrlNorm] = true
+			}
+			if len(seen) != tt.want {
+				t.Errorf("dequeued %d seeds, want %d", len(seen), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package rank
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jdpolicano/go-search/internal/store"
+)
+
+// RankerConfig holds the retry and timeout settings for the ranking phases.
+type RankerConfig struct {
+	MaxRetries    int                      // Retries per phase after the first attempt fails
+	BaseDelay     time.Duration            // Delay before the first retry, doubled for each later retry
+	MaxDelay      time.Duration            // Upper bound on the delay between retries
+	PhaseTimeout  time.Duration            // Deadline for a single attempt of any phase
+	PhaseTimeouts map[string]time.Duration // Per-phase deadlines overriding PhaseTimeout
+	WriteLimiter  *store.WriteLimiter      // Shared with an indexer, across processes if made by NewSharedWriteLimiter; nil disables
+	TFScheme      store.TFScheme           // Term frequency weighting used for document norms
+	MinDF         int                      // Terms in fewer documents are left out of norms; 0 keeps every term
+}
+
+// DefaultRankerConfig returns a RankerConfig populated with the default settings.
+func DefaultRankerConfig() RankerConfig {
+	return RankerConfig{
+		MaxRetries:   5,
+		BaseDelay:    100 * time.Millisecond,
+		MaxDelay:     5 * time.Second,
+		PhaseTimeout: 5 * time.Minute,
+		TFScheme:     store.TFLog,
+	}
+}
+
+// Validate checks that the configuration is usable.
+func (cfg RankerConfig) Validate() error {
+	if cfg.MaxRetries < 0 {
+		return errors.New("ranker max retries must not be negative")
+	}
+	if cfg.BaseDelay <= 0 {
+		return errors.New("ranker base delay must be positive")
+	}
+	if cfg.MaxDelay < cfg.BaseDelay {
+		return errors.New("ranker max delay must not be less than the base delay")
+	}
+	if cfg.PhaseTimeout <= 0 {
+		return errors.New("ranker phase timeout must be positive")
+	}
+	if cfg.MinDF < 0 {
+		return errors.New("ranker min df must not be negative")
+	}
+	if err := cfg.TFScheme.Validate(); err != nil {
+		return err
+	}
+	return nil
+}
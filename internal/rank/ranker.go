@@ -10,35 +10,48 @@ import (
 )
 
 type Ranker struct {
-	store      store.Store
-	logger     *slog.Logger
-	interval   time.Duration
-	maxRetries int
-	baseDelay  time.Duration
+	store    store.Store
+	logger   *slog.Logger
+	interval time.Duration
+	cfg      RankerConfig
+	lastRun  *store.IndexWatermark // Index state as of the last successful update
 }
 
-func NewRanker(store store.Store, logger *slog.Logger, interval time.Duration) *Ranker {
+// NewRanker creates a Ranker that updates rankings every interval, returning an
+// error if the configuration is invalid.
+func NewRanker(store store.Store, logger *slog.Logger, interval time.Duration, cfg RankerConfig) (*Ranker, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 	return &Ranker{
-		store:      store,
-		logger:     logger,
-		interval:   interval,
-		maxRetries: 5,
-		baseDelay:  100 * time.Millisecond,
+		store:    store,
+		logger:   logger,
+		interval: interval,
+		cfg:      cfg,
+	}, nil
+}
+
+// phaseTimeout returns the deadline for a single attempt of the named phase.
+// A stuck attempt is canceled when the deadline passes and then retried with backoff.
+func (r *Ranker) phaseTimeout(phase string) time.Duration {
+	if timeout, ok := r.cfg.PhaseTimeouts[phase]; ok && timeout > 0 {
+		return timeout
 	}
+	return r.cfg.PhaseTimeout
 }
 
 func (r *Ranker) retryWithBackoff(ctx context.Context, phase string, operation func(context.Context) error) error {
 	var lastErr error
 
-	for attempt := 0; attempt <= r.maxRetries; attempt++ {
+	for attempt := 0; attempt <= r.cfg.MaxRetries; attempt++ {
 		if attempt > 0 {
-			delay := time.Duration(float64(r.baseDelay) * math.Pow(2, float64(attempt-1)))
-			delay = min(delay, 5*time.Second) // Cap at 5 seconds
+			delay := time.Duration(float64(r.cfg.BaseDelay) * math.Pow(2, float64(attempt-1)))
+			delay = min(delay, r.cfg.MaxDelay)
 
 			r.logger.Warn("Retrying ranking phase after error",
 				"phase", phase,
 				"attempt", attempt,
-				"maxRetries", r.maxRetries,
+				"maxRetries", r.cfg.MaxRetries,
 				"delay", delay,
 				"lastError", lastErr)
 
@@ -50,9 +63,21 @@ func (r *Ranker) retryWithBackoff(ctx context.Context, phase string, operation f
 			}
 		}
 
-		if err := operation(ctx); err != nil {
+		if err := r.cfg.WriteLimiter.Acquire(ctx); err != nil {
+			return err
+		}
+		opCtx, cancel := context.WithTimeout(ctx, r.phaseTimeout(phase))
+		err := operation(opCtx)
+		cancel()
+		r.cfg.WriteLimiter.Release(err)
+
+		if err != nil {
 			lastErr = err
-			if attempt < r.maxRetries {
+			// Parent cancellation is final; only our own deadline is worth retrying.
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if attempt < r.cfg.MaxRetries {
 				r.logger.Error("Ranking phase failed",
 					"phase", phase,
 					"attempt", attempt+1,
@@ -95,9 +120,65 @@ func (r *Ranker) Start(ctx context.Context) error {
 	}
 }
 
+// ForceRun recomputes all rankings even if nothing was indexed since the last run.
+func (r *Ranker) ForceRun(ctx context.Context) error {
+	r.logger.Info("Running forced ranking update...")
+	return r.runPhases(ctx)
+}
+
+// Explain logs how many rows each ranking phase would update with the configured TF
+// scheme and min df, along with its query plan, without running any of the updates.
+func (r *Ranker) Explain(ctx context.Context) ([]store.PhaseEstimate, error) {
+	estimates, err := store.ExplainRankingUpdates(ctx, r.store.Pool, r.cfg.TFScheme, r.cfg.MinDF)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, est := range estimates {
+		r.logger.Info("Ranking phase estimate",
+			"phase", est.Phase,
+			"rows", est.Rows,
+			"plan", est.Plan)
+	}
+	return estimates, nil
+}
+
+// updateRankings recomputes rankings unless the index is unchanged since the
+// previous successful run, in which case it returns without issuing any updates.
 func (r *Ranker) updateRankings(ctx context.Context) error {
+	current, err := store.GetIndexWatermark(ctx, r.store.Pool)
+	if err != nil {
+		return err
+	}
+
+	if r.lastRun != nil && *r.lastRun == current {
+		r.logger.Info("Index unchanged since last ranking update, skipping",
+			"maxDocId", current.MaxDocId,
+			"docCount", current.DocCount,
+			"revisions", current.Revisions)
+		return nil
+	}
+
+	return r.runPhases(ctx)
+}
+
+// runPhases executes every ranking phase and records the index watermark on success.
+func (r *Ranker) runPhases(ctx context.Context) error {
 	start := time.Now()
 
+	// Capture the watermark before the phases so documents indexed mid-run trigger the next update.
+	watermark, err := store.GetIndexWatermark(ctx, r.store.Pool)
+	if err != nil {
+		return err
+	}
+
+	r.logger.Info("Phase 0: Updating corpus statistics...")
+	if err := r.retryWithBackoff(ctx, "corpus_stats", func(ctx context.Context) error {
+		return store.UpdateCorpusStats(ctx, r.store.Pool)
+	}); err != nil {
+		return err
+	}
+
 	r.logger.Info("Phase 1: Updating document frequencies...")
 	if err := r.retryWithBackoff(ctx, "document_frequency", func(ctx context.Context) error {
 		return store.UpdateDocumentFrequency(ctx, r.store.Pool)
@@ -112,13 +193,14 @@ func (r *Ranker) updateRankings(ctx context.Context) error {
 		return err
 	}
 
-	r.logger.Info("Phase 3: Updating document norms...")
+	r.logger.Info("Phase 3: Updating document norms...", "tfScheme", r.cfg.TFScheme, "minDF", r.cfg.MinDF)
 	if err := r.retryWithBackoff(ctx, "document_norms", func(ctx context.Context) error {
-		return store.UpdateDocumentNorms(ctx, r.store.Pool)
+		return store.UpdateDocumentNormsMinDF(ctx, r.store.Pool, r.cfg.TFScheme, r.cfg.MinDF)
 	}); err != nil {
 		return err
 	}
 
+	r.lastRun = &watermark
 	duration := time.Since(start)
 	r.logger.Info("Ranking update completed", "duration", duration)
 	return nil
@@ -0,0 +1,188 @@
+package rank
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jdpolicano/go-search/internal/store"
+	"github.com/jdpolicano/go-search/internal/store/testutil"
+)
+
+func TestRetryWithBackoffPhaseTimeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		slowAttempts int // Attempts that hang until their deadline
+		maxRetries   int
+		phaseTimeout map[string]time.Duration
+		wantAttempts int
+		wantErr      error
+	}{
+		{"fast phase", 0, 2, nil, 1, nil},
+		{"slow phase retried", 1, 2, nil, 2, nil},
+		{"slow until last retry", 2, 2, nil, 3, nil},
+		{"always slow", 5, 2, nil, 3, context.DeadlineExceeded},
+		{"per-phase timeout", 1, 1, map[string]time.Duration{"phase": 5 * time.Millisecond}, 2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultRankerConfig()
+			cfg.MaxRetries = tt.maxRetries
+			cfg.BaseDelay = time.Millisecond
+			cfg.MaxDelay = time.Millisecond
+			cfg.PhaseTimeout = 20 * time.Millisecond
+			cfg.PhaseTimeouts = tt.phaseTimeout
+			r, err := NewRanker(store.Store{}, slog.New(slog.NewTextHandler(io.Discard, nil)), time.Minute, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			attempts := 0
+			err = r.retryWithBackoff(context.Background(), "phase", func(ctx context.Context) error {
+				attempts++
+				if attempts <= tt.slowAttempts {
+					<-ctx.Done()
+					return ctx.Err()
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("%d attempts, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryWithBackoffParentCanceled(t *testing.T) {
+	cfg := DefaultRankerConfig()
+	cfg.BaseDelay = time.Millisecond
+	r, err := NewRanker(store.Store{}, slog.New(slog.NewTextHandler(io.Discard, nil)), time.Minute, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	err = r.retryWithBackoff(ctx, "phase", func(ctx context.Context) error {
+		attempts++
+		cancel()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) || attempts != 1 {
+		t.Errorf("err = %v after %d attempts, want %v after 1", err, attempts, context.Canceled)
+	}
+}
+
+// updateCounter is a pgx query tracer counting the statements run through a pool that
+// update rows, including upserts and UPDATEs inside a WITH.
+type updateCounter struct {
+	mu      sync.Mutex
+	updates int
+}
+
+func (c *updateCounter) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if strings.Contains(strings.ToUpper(data.SQL), "UPDATE") {
+		c.mu.Lock()
+		c.updates++
+		c.mu.Unlock()
+	}
+	return ctx
+}
+
+func (c *updateCounter) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
+
+func (c *updateCounter) reset() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.updates
+	c.updates = 0
+	return n
+}
+
+func TestUpdateRankingsSkipsUnchangedIndex(t *testing.T) {
+	dsn, err := testutil.TestDSN()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name        string
+		change      func(ctx context.Context, db store.DBTX, id int64) error // Applied between the two ticks; nil changes nothing
+		wantUpdates bool
+	}{
+		{"unchanged", nil, false},
+		{"new document", func(ctx context.Context, db store.DBTX, _ int64) error {
+			_, err := testutil.SeedCorpus(ctx, db, []testutil.TestDoc{{ID: 100, Url: "https://example.com/new", Text: "fresh words"}})
+			return err
+		}, true},
+		{"re-indexed document", func(ctx context.Context, db store.DBTX, id int64) error {
+			_, err := store.DiffIndex(ctx, db, id, map[string]int{"edited": 1})
+			return err
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, cleanup, err := testutil.NewTempStore(ctx, dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cleanup()
+			ids, err := testutil.SeedCorpus(ctx, s.Pool, []testutil.TestDoc{{Url: "https://example.com/a", Text: "some indexed words"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Run the ranker through a pool that counts its UPDATEs, in the same scratch schema
+			var schema string
+			if err := s.Pool.QueryRow(ctx, "SELECT current_schema()").Scan(&schema); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := pgxpool.ParseConfig(dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg.ConnConfig.RuntimeParams["search_path"] = schema
+			counter := &updateCounter{}
+			cfg.ConnConfig.Tracer = counter
+			traced, err := pgxpool.NewWithConfig(ctx, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer traced.Close()
+
+			r, err := NewRanker(store.Store{Pool: traced}, slog.New(slog.NewTextHandler(io.Discard, nil)), time.Minute, DefaultRankerConfig())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := r.updateRankings(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if counter.reset() == 0 {
+				t.Fatal("first tick issued no updates")
+			}
+
+			if tt.change != nil {
+				if err := tt.change(ctx, s.Pool, ids[0]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := r.updateRankings(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if updates := counter.reset(); (updates > 0) != tt.wantUpdates {
+				t.Errorf("second tick issued %d updates, want updates %t", updates, tt.wantUpdates)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jdpolicano/go-search/internal/store"
+)
+
+// BoostRequest represents the JSON request for the /admin/boost endpoint
+type BoostRequest struct {
+	URL   string  `json:"url"`
+	Boost float64 `json:"boost"` // 1.0 is neutral
+}
+
+// authorizeAdmin checks the request's bearer token against ServerConfig.AdminToken,
+// sending the error response and returning false if it doesn't match. Admin
+// endpoints answer 404 when no token is configured, so they are off by default.
+func (s *Server) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.cfg.AdminToken == "" {
+		http.NotFound(w, r)
+		return false
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.AdminToken)) != 1 {
+		s.sendError(w, http.StatusUnauthorized, "Invalid admin token")
+		return false
+	}
+	return true
+}
+
+// handleBoost handles the /admin/boost POST endpoint, setting a document's static
+// score boost. It answers 404 if the url isn't indexed.
+func (s *Server) handleBoost(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		s.sendError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
+		return
+	}
+
+	var req BoostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, http.StatusBadRequest, "Invalid JSON request")
+		return
+	}
+	if req.URL == "" {
+		s.sendError(w, http.StatusBadRequest, "url field is required")
+		return
+	}
+	if req.Boost < 0 {
+		s.sendError(w, http.StatusBadRequest, "boost must not be negative")
+		return
+	}
+
+	// Boosts are written to the primary, not the read replica
+	err := store.SetDocBoost(r.Context(), s.store.Pool, req.URL, req.Boost)
+	if errors.Is(err, store.ErrorDocNotFound) {
+		s.sendError(w, http.StatusNotFound, "Document not found")
+		return
+	}
+	if err != nil {
+		s.logger.Error("Setting doc boost failed", "error", err, "url", req.URL)
+		s.sendSearchError(w, err)
+		return
+	}
+
+	s.logger.Info("Doc boost set", "url", req.URL, "boost", req.Boost)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(req)
+}
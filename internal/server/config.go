@@ -0,0 +1,31 @@
+package server
+
+// ServerConfig holds the tunable settings for the search server.
+type ServerConfig struct {
+	MaxQueryLength int // Maximum query length in bytes; longer queries are rejected
+	MaxQueryTerms  int // Maximum number of distinct typed terms searched; later terms in the query are dropped
+
+	// MaxPrefixExpansions caps the terms all of a query's prefix terms expand to
+	// together, on top of the per-prefix cap, so prefixes can't crowd out the typed
+	// terms. Later prefixes get what is left. 0 is unlimited.
+	MaxPrefixExpansions int
+
+	// EmptyQueryIsError controls how a query with no terms left after stop-word
+	// removal (e.g. "the and of") is answered: 400 when true, or 200 with no
+	// rankings when false.
+	EmptyQueryIsError bool
+
+	// AdminToken is the bearer token required by /admin endpoints. Empty disables them.
+	AdminToken string
+}
+
+// DefaultServerConfig returns a ServerConfig populated with safe defaults.
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		MaxQueryLength:      1024,
+		MaxQueryTerms:       32,
+		MaxPrefixExpansions: 40,
+
+		EmptyQueryIsError: true,
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/jdpolicano/go-search/internal/store"
+)
+
+// ErrInvalidCursor is returned for a cursor that is malformed or was issued for a
+// different query.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursorVersion prefixes every encoded cursor so the format can change without
+// misreading old ones; cursors of another version are rejected as invalid.
+const cursorVersion = "v1"
+
+// encodeCursor encodes a store cursor for the query with the given fingerprint.
+// Cursors are opaque to clients: the unpadded URL-safe base64 of
+//
+//	v1.<query fingerprint, hex>.<score>.<document id>
+//
+// The fingerprint hashes the raw query text, the ranking mode and its resolved params,
+// so a cursor only continues the request that issued it; any other query rejects it
+// with a 400. It doesn't depend on what the query expands to, and neither indexing
+// nor ranking invalidates it, so newly indexed terms matching a prefix such as
+// "comput*" are harmless. The next page holds the results ranked after its (score, id)
+// at the time of the request, so pages never repeat a result, but a document whose
+// score changed between requests can move across the boundary and be skipped or seen
+// again.
+func encodeCursor(c store.Cursor, fingerprint uint64) string {
+	raw := fmt.Sprintf("%s.%x.%s.%d", cursorVersion, fingerprint, strconv.FormatFloat(c.Score, 'g', -1, 64), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor decodes a cursor, checking it was issued for the query with the given fingerprint.
+func decodeCursor(token string, fingerprint uint64) (store.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return store.Cursor{}, ErrInvalidCursor
+	}
+	parts := strings.Split(string(raw), ".")
+	// The score may itself contain a '.', so it is everything between the fingerprint and id
+	if len(parts) < 4 || parts[0] != cursorVersion {
+		return store.Cursor{}, ErrInvalidCursor
+	}
+	issued, err := strconv.ParseUint(parts[1], 16, 64)
+	if err != nil {
+		return store.Cursor{}, ErrInvalidCursor
+	}
+	if issued != fingerprint {
+		return store.Cursor{}, fmt.Errorf("%w: issued for a different query", ErrInvalidCursor)
+	}
+	score, err := strconv.ParseFloat(strings.Join(parts[2:len(parts)-1], "."), 64)
+	if err != nil {
+		return store.Cursor{}, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+	if err != nil {
+		return store.Cursor{}, ErrInvalidCursor
+	}
+	return store.Cursor{Score: score, ID: id}, nil
+}
+
+// queryFingerprint identifies a request by its raw query, mode and resolved params.
+func queryFingerprint(query, mode string, params map[string]float64) uint64 {
+	d := xxhash.New()
+	d.WriteString(mode)
+	for _, name := range slices.Sorted(maps.Keys(params)) {
+		fmt.Fprintf(d, "\x00%s=%g", name, params[name])
+	}
+	d.WriteString("\x01" + query)
+	return d.Sum64()
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/jdpolicano/go-search/internal/store"
+)
+
+// defaultMode is the ranking mode used when a request doesn't name one.
+const defaultMode = "bm25"
+
+// ErrPhrasesUnsupported is returned by a Searcher whose mode can't match phrases.
+var ErrPhrasesUnsupported = errors.New("phrase queries are only supported in bm25 mode")
+
+// ErrExplainUnsupported is returned by a Searcher whose mode can't explain its scores.
+var ErrExplainUnsupported = errors.New("explain is only supported in bm25 mode")
+
+// Searcher runs a query for one ranking mode.
+type Searcher interface {
+	// Params returns the parameters the mode accepts and their default values.
+	Params() map[string]float64
+	// Search runs the query. Params has already been validated and defaulted, and
+	// after, if not nil, is the position to continue from. Phrases, whose words are
+	// also in terms, must each appear in a result; modes that can't check them
+	// return ErrPhrasesUnsupported when any are given, and modes that can't break
+	// scores down return ErrExplainUnsupported when explain is set. Prefixes maps the terms
+	// expanded from a prefix to it, see store.SearchOptions.Prefixes.
+	Search(ctx context.Context, db store.DBTX, terms []string, phrases [][]string, prefixes map[string]string, limit int, params map[string]float64, after *store.Cursor, explain bool) ([]store.SearchResult, error)
+}
+
+// searchers maps each ranking mode name to its Searcher.
+//
+// Accepted params per mode:
+//   - bm25:  k1 (term frequency saturation), b (length normalization, 0-1],
+//     min_match (distinct query terms a result must contain; 0 = auto),
+//     min_df (query terms in fewer documents are ignored; 0 = keep all)
+//   - bm25f: k1, title_boost, body_boost, title_b, body_b
+//   - cosine: none; uses the TF scheme the ranker computed norms with
+var searchers = map[string]Searcher{
+	"bm25":   bm25Searcher{},
+	"bm25f":  bm25fSearcher{},
+	"cosine": cosineSearcher{},
+}
+
+// resolveSearcher validates a request's mode and params, returning the Searcher and
+// the complete parameter set with defaults filled in. Errors list the valid options.
+func resolveSearcher(mode string, params map[string]float64) (Searcher, map[string]float64, error) {
+	if mode == "" {
+		mode = defaultMode
+	}
+
+	searcher, ok := searchers[mode]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown mode %q, valid modes: %s", mode, strings.Join(slices.Sorted(maps.Keys(searchers)), ", "))
+	}
+
+	resolved := searcher.Params()
+	for name, value := range params {
+		if _, ok := resolved[name]; !ok {
+			return nil, nil, fmt.Errorf("unknown param %q for mode %q, valid params: %s", name, mode, strings.Join(slices.Sorted(maps.Keys(resolved)), ", "))
+		}
+		if value < 0 {
+			return nil, nil, fmt.Errorf("param %q must not be negative", name)
+		}
+		resolved[name] = value
+	}
+
+	return searcher, resolved, nil
+}
+
+// bm25Searcher ranks with plain BM25 over document bodies.
+type bm25Searcher struct{}
+
+func (bm25Searcher) Params() map[string]float64 {
+	return map[string]float64{"k1": store.DefaultK1, "b": store.DefaultB, "min_match": 0, "min_df": 0}
+}
+
+func (bm25Searcher) Search(ctx context.Context, db store.DBTX, terms []string, phrases [][]string, prefixes map[string]string, limit int, params map[string]float64, after *store.Cursor, explain bool) ([]store.SearchResult, error) {
+	k1, b := params["k1"], params["b"]
+	return store.SearchBM25(ctx, db, terms, store.SearchOptions{
+		Limit:              limit,
+		Explain:            explain,
+		K1:                 &k1,
+		B:                  &b,
+		MinDistinctMatches: int(params["min_match"]),
+		MinDF:              int(params["min_df"]),
+		After:              after,
+		Phrases:            phrases,
+		Prefixes:           prefixes,
+	})
+}
+
+// bm25fSearcher ranks with BM25F, weighting title and body matches separately.
+type bm25fSearcher struct{}
+
+func (bm25fSearcher) Params() map[string]float64 {
+	d := store.DefaultBM25FOptions()
+	return map[string]float64{
+		"k1":          d.K1,
+		"title_boost": d.TitleBoost,
+		"body_boost":  d.BodyBoost,
+		"title_b":     d.TitleB,
+		"body_b":      d.BodyB,
+	}
+}
+
+func (bm25fSearcher) Search(ctx context.Context, db store.DBTX, terms []string, phrases [][]string, prefixes map[string]string, limit int, params map[string]float64, after *store.Cursor, explain bool) ([]store.SearchResult, error) {
+	if len(phrases) > 0 {
+		return nil, ErrPhrasesUnsupported
+	}
+	if explain {
+		return nil, ErrExplainUnsupported
+	}
+	return store.SearchBM25F(ctx, db, terms, store.BM25FOptions{
+		K1:         params["k1"],
+		TitleBoost: params["title_boost"],
+		BodyBoost:  params["body_boost"],
+		TitleB:     params["title_b"],
+		BodyB:      params["body_b"],
+		Limit:      limit,
+		After:      after,
+		Prefixes:   prefixes,
+	})
+}
+
+// cosineSearcher ranks with tf-idf cosine similarity.
+type cosineSearcher struct{}
+
+func (cosineSearcher) Params() map[string]float64 {
+	return map[string]float64{}
+}
+
+func (cosineSearcher) Search(ctx context.Context, db store.DBTX, terms []string, phrases [][]string, prefixes map[string]string, limit int, params map[string]float64, after *store.Cursor, explain bool) ([]store.SearchResult, error) {
+	if len(phrases) > 0 {
+		return nil, ErrPhrasesUnsupported
+	}
+	if explain {
+		return nil, ErrExplainUnsupported
+	}
+	return store.SearchCosine(ctx, db, terms, store.CosineOptions{Limit: limit, After: after})
+}
@@ -4,24 +4,68 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/jdpolicano/go-search/internal/extract"
+	"github.com/jdpolicano/go-search/internal/logging"
 	"github.com/jdpolicano/go-search/internal/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// maxPrefixExpansions caps how many indexed terms a single prefix query term
+// (e.g. "comput*") expands to. Expansions are chosen by descending document frequency.
+const maxPrefixExpansions = 20
+
+// statusClientClosedRequest is the non-standard status (popularized by nginx) logged
+// when the client goes away before its query finishes. The client never sees it.
+const statusClientClosedRequest = 499
+
 // QueryRequest represents the JSON request for the /query endpoint
 type QueryRequest struct {
-	Query string `json:"query"`
-	Limit int    `json:"limit,omitempty"`
+	Query   string `json:"query"`
+	Limit   int    `json:"limit,omitempty"`
+	Explain bool   `json:"explain,omitempty"`
+
+	// Highlights requests the rune offsets of matched terms within each result's
+	// snippet, for frontends that render highlighting themselves.
+	Highlights bool `json:"highlights,omitempty"`
+
+	// Mode selects the ranking function ("bm25" by default, or "bm25f") and
+	// Params overrides its tuning parameters; see searchers for what each accepts.
+	Mode   string             `json:"mode,omitempty"`
+	Params map[string]float64 `json:"params,omitempty"`
+
+	// Cursor continues from the page whose response returned it. It must be sent
+	// with the same query, mode and params; see encodeCursor for its semantics.
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// Validate checks the request against the server's limits before any work is done.
+func (req QueryRequest) Validate(cfg ServerConfig) error {
+	if req.Query == "" {
+		return errors.New("Query field is required")
+	}
+	if cfg.MaxQueryLength > 0 && len(req.Query) > cfg.MaxQueryLength {
+		return fmt.Errorf("Query exceeds maximum length of %d bytes", cfg.MaxQueryLength)
+	}
+	return nil
 }
 
 // QueryResponse represents the JSON response for the /query endpoint
 type QueryResponse struct {
 	Rankings []store.SearchResult `json:"rankings"`
+
+	// Cursor fetches the next page when sent back in QueryRequest.Cursor. It is
+	// omitted when the page was not full, as there are no more results.
+	Cursor string `json:"cursor,omitempty"`
 }
 
 // ErrorResponse represents an error response
@@ -32,14 +76,19 @@ type ErrorResponse struct {
 // Server represents the HTTP search server
 type Server struct {
 	store  store.Store
+	cfg    ServerConfig
+	tracer trace.Tracer
 	logger *slog.Logger
 	server *http.Server
 }
 
-// NewServer creates a new search server instance
-func NewServer(s store.Store, logger *slog.Logger) *Server {
+// NewServer creates a new search server instance. Queries are traced with tracer,
+// which may be nil to disable tracing.
+func NewServer(s store.Store, cfg ServerConfig, tracer trace.Tracer, logger *slog.Logger) *Server {
 	return &Server{
 		store:  s,
+		cfg:    cfg,
+		tracer: tracerOrNoop(tracer),
 		logger: logger,
 	}
 }
@@ -49,7 +98,10 @@ func (s *Server) Start(ctx context.Context) error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.handleRoot)
 	mux.HandleFunc("/query", s.handleQuery)
+	mux.HandleFunc("/related", s.handleRelated)
+	mux.HandleFunc("/doc/vector", s.handleDocVector)
 	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/admin/boost", s.handleBoost)
 	mux.HandleFunc("/static/", s.handleStatic)
 
 	s.server = &http.Server{
@@ -65,17 +117,32 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	return s.server.Shutdown(ctx)
 }
 
-// handleQuery handles the /query POST endpoint
+// handleQuery handles the /query POST endpoint. Its status codes are:
+//
+//   - 200 with the rankings, which may be empty.
+//   - 200 with no rankings when no terms survive stop-word removal, if
+//     ServerConfig.EmptyQueryIsError is false; otherwise 400.
+//   - 400 for malformed JSON, invalid fields, unknown modes or parameters,
+//     queries that fail to tokenize, cursors issued for another query, and
+//     quoted phrases in a mode other than bm25.
+//   - 405 for anything but POST.
+//   - 499 when the client cancels the request before the search completes.
+//   - 503 when the search runs past its deadline.
+//   - 500 for any other database failure.
 func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		s.sendError(w, http.StatusMethodNotAllowed, "Only POST method is allowed")
 		return
 	}
 
+	ctx, span := s.startRequestSpan(r, "search.query")
+	defer span.End()
+	logger := logging.WithContext(s.logger, ctx)
+
 	start := time.Now()
 	defer func() {
 		duration := time.Since(start)
-		s.logger.Info("Query processed", "duration", duration, "path", r.URL.Path, "method", r.Method)
+		logger.Info("Query processed", "duration", duration, "path", r.URL.Path, "method", r.Method)
 	}()
 
 	var req QueryRequest
@@ -84,8 +151,14 @@ func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Query == "" {
-		s.sendError(w, http.StatusBadRequest, "Query field is required")
+	if err := req.Validate(s.cfg); err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	searcher, params, err := resolveSearcher(req.Mode, req.Params)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -97,26 +170,131 @@ func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
 		limit = 100 // max limit
 	}
 
-	// Tokenize query using the same scanner as documents
-	terms, err := tokenizeQuery(req.Query)
-	if err != nil {
+	// Tokenize query using the same scanner as documents, keeping quoted phrases together
+	_, tokenizeSpan := s.tracer.Start(ctx, "search.tokenize")
+	plain, quoted := splitPhrases(req.Query)
+	terms, phrases, err := tokenizeQueryParts(stripPrefixQueryTerms(plain), quoted)
+	tokenizeSpan.SetAttributes(attribute.Int("search.terms", len(terms)), attribute.Int("search.phrases", len(phrases)))
+	tokenizeSpan.End()
+	if err != nil && !errors.Is(err, ErrNoQueryTerms) {
 		s.sendError(w, http.StatusBadRequest, "Failed to tokenize query: "+err.Error())
 		return
 	}
 
+	// Bound the size of the search to keep the query latency predictable. Typed terms
+	// are cut in query order, before prefix expansion, which has its own cap.
+	_, counts := store.NormalizeQueryTerms(terms)
+	terms = distinctTerms(terms)
+	if s.cfg.MaxQueryTerms > 0 && len(terms) > s.cfg.MaxQueryTerms {
+		logger.Warn("Query terms truncated", "query", req.Query, "terms", len(terms), "max", s.cfg.MaxQueryTerms)
+		terms = terms[:s.cfg.MaxQueryTerms]
+	}
+
+	// Expand prefix terms such as "comput*" into the indexed terms they match,
+	// remembering each expansion's prefix so the prefix counts once towards min-match
+	prefixes := prefixQueryTerms(plain)
+	typed := len(terms)
+	prefixOf := make(map[string]string)
+	for _, prefix := range prefixes {
+		expansions := maxPrefixExpansions
+		if s.cfg.MaxPrefixExpansions > 0 {
+			expansions = min(expansions, s.cfg.MaxPrefixExpansions-(len(terms)-typed))
+		}
+		if expansions <= 0 {
+			logger.Warn("Prefix expansions truncated", "query", req.Query, "prefix", prefix, "max", s.cfg.MaxPrefixExpansions)
+			break
+		}
+		expanded, err := store.ExpandPrefix(ctx, s.store.Reader(), prefix, expansions)
+		if err != nil {
+			logger.Error("Prefix expansion failed", "error", err, "prefix", prefix)
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "prefix expansion failed")
+			s.sendSearchError(w, err)
+			return
+		}
+		for _, term := range expanded {
+			if _, ok := prefixOf[term]; !ok && !slices.Contains(terms[:typed], term) {
+				prefixOf[term] = prefix
+			}
+		}
+		terms = append(terms, expanded...)
+	}
+
+	if len(terms) == 0 {
+		if s.cfg.EmptyQueryIsError {
+			s.sendError(w, http.StatusBadRequest, "Failed to tokenize query: "+ErrNoQueryTerms.Error())
+			return
+		}
+		s.sendResults(w, []store.SearchResult{}, "")
+		return
+	}
+
+	terms, _ = store.NormalizeQueryTerms(terms)
+
 	// log user query
-	s.logger.Info("User query tokenized", "query", terms)
+	logger.Info("User query tokenized", "query", terms, "counts", counts, "prefixes", prefixes, "phrases", phrases)
+
+	mode := req.Mode
+	if mode == "" {
+		mode = defaultMode
+	}
+	fingerprint := queryFingerprint(req.Query, mode, params)
+	var after *store.Cursor
+	if req.Cursor != "" {
+		cursor, err := decodeCursor(req.Cursor, fingerprint)
+		if err != nil {
+			s.sendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		after = &cursor
+	}
 
-	// Perform BM25 search
-	results, err := store.SearchBM25(r.Context(), s.store.Pool, terms, limit)
+	// Perform the search with the requested ranking mode
+	searchCtx, searchSpan := s.tracer.Start(ctx, "search.execute", trace.WithAttributes(
+		attribute.String("search.mode", req.Mode),
+		attribute.Int("search.terms", len(terms)),
+		attribute.Int("search.limit", limit),
+	))
+	results, err := searcher.Search(searchCtx, s.store.Reader(), terms, phrases, prefixOf, limit, params, after, req.Explain)
+	if errors.Is(err, ErrPhrasesUnsupported) || errors.Is(err, ErrExplainUnsupported) {
+		searchSpan.End()
+		s.sendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
-		s.logger.Error("BM25 search failed", "error", err, "query", req.Query, "terms", terms)
-		s.sendError(w, http.StatusInternalServerError, "Search failed")
+		searchSpan.RecordError(err)
+		searchSpan.SetStatus(codes.Error, "search failed")
+		searchSpan.End()
+		span.SetStatus(codes.Error, "search failed")
+		logger.Error("Search failed", "error", err, "query", req.Query, "terms", terms, "mode", req.Mode)
+		s.sendSearchError(w, err)
 		return
 	}
+	searchSpan.SetAttributes(attribute.Int("search.results", len(results)))
+	searchSpan.End()
+
+	if req.Highlights {
+		for i := range results {
+			if results[i].Snippet != nil {
+				results[i].Highlights = extract.HighlightRanges(*results[i].Snippet, terms)
+			}
+		}
+	}
 
+	var next string
+	if len(results) == limit {
+		next = encodeCursor(*store.NextCursor(results), fingerprint)
+	}
+
+	span.SetAttributes(attribute.Int("search.terms", len(terms)), attribute.Int("search.results", len(results)))
+	s.sendResults(w, results, next)
+}
+
+// sendResults sends a successful query response, with the cursor of the next page if any.
+func (s *Server) sendResults(w http.ResponseWriter, results []store.SearchResult, cursor string) {
 	response := QueryResponse{
 		Rankings: results,
+		Cursor:   cursor,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -124,6 +302,88 @@ func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// RelatedResponse represents the JSON response for the /related endpoint
+type RelatedResponse struct {
+	Term    string            `json:"term"`
+	Related []store.TermScore `json:"related"`
+}
+
+// handleRelated handles the /related GET endpoint, returning terms that co-occur with
+// ?term= more than chance would suggest, up to ?limit= (default 10, max 100).
+func (s *Server) handleRelated(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
+		return
+	}
+
+	raw := r.URL.Query().Get("term")
+	if raw == "" {
+		s.sendError(w, http.StatusBadRequest, "term parameter is required")
+		return
+	}
+
+	terms, err := TokenizeQuery(raw)
+	if err != nil {
+		s.sendError(w, http.StatusBadRequest, "Failed to tokenize term: "+err.Error())
+		return
+	}
+	if len(terms) != 1 {
+		s.sendError(w, http.StatusBadRequest, "term must be a single word")
+		return
+	}
+
+	limit := 10
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			s.sendError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, 100)
+	}
+
+	related, err := store.RelatedTerms(r.Context(), s.store.Reader(), terms[0], limit, store.DefaultRelatedSample)
+	if err != nil {
+		s.logger.Error("Related terms failed", "error", err, "term", terms[0])
+		s.sendSearchError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(RelatedResponse{Term: terms[0], Related: related})
+}
+
+// handleDocVector handles the /doc/vector GET endpoint, returning a document's
+// stored term weights for relevance debugging.
+func (s *Server) handleDocVector(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.sendError(w, http.StatusMethodNotAllowed, "Only GET method is allowed")
+		return
+	}
+
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || id <= 0 {
+		s.sendError(w, http.StatusBadRequest, "id must be a positive integer")
+		return
+	}
+
+	vector, err := store.GetDocumentVector(r.Context(), s.store.Reader(), id)
+	if errors.Is(err, store.ErrorDocNotFound) {
+		s.sendError(w, http.StatusNotFound, "Document not found")
+		return
+	}
+	if err != nil {
+		s.logger.Error("Document vector failed", "error", err, "id", id)
+		s.sendSearchError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(vector)
+}
+
 // handleHealth handles the /health endpoint
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -162,10 +422,133 @@ func (s *Server) sendError(w http.ResponseWriter, statusCode int, message string
 	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
 }
 
+// sendSearchError sends the error response for a failed search, distinguishing
+// client cancellation and timeouts from genuine server errors.
+func (s *Server) sendSearchError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		s.sendError(w, statusClientClosedRequest, "Request canceled")
+	case errors.Is(err, context.DeadlineExceeded):
+		s.sendError(w, http.StatusServiceUnavailable, "Search timed out")
+	default:
+		s.sendError(w, http.StatusInternalServerError, "Search failed")
+	}
+}
+
+// prefixQueryTerms returns the tokenized stems of every query word ending in '*'.
+//
+// Each prefix expands to up to maxPrefixExpansions terms. For the minimum-match rule
+// a prefix counts as one term however many of its expansions a document contains, so
+// "comput*" alone matches any document with one expansion, and "comput* history"
+// needs an expansion and "history". An expansion that was also typed counts on its own.
+func prefixQueryTerms(query string) []string {
+	var prefixes []string
+	for _, field := range strings.Fields(query) {
+		if !strings.HasSuffix(field, "*") {
+			continue
+		}
+		words, err := extract.ScanWordsFromString(strings.TrimSuffix(field, "*"))
+		if err != nil || len(words) == 0 {
+			continue
+		}
+		prefixes = append(prefixes, words[len(words)-1])
+	}
+	return prefixes
+}
+
+// distinctTerms returns terms without repeats, keeping the first occurrence of each in
+// query order.
+func distinctTerms(terms []string) []string {
+	seen := make(map[string]struct{}, len(terms))
+	unique := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if _, ok := seen[term]; !ok {
+			seen[term] = struct{}{}
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
+
+// stripPrefixQueryTerms removes the words ending in '*' from a query, leaving the plain terms.
+func stripPrefixQueryTerms(query string) string {
+	fields := strings.Fields(query)
+	plain := fields[:0]
+	for _, field := range fields {
+		if !strings.HasSuffix(field, "*") {
+			plain = append(plain, field)
+		}
+	}
+	return strings.Join(plain, " ")
+}
+
+// phraseQuotes maps typographic double quotes, as typed on many phones, to plain ones.
+var phraseQuotes = strings.NewReplacer("\u201c", `"`, "\u201d", `"`)
+
+// splitPhrases separates the double-quoted phrases of a query from its plain text,
+// e.g. `"computer science" history` into " history" and ["computer science"]. An
+// unterminated quote runs to the end of the query.
+func splitPhrases(query string) (plain string, quoted []string) {
+	query = phraseQuotes.Replace(query)
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(query, '"')
+		if start < 0 {
+			b.WriteString(query)
+			return b.String(), quoted
+		}
+		b.WriteString(query[:start])
+		b.WriteByte(' ')
+		query = query[start+1:]
+
+		end := strings.IndexByte(query, '"')
+		if end < 0 {
+			return b.String(), append(quoted, query)
+		}
+		quoted = append(quoted, query[:end])
+		query = query[end+1:]
+	}
+}
+
+// ParseQuery tokenizes a query like TokenizeQuery, and also returns its quoted
+// phrases, whose words must appear next to each other in a result. Phrase words are
+// included in terms, and a quoted single word is only a term. Stop words are dropped
+// from phrases as from documents, so "university of london" matches the words
+// "university" and "london" adjacent once "of" is removed.
+func ParseQuery(query string) (terms []string, phrases [][]string, err error) {
+	return tokenizeQueryParts(splitPhrases(query))
+}
+
+// tokenizeQueryParts tokenizes the plain text and quoted phrases of a query, as split
+// by splitPhrases, returning ErrNoQueryTerms when neither yields a term.
+func tokenizeQueryParts(plain string, quoted []string) (terms []string, phrases [][]string, err error) {
+	terms, err = TokenizeQuery(plain)
+	if err != nil && !errors.Is(err, ErrNoQueryTerms) {
+		return nil, nil, err
+	}
+	for _, phrase := range quoted {
+		words, err := extract.ScanWordsFromString(phrase)
+		if err != nil {
+			return nil, nil, err
+		}
+		terms = append(terms, words...)
+		if len(words) > 1 {
+			phrases = append(phrases, words)
+		}
+	}
+	if len(terms) == 0 {
+		return nil, nil, ErrNoQueryTerms
+	}
+	return terms, phrases, nil
+}
+
+// ErrNoQueryTerms is returned by TokenizeQuery when nothing survives tokenization.
+var ErrNoQueryTerms = errors.New("no valid terms found in query")
+
 // TokenizeQuery uses the same scanner as document processing to tokenize a query
-func tokenizeQuery(query string) ([]string, error) {
+func TokenizeQuery(query string) ([]string, error) {
 	if query == "" {
-		return nil, errors.New("query cannot be empty")
+		return nil, ErrNoQueryTerms
 	}
 
 	terms, err := extract.ScanWordsFromString(query)
@@ -174,7 +557,7 @@ func tokenizeQuery(query string) ([]string, error) {
 	}
 
 	if len(terms) == 0 {
-		return nil, errors.New("no valid terms found in query")
+		return nil, ErrNoQueryTerms
 	}
 
 	return terms, nil
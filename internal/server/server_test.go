@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jdpolicano/go-search/internal/store"
+	"github.com/jdpolicano/go-search/internal/store/testutil"
+)
+
+func newTestServer(s store.Store, cfg ServerConfig) *Server {
+	return NewServer(s, cfg, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestHandleQueryStatus(t *testing.T) {
+	tests := []struct {
+		name              string
+		method            string
+		body              string
+		emptyQueryIsError bool
+		wantStatus        int
+		wantBody          string // Substring of the response body
+	}{
+		{"wrong method", http.MethodGet, "", true, http.StatusMethodNotAllowed, "POST"},
+		{"malformed json", http.MethodPost, `{"query":`, true, http.StatusBadRequest, "Invalid JSON"},
+		{"missing query", http.MethodPost, `{}`, true, http.StatusBadRequest, "required"},
+		{"query too long", http.MethodPost, fmt.Sprintf(`{"query":%q}`, strings.Repeat("a", 2000)), true, http.StatusBadRequest, "maximum length"},
+		{"unknown mode", http.MethodPost, `{"query":"go","mode":"pagerank"}`, true, http.StatusBadRequest, "unknown mode"},
+		{"unknown param", http.MethodPost, `{"query":"go","params":{"z":1}}`, true, http.StatusBadRequest, "unknown param"},
+		{"all stop words as error", http.MethodPost, `{"query":"the and of"}`, true, http.StatusBadRequest, ErrNoQueryTerms.Error()},
+		{"all stop words as empty", http.MethodPost, `{"query":"the and of"}`, false, http.StatusOK, `"rankings":[]`},
+		{"foreign cursor", http.MethodPost, `{"query":"go language","cursor":"garbage"}`, true, http.StatusBadRequest, "cursor"},
+		{"phrase in cosine", http.MethodPost, `{"query":"\"computer science\"","mode":"cosine"}`, true, http.StatusBadRequest, ErrPhrasesUnsupported.Error()},
+		{"explain in cosine", http.MethodPost, `{"query":"go language","mode":"cosine","explain":true}`, true, http.StatusBadRequest, ErrExplainUnsupported.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultServerConfig()
+			cfg.EmptyQueryIsError = tt.emptyQueryIsError
+			s := newTestServer(store.Store{}, cfg)
+
+			rec := httptest.NewRecorder()
+			s.handleQuery(rec, httptest.NewRequest(tt.method, "/query", strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body %q does not contain %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSendSearchError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"canceled", context.Canceled, statusClientClosedRequest},
+		{"wrapped canceled", fmt.Errorf("query: %w", context.Canceled), statusClientClosedRequest},
+		{"deadline", context.DeadlineExceeded, http.StatusServiceUnavailable},
+		{"wrapped deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusServiceUnavailable},
+		{"database error", errors.New("relation \"docs\" does not exist"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			newTestServer(store.Store{}, DefaultServerConfig()).sendSearchError(rec, tt.err)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestHandleQueryStatusWithStore(t *testing.T) {
+	dsn, err := testutil.TestDSN()
+	if err != nil {
+		t.Skip(err)
+	}
+	ctx := context.Background()
+	s, cleanup, err := testutil.NewTempStore(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	if _, err := testutil.SeedCorpus(ctx, s.Pool, []testutil.TestDoc{{Url: "https://example.com/go", Title: "Go", Text: "go is a programming language"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	expired, cancelExpired := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancelExpired()
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		wantStatus int
+	}{
+		{"results", ctx, http.StatusOK},
+		{"client canceled", canceled, statusClientClosedRequest},
+		{"deadline passed", expired, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequestWithContext(tt.ctx, http.MethodPost, "/query", strings.NewReader(`{"query":"programming"}`))
+			newTestServer(s, DefaultServerConfig()).handleQuery(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		wantTerms   []string
+		wantPhrases [][]string
+		wantErr     error
+	}{
+		{"bag of words", "computer science", []string{"computer", "science"}, nil, nil},
+		{"quoted phrase", `"computer science"`, []string{"computer", "science"}, [][]string{{"computer", "science"}}, nil},
+		{"phrase and terms", `history "computer science" degree`, []string{"history", "degree", "computer", "science"}, [][]string{{"computer", "science"}}, nil},
+		{"stop words dropped from phrase", `"university of london"`, []string{"university", "london"}, [][]string{{"university", "london"}}, nil},
+		{"single quoted word", `"computer"`, []string{"computer"}, nil, nil},
+		{"unterminated quote", `history "computer science`, []string{"history", "computer", "science"}, [][]string{{"computer", "science"}}, nil},
+		{"curly quotes", "“computer science”", []string{"computer", "science"}, [][]string{{"computer", "science"}}, nil},
+		{"only stop words", `"of the"`, nil, nil, ErrNoQueryTerms},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			terms, phrases, err := ParseQuery(tt.query)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !slices.Equal(terms, tt.wantTerms) {
+				t.Errorf("terms %q, want %q", terms, tt.wantTerms)
+			}
+			if !slices.EqualFunc(phrases, tt.wantPhrases, slices.Equal) {
+				t.Errorf("phrases %q, want %q", phrases, tt.wantPhrases)
+			}
+		})
+	}
+}
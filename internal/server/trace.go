@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jdpolicano/go-search/internal/logging"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// correlationIDHeader is the request header a caller may set to choose the
+// correlation id attached to its logs and spans.
+const correlationIDHeader = "X-Correlation-ID"
+
+// tracePropagator extracts W3C trace context and baggage from incoming headers.
+var tracePropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// tracerOrNoop returns tracer, or a tracer that records nothing if it is nil.
+func tracerOrNoop(tracer trace.Tracer) trace.Tracer {
+	if tracer == nil {
+		return noop.NewTracerProvider().Tracer("")
+	}
+	return tracer
+}
+
+// startRequestSpan continues the caller's trace, if any, with a span for the request,
+// and attaches a correlation id to the returned context. The id comes from the
+// X-Correlation-ID header when present, and otherwise is the trace id, so logs
+// can be joined with their trace.
+func (s *Server) startRequestSpan(r *http.Request, name string) (context.Context, trace.Span) {
+	ctx := tracePropagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := s.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+
+	correlationID := r.Header.Get(correlationIDHeader)
+	if correlationID == "" && span.SpanContext().HasTraceID() {
+		correlationID = span.SpanContext().TraceID().String()
+	}
+	if correlationID != "" {
+		ctx = logging.WithCorrelationID(ctx, correlationID)
+	}
+	return ctx, span
+}
@@ -0,0 +1,103 @@
+// Package store provides migration of documents indexed before dedup metadata existed.
+package store
+
+import (
+	"context"
+)
+
+// select docs with missing dedup metadata, along with their stored text if any
+const getDocsMissingMetadataStmt = `SELECT d.id, d.url, (d.domain IS NULL OR d.domain = ''), d.hash IS NULL, t.body
+FROM docs d
+LEFT JOIN document_text t ON t.doc_id = d.id
+WHERE d.id > $1
+  AND (d.domain IS NULL OR d.domain = '' OR d.hash IS NULL)
+ORDER BY d.id
+LIMIT $2;`
+
+// set whichever of domain and hash were recomputed, keeping existing values otherwise
+const updateDocMetadataStmt = `UPDATE docs
+SET domain = COALESCE($2, domain), hash = COALESCE($3, hash)
+WHERE id = $1;`
+
+// legacyDoc is a document whose domain or hash needs backfilling.
+type legacyDoc struct {
+	id          int64
+	url         string
+	needsDomain bool
+	needsHash   bool
+	body        []byte // compressed document text, nil if it was never stored
+}
+
+// BackfillDocMetadataBatch fills in the domain and content hash of up to limit
+// documents with an id greater than afterId that are missing either. Domains are
+// recomputed from the url. Hashes are recomputed by hashText from the stored
+// document text when there is some, and otherwise left NULL. A recomputed hash
+// that collides with another document on the same domain is also left NULL.
+//
+// It returns how many documents were updated and the last id examined, which is
+// the afterId for the next batch. A lastId equal to afterId means nothing is left.
+func BackfillDocMetadataBatch(ctx context.Context, db DBTX, afterId int64, limit int, hashText func(text string) (string, error)) (updated int, lastId int64, err error) {
+	docs, err := getDocsMissingMetadata(ctx, db, afterId, limit)
+	if err != nil {
+		return 0, afterId, err
+	}
+
+	lastId = afterId
+	for _, doc := range docs {
+		lastId = doc.id
+
+		var domain, hash *string
+		if doc.needsDomain {
+			d, err := GetHostame(doc.url)
+			if err != nil {
+				return updated, lastId, err
+			}
+			domain = &d
+		}
+		if doc.needsHash && doc.body != nil && hashText != nil {
+			text, err := decompressText(doc.body)
+			if err != nil {
+				return updated, lastId, err
+			}
+			h, err := hashText(text)
+			if err != nil {
+				return updated, lastId, err
+			}
+			hash = &h
+		}
+		if domain == nil && hash == nil {
+			continue
+		}
+
+		_, err = db.Exec(ctx, updateDocMetadataStmt, doc.id, domain, hash)
+		if ErrorIsUniqueViolation(err) {
+			// Duplicate content on the same domain; keep the domain but leave the hash NULL.
+			_, err = db.Exec(ctx, updateDocMetadataStmt, doc.id, domain, nil)
+		}
+		if err != nil {
+			return updated, lastId, err
+		}
+		updated++
+	}
+
+	return updated, lastId, nil
+}
+
+// getDocsMissingMetadata returns up to limit docs after afterId that lack a domain or hash.
+func getDocsMissingMetadata(ctx context.Context, db DBTX, afterId int64, limit int) ([]legacyDoc, error) {
+	rows, err := db.Query(ctx, getDocsMissingMetadataStmt, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []legacyDoc
+	for rows.Next() {
+		var doc legacyDoc
+		if err := rows.Scan(&doc.id, &doc.url, &doc.needsDomain, &doc.needsHash, &doc.body); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
@@ -0,0 +1,55 @@
+// Package store provides static per-document score boosts for editorial ranking control.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// BoostMode selects how a document's boost is combined with its relevance score.
+type BoostMode string
+
+const (
+	// BoostMultiply multiplies the score by the boost. It is the default.
+	BoostMultiply BoostMode = "multiply"
+	// BoostAdd adds boost - 1 to the score, so the neutral boost of 1.0 has no
+	// effect in either mode.
+	BoostAdd BoostMode = "add"
+)
+
+// Validate reports whether the mode is known. The empty mode is BoostMultiply.
+func (m BoostMode) Validate() error {
+	switch m {
+	case "", BoostMultiply, BoostAdd:
+		return nil
+	}
+	return fmt.Errorf("unknown boost mode %q, valid modes: %s, %s", m, BoostMultiply, BoostAdd)
+}
+
+// ErrorDocNotFound is returned when a referenced document isn't indexed.
+var ErrorDocNotFound = errors.New("document not found")
+
+// setDocBoostStmt upserts the boost for the document with the given url.
+// Returns no row if the url isn't indexed.
+const setDocBoostStmt = `INSERT INTO doc_boost (doc_id, boost)
+SELECT id, $2 FROM docs WHERE url = $1
+ON CONFLICT (doc_id) DO UPDATE SET boost = EXCLUDED.boost
+RETURNING doc_id;`
+
+// SetDocBoost sets the static score boost for the document at url; documents without
+// one are scored as if their boost were 1.0. It returns ErrorDocNotFound if url isn't indexed.
+func SetDocBoost(ctx context.Context, db DBTX, url string, boost float64) error {
+	if boost < 0 {
+		return errors.New("boost must not be negative")
+	}
+
+	var docId int64
+	err := db.QueryRow(ctx, setDocBoostStmt, url, boost).Scan(&docId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrorDocNotFound
+	}
+	return err
+}
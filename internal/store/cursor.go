@@ -0,0 +1,38 @@
+// Package store provides keyset ("search after") pagination of ranked results.
+package store
+
+// Cursor is the position of the last result of a page in a ranking ordered by score
+// descending, then document id descending. Passing it to the next search continues
+// strictly after that result, so pages don't shift or repeat when documents are
+// indexed between requests, and deep pages cost no more than the first.
+type Cursor struct {
+	Score float64 // Score of the last result returned
+	ID    int64   // Document id of the last result returned, breaking score ties
+}
+
+// NextCursor returns the cursor continuing after results, or nil if there are none.
+func NextCursor(results []SearchResult) *Cursor {
+	if len(results) == 0 {
+		return nil
+	}
+	last := results[len(results)-1]
+	return &Cursor{Score: last.Score, ID: last.ID}
+}
+
+// args returns the cursor's score and id as statement arguments, both NULL for a
+// nil cursor so the keyset condition matches every row.
+func (c *Cursor) args() (score *float64, id *int64) {
+	if c == nil {
+		return nil, nil
+	}
+	return &c.Score, &c.ID
+}
+
+// after reports whether a result ranked with score and id comes after the cursor.
+// A nil cursor is before every result.
+func (c *Cursor) after(score float64, id int64) bool {
+	if c == nil {
+		return true
+	}
+	return score < c.Score || (score == c.Score && id < c.ID)
+}
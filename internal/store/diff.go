@@ -0,0 +1,220 @@
+// Package store provides diff-based re-indexing of changed documents.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// get the stored body and title frequencies and positions of every term of a document
+const getDocPostingsStmt = `SELECT t.raw, p.term_id, p.tf_raw, p.tf_title, p.positions
+FROM postings p
+JOIN terms t ON t.id = p.term_id
+WHERE p.doc_id = $1;`
+
+// delete postings of terms no longer in the document body or title
+const deletePostingsStmt = `DELETE FROM postings WHERE doc_id = $1 AND term_id = ANY($2::int[]);`
+
+// forget the document's positions, which a diff of frequencies can't keep accurate
+const clearPositionsStmt = `UPDATE postings SET positions = NULL WHERE doc_id = $1 AND positions IS NOT NULL;`
+
+// keep the document length in step with its new body, and bump its revision for the ranker
+const updateDocLenStmt = `UPDATE docs SET len = $2, revision = revision + 1 WHERE id = $1;`
+
+// get the id a url is indexed under
+const getDocIdByUrlStmt = `SELECT id FROM docs WHERE url = $1;`
+
+// DiffStats counts the posting changes made by DiffIndex.
+type DiffStats struct {
+	Inserted  int // Terms new to the document
+	Updated   int // Terms whose frequencies or positions changed
+	Deleted   int // Terms no longer in the document
+	Unchanged int // Terms left as they were
+}
+
+// DiffIndex re-indexes the body of an existing document by comparing newFreqs with
+// its stored postings and writing only the terms that changed, which is far cheaper
+// than rewriting every posting when a re-crawled page has a small edit. Title
+// frequencies are kept; a term that leaves the body but is still in the title keeps
+// its posting with a body frequency of 0. Word positions can't be diffed from
+// frequencies, so they are cleared and the document won't match phrase queries
+// until it is fully re-indexed; ReindexDocumentCached diffs them too.
+//
+// The changes are applied atomically: in a transaction, or a savepoint if db is
+// already one. Like IndexDocumentInit it leaves df, idf and norms to the ranker.
+func DiffIndex(ctx context.Context, db DBTX, docId int64, newFreqs map[string]int) (stats DiffStats, err error) {
+	err = withTx(ctx, db, func(tx DBTX) error {
+		stats, _, err = diffPostings(ctx, tx, docId, postingDiff{body: newFreqs}, nil)
+		if err != nil {
+			return err
+		}
+
+		total := 0
+		for _, tf := range newFreqs {
+			total += tf
+		}
+		if _, err := tx.Exec(ctx, updateDocLenStmt, docId, total); err != nil {
+			return fmt.Errorf("failed to update document length: %w", err)
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// ReindexDocumentCached re-indexes a document already indexed under doc.Url in place,
+// like IndexDocumentCached but writing only the postings whose body or title frequency
+// or positions changed, as DiffIndex does. Its length, title, snippet and text are
+// refreshed. It reports false, changing nothing, if the URL isn't indexed yet.
+func ReindexDocumentCached(ctx context.Context, db DBTX, doc IndexEntry, cache *TermCache) (map[string]int64, bool, error) {
+	var docId int64
+	err := db.QueryRow(ctx, getDocIdByUrlStmt, doc.Url).Scan(&docId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Refresh the document's metadata, checking its new content for duplicates
+	if docId, err = insertDocumentInfo(ctx, db, doc); err != nil {
+		return nil, false, fmt.Errorf("failed to insert document info: %w", err)
+	}
+
+	_, resolved, err := diffPostings(ctx, db, docId, postingDiff{doc.TermFreqs, doc.TitleFreqs, doc.Positions}, cache)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if doc.Text != "" {
+		if err := insertDocumentText(ctx, db, docId, doc.Text); err != nil {
+			return nil, false, fmt.Errorf("failed to insert document text: %w", err)
+		}
+	}
+	return resolved, true, nil
+}
+
+// postingDiff is the new content of a document's postings.
+type postingDiff struct {
+	body      map[string]int   // Body frequency of each term
+	title     map[string]int   // Title frequency of each term; nil keeps the stored ones
+	positions map[string][]int // Body positions of each term; nil clears the stored ones
+}
+
+// storedPosting is a posting as read back for diffing.
+type storedPosting struct {
+	termId int64
+	freqs  fieldFreqs
+}
+
+// diffPostings brings a document's postings in line with d, writing only the terms
+// that changed, without managing a transaction. It returns the term ids of new terms
+// resolved from the database rather than cache.
+func diffPostings(ctx context.Context, db DBTX, docId int64, d postingDiff, cache *TermCache) (DiffStats, map[string]int64, error) {
+	stored, err := getDocPostings(ctx, db, docId)
+	if err != nil {
+		return DiffStats{}, nil, fmt.Errorf("failed to read postings: %w", err)
+	}
+
+	// want returns a term's new frequencies and positions, and whether it still has a posting
+	want := func(term string) (fieldFreqs, bool) {
+		f := fieldFreqs{body: d.body[term], positions: d.positions[term]}
+		if d.title != nil {
+			f.title = d.title[term]
+		} else if posting, ok := stored[term]; ok {
+			f.title = posting.freqs.title
+		}
+		return f, f.body > 0 || f.title > 0
+	}
+
+	var stats DiffStats
+	addedBody, addedTitle, addedPositions := make(map[string]int), make(map[string]int), make(map[string][]int)
+	changed := make(map[int64]fieldFreqs)
+	var deleteIds []int64
+	diff := func(term string) {
+		f, keep := want(term)
+		posting, ok := stored[term]
+		switch {
+		case !keep && ok:
+			deleteIds = append(deleteIds, posting.termId)
+		case !keep:
+			// Neither stored nor wanted, e.g. a title term with a title frequency of 0
+		case !ok:
+			addedBody[term], addedTitle[term], addedPositions[term] = f.body, f.title, f.positions
+		case posting.freqs.body != f.body || posting.freqs.title != f.title ||
+			(d.positions != nil && !slices.Equal(posting.freqs.positions, f.positions)):
+			changed[posting.termId] = f
+		default:
+			stats.Unchanged++
+		}
+	}
+
+	seen := make(map[string]bool, len(stored)+len(d.body))
+	for _, terms := range []map[string]int{d.body, d.title} {
+		for term := range terms {
+			if !seen[term] {
+				seen[term] = true
+				diff(term)
+			}
+		}
+	}
+	for term := range stored {
+		if !seen[term] {
+			diff(term)
+		}
+	}
+
+	var resolved map[string]int64
+	if len(addedBody) > 0 {
+		termIdFreqMap, ids, err := insertTerms(ctx, db, addedBody, addedTitle, addedPositions, cache)
+		if err != nil {
+			return DiffStats{}, nil, fmt.Errorf("failed to insert terms: %w", err)
+		}
+		if err := insertPostings(ctx, db, docId, termIdFreqMap); err != nil {
+			return DiffStats{}, nil, fmt.Errorf("failed to insert postings: %w", err)
+		}
+		stats.Inserted, resolved = len(termIdFreqMap), ids
+	}
+	if len(changed) > 0 {
+		if err := insertPostings(ctx, db, docId, changed); err != nil {
+			return DiffStats{}, nil, fmt.Errorf("failed to update postings: %w", err)
+		}
+		stats.Updated = len(changed)
+	}
+	if len(deleteIds) > 0 {
+		if _, err := db.Exec(ctx, deletePostingsStmt, docId, deleteIds); err != nil {
+			return DiffStats{}, nil, fmt.Errorf("failed to delete postings: %w", err)
+		}
+		stats.Deleted = len(deleteIds)
+	}
+
+	if d.positions == nil {
+		if _, err := db.Exec(ctx, clearPositionsStmt, docId); err != nil {
+			return DiffStats{}, nil, fmt.Errorf("failed to clear positions: %w", err)
+		}
+	}
+	return stats, resolved, nil
+}
+
+// getDocPostings returns a document's postings keyed by term.
+func getDocPostings(ctx context.Context, db DBTX, docId int64) (map[string]storedPosting, error) {
+	rows, err := db.Query(ctx, getDocPostingsStmt, docId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	postings := make(map[string]storedPosting)
+	for rows.Next() {
+		var term string
+		var posting storedPosting
+		if err := rows.Scan(&term, &posting.termId, &posting.freqs.body, &posting.freqs.title, &posting.freqs.positions); err != nil {
+			return nil, err
+		}
+		postings[term] = posting
+	}
+	return postings, rows.Err()
+}
@@ -0,0 +1,49 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jdpolicano/go-search/internal/store"
+	"github.com/jdpolicano/go-search/internal/store/testutil"
+)
+
+// BenchmarkDiffIndex measures re-indexing a large document after a one word edit,
+// alternating between two versions so every iteration has a change to write.
+func BenchmarkDiffIndex(b *testing.B) {
+	dsn, err := testutil.TestDSN()
+	if err != nil {
+		b.Skip(err)
+	}
+	ctx := context.Background()
+	s, cleanup, err := testutil.NewTempStore(ctx, dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer cleanup()
+
+	words := make([]string, 2000)
+	for i := range words {
+		words[i] = fmt.Sprintf("word%d", i%500)
+	}
+	ids, err := testutil.SeedCorpus(ctx, s.Pool, []testutil.TestDoc{{Url: "https://example.com/long", Text: strings.Join(words, " ")}})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	versions := [2]map[string]int{make(map[string]int), make(map[string]int)}
+	for _, word := range words {
+		versions[0][word]++
+		versions[1][word]++
+	}
+	versions[1]["edited"] = 1
+
+	b.ResetTimer()
+	for i := range b.N {
+		if _, err := store.DiffIndex(ctx, s.Pool, ids[0], versions[i%2]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
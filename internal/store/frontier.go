@@ -2,17 +2,21 @@
 package store
 
 import (
+	"cmp"
 	"context"
+	"slices"
 
 	"github.com/jackc/pgx/v5"
 )
 
-const insertFIBatchStmt = `INSERT INTO frontier (url, url_norm, parent_url, depth, status)
-SELECT fi.url, fi.url_norm, fi.parent_url, fi.depth, fi.status
-FROM unnest($1::text[], $2::text[], $3::text[], $4::int[], $5::int[])
-	 AS fi(url, url_norm, parent_url, depth, status)
-ON CONFLICT (url_norm) DO NOTHING
-RETURNING url, url_norm, parent_url, depth, status;`
+// insert a batch of frontier items, skipping any that collide with an existing url or
+// url_norm (or with an earlier item in the same batch) so one known URL can't abort the rest
+const insertFIBatchStmt = `INSERT INTO frontier (url, url_norm, parent_url, depth, status, priority)
+SELECT fi.url, fi.url_norm, fi.parent_url, fi.depth, fi.status, fi.priority
+FROM unnest($1::text[], $2::text[], $3::text[], $4::int[], $5::int[], $6::real[])
+	 AS fi(url, url_norm, parent_url, depth, status, priority)
+ON CONFLICT DO NOTHING
+RETURNING url, url_norm, parent_url, depth, status, priority;`
 
 // FrontierStatusEnum represents the status of a frontier item in the crawling process.
 type FrontierStatusEnum int
@@ -22,8 +26,38 @@ const (
 	StatusInProgress                           // URL is currently being crawled
 	StatusCompleted                            // URL has been successfully crawled
 	StatusFailed                               // URL crawling failed
+	StatusSkipped                              // URL was deliberately not crawled (e.g. over budget)
+	StatusRetryable                            // URL crawling failed transiently; a later run tries again
 )
 
+// TrimPolicy selects which unvisited frontier items are evicted when the frontier
+// grows past its size limit.
+type TrimPolicy int
+
+const (
+	TrimNone           TrimPolicy = iota // Evict nothing; new URLs are dropped instead
+	TrimLowestPriority                   // Evict the lowest priority items first
+	TrimDeepest                          // Evict the deepest items first
+)
+
+// delete unvisited items beyond the n best by priority
+const trimLowestPriorityStmt = `DELETE FROM frontier
+WHERE url_norm IN (
+  SELECT url_norm FROM frontier
+  WHERE status = $1
+  ORDER BY priority DESC, depth ASC
+  OFFSET $2
+);`
+
+// delete unvisited items beyond the n shallowest
+const trimDeepestStmt = `DELETE FROM frontier
+WHERE url_norm IN (
+  SELECT url_norm FROM frontier
+  WHERE status = $1
+  ORDER BY depth ASC, priority DESC
+  OFFSET $2
+);`
+
 // FrontierItem represents a URL to be crawled with metadata for the crawling process.
 type FrontierItem struct {
 	Url       string             // Original URL
@@ -31,10 +65,12 @@ type FrontierItem struct {
 	ParentUrl string             // URL of the page that contained this link
 	Depth     int                // Depth in the crawling tree
 	Status    FrontierStatusEnum // Current status of this URL
+	Priority  float64            // Crawl priority, higher is dequeued first (see ScoreURL)
 }
 
-// NewFrontierItemFromParent creates a new frontier item from a parent URL and relative link.
-func NewFrontierItemFromParent(parent FrontierItem, rawUrl string) (FrontierItem, error) {
+// NewFrontierItemFromParent creates a new frontier item from a parent URL and relative link,
+// prioritized with w.
+func NewFrontierItemFromParent(parent FrontierItem, rawUrl string, w PriorityWeights) (FrontierItem, error) {
 	url, err := MakeUrl(parent.Url, rawUrl)
 	if err != nil {
 		return FrontierItem{}, err
@@ -43,23 +79,25 @@ func NewFrontierItemFromParent(parent FrontierItem, rawUrl string) (FrontierItem
 	if err != nil {
 		return FrontierItem{}, err
 	}
-	return FrontierItem{url, urlNorm, parent.Url, parent.Depth + 1, StatusUnvisited}, err
+	depth := parent.Depth + 1
+	return FrontierItem{url, urlNorm, parent.Url, depth, StatusUnvisited, ScoreURL(url, depth, w)}, err
 }
 
-// NewFrontierItemFromSeed creates a new frontier item from a seed URL with depth 0.
-func NewFrontierItemFromSeed(url string) (FrontierItem, error) {
+// NewFrontierItemFromSeed creates a new frontier item from a seed URL with depth 0,
+// prioritized with w.
+func NewFrontierItemFromSeed(url string, w PriorityWeights) (FrontierItem, error) {
 	urlNorm, err := NormalizeURL(url)
-	return FrontierItem{url, urlNorm, "", 0, StatusUnvisited}, err
+	return FrontierItem{url, urlNorm, "", 0, StatusUnvisited, ScoreURL(url, 0, w)}, err
 }
 
-// NewFrontierItem creates a new frontier item with all specified fields.
-func NewFrontierItem(url, urlNorm, parentUrl string, depth int, status FrontierStatusEnum) FrontierItem {
-	return FrontierItem{url, urlNorm, parentUrl, depth, status}
+// NewFrontierItem creates a new frontier item with all specified fields, prioritized with w.
+func NewFrontierItem(url, urlNorm, parentUrl string, depth int, status FrontierStatusEnum, w PriorityWeights) FrontierItem {
+	return FrontierItem{url, urlNorm, parentUrl, depth, status, ScoreURL(url, depth, w)}
 }
 
 // FromRows populates a FrontierItem from database query results.
 func (fi *FrontierItem) FromRows(rows pgx.Rows) error {
-	return rows.Scan(&fi.Url, &fi.UrlNorm, &fi.ParentUrl, &fi.Depth, &fi.Status)
+	return rows.Scan(&fi.Url, &fi.UrlNorm, &fi.ParentUrl, &fi.Depth, &fi.Status, &fi.Priority)
 }
 
 // GetFICount returns the total count of frontier items.
@@ -82,28 +120,111 @@ func GetFICountByStatus(ctx context.Context, db DBTX, status FrontierStatusEnum)
 	return count, nil
 }
 
-// GetFIByStatusDepthSorted returns frontier items sorted by depth for breadth-first crawling.
-func GetFIByStatusDepthSorted(ctx context.Context, db DBTX, status FrontierStatusEnum, limit int) (pgx.Rows, error) {
-	rows, err := db.Query(ctx, "SELECT * FROM frontier WHERE status = $1 ORDER BY depth ASC LIMIT $2", status, limit)
+// GetFIByStatusPrioritySorted returns up to limit frontier items sorted by priority, then depth,
+// so likely content pages are crawled first and ties fall back to breadth-first order.
+// The result holds exactly the rows found, which may be fewer than limit or none.
+func GetFIByStatusPrioritySorted(ctx context.Context, db DBTX, status FrontierStatusEnum, limit int) ([]FrontierItem, error) {
+	rows, err := db.Query(ctx, "SELECT url, url_norm, parent_url, depth, status, priority FROM frontier WHERE status = $1 ORDER BY priority DESC, depth ASC LIMIT $2", status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]FrontierItem, 0, max(limit, 0))
+	for rows.Next() {
+		var fi FrontierItem
+		if err := fi.FromRows(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, fi)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// mark the best unvisited items in progress, so they are neither handed out again nor
+// evicted while the crawler has them; rows locked by another claim are skipped
+const claimFIBatchStmt = `UPDATE frontier f
+SET status = $2
+FROM (
+  SELECT url_norm FROM frontier
+  WHERE status = $1
+  ORDER BY priority DESC, depth ASC
+  LIMIT $3
+  FOR UPDATE SKIP LOCKED
+) c
+WHERE f.url_norm = c.url_norm
+RETURNING f.url, f.url_norm, f.parent_url, f.depth, f.status, f.priority;`
+
+// ClaimFIBatch marks up to limit unvisited frontier items in progress and returns them
+// in the order GetFIByStatusPrioritySorted would. Claimed items are left alone by
+// TrimFrontierToSize; ReleaseFIBatch and RequeueInProgress return them to unvisited.
+func ClaimFIBatch(ctx context.Context, db DBTX, limit int) ([]FrontierItem, error) {
+	rows, err := db.Query(ctx, claimFIBatchStmt, StatusUnvisited, StatusInProgress, limit)
 	if err != nil {
 		return nil, err
 	}
-	return rows, nil
+	defer rows.Close()
+
+	items := make([]FrontierItem, 0, max(limit, 0))
+	for rows.Next() {
+		var fi FrontierItem
+		if err := fi.FromRows(rows); err != nil {
+			return nil, err
+		}
+		items = append(items, fi)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING has no order of its own
+	slices.SortStableFunc(items, func(a, b FrontierItem) int {
+		if c := cmp.Compare(b.Priority, a.Priority); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Depth, b.Depth)
+	})
+	return items, nil
+}
+
+// ReleaseFIBatch returns claimed frontier items that were never crawled to unvisited.
+func ReleaseFIBatch(ctx context.Context, db DBTX, urlNorms []string) error {
+	if len(urlNorms) == 0 {
+		return nil
+	}
+	_, err := db.Exec(ctx, "UPDATE frontier SET status = $1 WHERE status = $2 AND url_norm = ANY($3::text[])", StatusUnvisited, StatusInProgress, urlNorms)
+	return err
+}
+
+// RequeueInProgress returns every in-progress frontier item to unvisited, returning how
+// many were requeued. Items are only left in progress by a run that stopped before
+// crawling everything it claimed, so call it before a new run starts, not during one.
+func RequeueInProgress(ctx context.Context, db DBTX) (int64, error) {
+	tag, err := db.Exec(ctx, "UPDATE frontier SET status = $1 WHERE status = $2", StatusUnvisited, StatusInProgress)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
 }
 
-// InsertFI inserts a single frontier item into the database.
+// InsertFI inserts a single frontier item into the database, doing nothing if it is already present.
 func InsertFI(ctx context.Context, db DBTX, item FrontierItem) error {
-	_, err := db.Exec(ctx, "INSERT INTO frontier (url, url_norm, parent_url, depth, status) VALUES ($1, $2, $3, $4, $5)", item.Url, item.UrlNorm, item.ParentUrl, item.Depth, item.Status)
+	_, err := db.Exec(ctx, "INSERT INTO frontier (url, url_norm, parent_url, depth, status, priority) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT DO NOTHING", item.Url, item.UrlNorm, item.ParentUrl, item.Depth, item.Status, item.Priority)
 	return err
 }
 
 // InsertFIBatch inserts multiple frontier items in a single database operation for efficiency.
+// Items already in the frontier are skipped; only the newly inserted items are returned.
 func InsertFIBatch(ctx context.Context, db DBTX, items []FrontierItem) ([]FrontierItem, error) {
 	urls := make([]string, len(items))
 	urlNorms := make([]string, len(items))
 	parentUrls := make([]string, len(items))
 	depths := make([]int, len(items))
 	statuses := make([]int, len(items))
+	priorities := make([]float64, len(items))
 
 	for i, fi := range items {
 		urls[i] = fi.Url
@@ -111,9 +232,10 @@ func InsertFIBatch(ctx context.Context, db DBTX, items []FrontierItem) ([]Fronti
 		parentUrls[i] = fi.ParentUrl
 		depths[i] = fi.Depth
 		statuses[i] = int(fi.Status)
+		priorities[i] = fi.Priority
 	}
 
-	rows, err := db.Query(ctx, insertFIBatchStmt, urls, urlNorms, parentUrls, depths, statuses)
+	rows, err := db.Query(ctx, insertFIBatchStmt, urls, urlNorms, parentUrls, depths, statuses, priorities)
 	if err != nil {
 		return nil, err
 	}
@@ -135,6 +257,105 @@ func UpdateFIStatus(ctx context.Context, db DBTX, urlNorm string, status Frontie
 	return err
 }
 
+// record a page's outcome on the URL it redirected to, adding the target if it isn't
+// known yet; a target already completed in its own right keeps its status
+const markRedirectTargetStmt = `INSERT INTO frontier (url, url_norm, parent_url, depth, status, priority, failure_reason)
+VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+ON CONFLICT (url_norm) DO UPDATE SET status = EXCLUDED.status, failure_reason = EXCLUDED.failure_reason
+WHERE frontier.status <> $8;`
+
+// MarkRedirectTarget gives the frontier item a redirect led to the status of the page
+// fetched through it, and reason unless it is empty, so the target is neither crawled
+// again when linked to directly nor marked done before the page's outcome is known.
+func MarkRedirectTarget(ctx context.Context, db DBTX, target FrontierItem, status FrontierStatusEnum, reason string) error {
+	_, err := db.Exec(ctx, markRedirectTargetStmt, target.Url, target.UrlNorm, target.ParentUrl, target.Depth, status, target.Priority, reason, StatusCompleted)
+	return err
+}
+
+// TrimFrontierToSize evicts unvisited frontier items until at most n remain, choosing
+// victims according to policy, and returns how many were evicted. Only unvisited items
+// are considered, so items claimed by ClaimFIBatch are never evicted. Evicted URLs may be
+// enqueued again if they are rediscovered later. TrimNone evicts nothing.
+func TrimFrontierToSize(ctx context.Context, db DBTX, n int, policy TrimPolicy) (int64, error) {
+	var stmt string
+	switch policy {
+	case TrimLowestPriority:
+		stmt = trimLowestPriorityStmt
+	case TrimDeepest:
+		stmt = trimDeepestStmt
+	default:
+		return 0, nil
+	}
+
+	tag, err := db.Exec(ctx, stmt, StatusUnvisited, n)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// MarkFIFailed marks a frontier item permanently failed, recording why.
+func MarkFIFailed(ctx context.Context, db DBTX, urlNorm string, reason string) error {
+	_, err := db.Exec(ctx, "UPDATE frontier SET status = $1, failure_reason = $2 WHERE url_norm = $3", StatusFailed, reason, urlNorm)
+	return err
+}
+
+// MarkFIRetryable marks a frontier item as failed for a transient reason, such as a
+// 503, so RequeueRetryable can give it another chance, and counts the attempt.
+func MarkFIRetryable(ctx context.Context, db DBTX, urlNorm string, reason string) error {
+	_, err := db.Exec(ctx, "UPDATE frontier SET status = $1, failure_reason = $2, attempts = attempts + 1 WHERE url_norm = $3", StatusRetryable, reason, urlNorm)
+	return err
+}
+
+// give up on retryable items that have used their attempts, keeping the last reason
+const failExhaustedRetryableStmt = `UPDATE frontier SET status = $1 WHERE status = $2 AND attempts >= $3;`
+
+// RequeueRetryable returns retryable frontier items to unvisited, returning how many
+// were requeued and how many were instead marked failed for good, having already
+// failed maxAttempts times. A maxAttempts below 1 retries items indefinitely.
+func RequeueRetryable(ctx context.Context, db DBTX, maxAttempts int) (requeued, failed int64, err error) {
+	if maxAttempts > 0 {
+		tag, err := db.Exec(ctx, failExhaustedRetryableStmt, StatusFailed, StatusRetryable, maxAttempts)
+		if err != nil {
+			return 0, 0, err
+		}
+		failed = tag.RowsAffected()
+	}
+
+	tag, err := db.Exec(ctx, "UPDATE frontier SET status = $1, failure_reason = NULL WHERE status = $2", StatusUnvisited, StatusRetryable)
+	if err != nil {
+		return 0, failed, err
+	}
+	return tag.RowsAffected(), failed, nil
+}
+
+const getFailureReasonCountsStmt = `SELECT COALESCE(failure_reason, 'unknown'), COUNT(*)
+FROM frontier
+WHERE status IN ($1, $2)
+GROUP BY 1;`
+
+// GetFailureReasonCounts returns the number of failed frontier items per failure reason,
+// including those that may still be retried. Items that failed before reasons were
+// recorded are counted as "unknown".
+func GetFailureReasonCounts(ctx context.Context, db DBTX) (map[string]int, error) {
+	rows, err := db.Query(ctx, getFailureReasonCountsStmt, StatusFailed, StatusRetryable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var reason string
+		var count int
+		if err := rows.Scan(&reason, &count); err != nil {
+			return nil, err
+		}
+		counts[reason] = count
+	}
+	return counts, rows.Err()
+}
+
 // CleanupFrontier removes completed frontier items from the database to free space.
 func CleanupFrontier(ctx context.Context, db DBTX) error {
 	_, err := db.Exec(ctx, "DELETE FROM frontier WHERE status = $1", StatusCompleted)
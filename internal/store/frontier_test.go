@@ -0,0 +1,135 @@
+package store_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/jdpolicano/go-search/internal/store"
+	"github.com/jdpolicano/go-search/internal/store/testutil"
+)
+
+// newFrontierStore returns an empty scratch store, skipping the test without a database.
+func newFrontierStore(t *testing.T) store.Store {
+	t.Helper()
+	dsn, err := testutil.TestDSN()
+	if err != nil {
+		t.Skip(err)
+	}
+	s, cleanup, err := testutil.NewTempStore(context.Background(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	return s
+}
+
+// seedItems builds unvisited frontier items for urls.
+func seedItems(t *testing.T, urls ...string) []store.FrontierItem {
+	t.Helper()
+	items := make([]store.FrontierItem, len(urls))
+	for i, url := range urls {
+		item, err := store.NewFrontierItemFromSeed(url, store.DefaultPriorityWeights())
+		if err != nil {
+			t.Fatal(err)
+		}
+		items[i] = item
+	}
+	return items
+}
+
+// urlsOf returns the urls of items, sorted.
+func urlsOf(items []store.FrontierItem) []string {
+	urls := make([]string, len(items))
+	for i, item := range items {
+		urls[i] = item.Url
+	}
+	slices.Sort(urls)
+	return urls
+}
+
+func TestInsertFIBatchSkipsDuplicates(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []string
+		batch    []string
+		want     []string // Urls the batch inserts
+	}{
+		{"all new", nil, []string{"https://example.com/a", "https://example.com/b"}, []string{"https://example.com/a", "https://example.com/b"}},
+		{"one known", []string{"https://example.com/a"}, []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"}, []string{"https://example.com/b", "https://example.com/c"}},
+		{"known by normalized url", []string{"https://example.com/a"}, []string{"https://EXAMPLE.com/a", "https://example.com/b"}, []string{"https://example.com/b"}},
+		{"repeated within batch", nil, []string{"https://example.com/a", "https://example.com/a", "https://example.com/b"}, []string{"https://example.com/a", "https://example.com/b"}},
+		{"all known", []string{"https://example.com/a"}, []string{"https://example.com/a"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newFrontierStore(t)
+			if _, err := store.InsertFIBatch(ctx, s.Pool, seedItems(t, tt.existing...)); err != nil {
+				t.Fatal(err)
+			}
+
+			inserted, err := store.InsertFIBatch(ctx, s.Pool, seedItems(t, tt.batch...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := urlsOf(inserted); !slices.Equal(got, tt.want) {
+				t.Errorf("inserted %q, want %q", got, tt.want)
+			}
+			count, err := store.GetFICount(ctx, s.Pool)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := len(tt.existing) + len(tt.want); count != want {
+				t.Errorf("frontier holds %d items, want %d", count, want)
+			}
+		})
+	}
+}
+
+func TestGetFIByStatusPrioritySortedShortResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		urls   []string
+		status store.FrontierStatusEnum
+		limit  int
+		want   int
+	}{
+		{"empty frontier", nil, store.StatusUnvisited, 10, 0},
+		{"fewer than limit", []string{"https://example.com/a", "https://example.com/b"}, store.StatusUnvisited, 10, 2},
+		{"exactly limit", []string{"https://example.com/a", "https://example.com/b"}, store.StatusUnvisited, 2, 2},
+		{"more than limit", []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"}, store.StatusUnvisited, 2, 2},
+		{"no rows in status", []string{"https://example.com/a"}, store.StatusCompleted, 10, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newFrontierStore(t)
+			seeded := seedItems(t, tt.urls...)
+			if _, err := store.InsertFIBatch(ctx, s.Pool, seeded); err != nil {
+				t.Fatal(err)
+			}
+
+			items, err := store.GetFIByStatusPrioritySorted(ctx, s.Pool, tt.status, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != tt.want {
+				t.Fatalf("got %d items, want %d", len(items), tt.want)
+			}
+			byNorm := make(map[string]store.FrontierItem, len(seeded))
+			for _, item := range seeded {
+				byNorm[item.UrlNorm] = item
+			}
+			for i, item := range items {
+				seed, ok := byNorm[item.UrlNorm]
+				if !ok || item.Url != seed.Url || item.Status != tt.status {
+					t.Errorf("item %d = %+v, not a seeded %v item", i, item, tt.status)
+				}
+				if i > 0 && items[i-1].Priority < item.Priority {
+					t.Errorf("item %d out of priority order", i)
+				}
+			}
+		})
+	}
+}
@@ -4,20 +4,42 @@ package store
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jdpolicano/go-search/internal/extract"
 )
 
-// upsert a doc with a dummy update to get doc_id on conflict
-// in future we might want to update title/snippet if they change
-const insertDocStmt = `INSERT INTO docs (url, domain, hash, len)
-VALUES ($1, $2, $3, $4)
+// upsert a doc, refreshing its length, title and snippet on conflict so a re-crawl keeps
+// them up to date and we always get an id back; an empty title or snippet is stored as NULL.
+// A re-index bumps the revision, since it keeps the id and wouldn't otherwise show in the watermark
+const insertDocStmt = `INSERT INTO docs (url, domain, hash, len, title_len, title, snippet)
+VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''))
 ON CONFLICT (url) DO UPDATE SET
-	len = EXCLUDED.len -- just update length on conflict to keep it up to date and ensure we get an id back
+	len = EXCLUDED.len,
+	title_len = EXCLUDED.title_len,
+	title = EXCLUDED.title,
+	snippet = EXCLUDED.snippet,
+	revision = docs.revision + 1
 RETURNING id;`
 
-// checks if there will be a conflict in docs table based on a hash and domain
-const checkDocConflictStmt = `SELECT id FROM docs WHERE domain = $1 AND hash = $2;`
+// insert a doc under an explicit id, bypassing the generated identity; fails if the id or url exists
+const insertDocWithIdStmt = `INSERT INTO docs (id, url, domain, hash, len, title_len, title, snippet)
+OVERRIDING SYSTEM VALUE
+VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''));`
+
+// move the docs id sequence past the highest id so generated ids never collide with explicit ones
+const advanceDocIdSeqStmt = `SELECT setval(pg_get_serial_sequence('docs', 'id'), (SELECT MAX(id) FROM docs));`
+
+// checks if there will be a conflict in docs table based on a hash and domain; the
+// document's own row, when it is being re-indexed, is not a conflict
+const checkDocConflictStmt = `SELECT id FROM docs WHERE domain = $1 AND hash = $2 AND url <> $3 LIMIT 1;`
+
+// ErrorDuplicateContent is returned when indexing a document whose content hash matches
+// a document already indexed under another URL of the same domain.
+var ErrorDuplicateContent = errors.New("document with same hash already exists for this domain")
 
 // insert each term frequency for a document, and update term frequencies if they already exist
 const insertTermsStmt = `INSERT INTO terms (raw) SELECT unnest($1::text[])
@@ -26,21 +48,36 @@ ON CONFLICT (raw) DO UPDATE SET
 RETURNING id, raw;
 `
 
-// inserts postings, and on unique entries, updates term frequency
-const insertPostingsBatchStmt = `INSERT INTO postings (term_id, doc_id, tf_raw)
-SELECT t.term_id, $1::int, t.tf_raw -- doc_id is constant for this batch
-FROM unnest($2::int[], $3::int[]) AS t(term_id, tf_raw) -- term_id, tf_raw pairs
+// inserts postings, and on unique entries, updates term frequency and positions.
+// Positions arrive as array literals, since arrays can't be unnested into arrays; ” is NULL
+const insertPostingsBatchStmt = `INSERT INTO postings (term_id, doc_id, tf_raw, tf_title, positions)
+SELECT t.term_id, $1::int, t.tf_raw, t.tf_title, NULLIF(t.positions, '')::int[] -- doc_id is constant for this batch
+FROM unnest($2::int[], $3::int[], $4::int[], $5::text[]) AS t(term_id, tf_raw, tf_title, positions) -- term_id, body tf, title tf, positions
 ON CONFLICT (term_id, doc_id) DO UPDATE
-SET tf_raw = EXCLUDED.tf_raw;`
+SET tf_raw = EXCLUDED.tf_raw, tf_title = EXCLUDED.tf_title, positions = EXCLUDED.positions;`
 
 // IndexEntry represents a document ready to be indexed in the search engine.
 type IndexEntry struct {
-	Url       string         // Original URL
-	UrlNorm   string         // Normalized URL for deduplication
-	Domain    string         // Domain name
-	Hash      string         // Content hash for duplicate detection
-	Len       int            // Number of terms in the document
-	TermFreqs map[string]int // Term to frequency map for this document
+	Url        string           // Original URL
+	UrlNorm    string           // Normalized URL for deduplication
+	Domain     string           // Domain name
+	Hash       string           // Content hash for duplicate detection
+	Len        int              // Number of terms in the document
+	TermFreqs  map[string]int   // Term to frequency map for this document
+	Positions  map[string][]int // Term to body word positions, for phrase queries; terms without any store NULL
+	Title      string           // Display title; stored as NULL when empty
+	TitleLen   int              // Number of terms in the document title
+	TitleFreqs map[string]int   // Term to frequency map for the document title
+	Snippet    string           // Static snippet shown in search results; stored as NULL when empty
+	Text       string           // Extracted visible text; stored only when non-empty
+}
+
+// fieldFreqs holds a term's frequency in each indexed field of a document, and its
+// positions in the body when known.
+type fieldFreqs struct {
+	body      int
+	title     int
+	positions []int
 }
 
 // NewIndexEntry creates a new IndexEntry from URL, hash, length, and term frequencies.
@@ -65,6 +102,23 @@ func NewIndexEntry(url, hash string, len int, termFreqs map[string]int) (IndexEn
 	}, nil
 }
 
+// SetTitle sets the entry's display title and tokenizes it into the title field
+// used for ranking.
+func (e *IndexEntry) SetTitle(title string) error {
+	words, err := extract.ScanWordsFromString(title)
+	if err != nil {
+		return err
+	}
+	freqs := make(map[string]int, len(words))
+	for _, word := range words {
+		freqs[word]++
+	}
+	e.Title = title
+	e.TitleLen = len(words)
+	e.TitleFreqs = freqs
+	return nil
+}
+
 // IndexDocumentInit performs the initial indexing of a document:
 // 1. Inserts document info (url, length) into the docs table.
 // 2. Inserts terms into the terms table, getting their term ids.
@@ -73,45 +127,94 @@ func NewIndexEntry(url, hash string, len int, termFreqs map[string]int) (IndexEn
 // This is only the first phase of the indexing process. There must also be a pre-compute step to calculate TF, IDF, and Norm for terms/docs
 // In the database
 func IndexDocumentInit(ctx context.Context, db DBTX, doc IndexEntry) error {
-	docId, err := insertDocumentInfo(ctx, db, doc.Url, doc.Domain, doc.Hash, doc.Len)
+	_, err := IndexDocumentCached(ctx, db, doc, nil)
+	return err
+}
+
+// IndexDocumentCached is IndexDocumentInit, but looks up term ids in cache before
+// inserting terms. It returns the term ids it had to resolve from the database, which
+// the caller should add to the cache once the surrounding transaction has committed.
+func IndexDocumentCached(ctx context.Context, db DBTX, doc IndexEntry, cache *TermCache) (map[string]int64, error) {
+	docId, err := insertDocumentInfo(ctx, db, doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert document info: %w", err)
+	}
+	return indexDocumentContent(ctx, db, docId, doc, cache)
+}
+
+// IndexDocumentWithID indexes a document under a caller-chosen id instead of the next
+// generated one, so fixtures and golden ranking tests can refer to known ids.
+//
+// It is meant only for loading test corpora and controlled ingestion: it fails if the
+// id or url is already taken, and it advances the id sequence past the highest id so
+// that documents indexed normally afterwards can't collide with it.
+func IndexDocumentWithID(ctx context.Context, db DBTX, id int64, doc IndexEntry) error {
+	if id <= 0 {
+		return fmt.Errorf("invalid document id %d: must be positive", id)
+	}
+
+	hasConflict, err := hasDomainHashConflict(ctx, db, doc.Url, doc.Domain, doc.Hash)
 	if err != nil {
-		return errors.New("failed to insert document info " + err.Error())
+		return fmt.Errorf("failed to insert document info: %w", err)
+	}
+	if hasConflict {
+		return fmt.Errorf("failed to insert document info: %w", ErrorDuplicateContent)
 	}
 
-	termIdFreqMap, err := insertTerms(ctx, db, doc.TermFreqs)
+	if _, err := db.Exec(ctx, insertDocWithIdStmt, id, doc.Url, doc.Domain, doc.Hash, doc.Len, doc.TitleLen, doc.Title, doc.Snippet); err != nil {
+		return fmt.Errorf("failed to insert document info: %w", err)
+	}
+	if _, err := db.Exec(ctx, advanceDocIdSeqStmt); err != nil {
+		return fmt.Errorf("failed to advance document id sequence: %w", err)
+	}
+
+	_, err = indexDocumentContent(ctx, db, id, doc, nil)
+	return err
+}
+
+// indexDocumentContent inserts the terms, postings and text of a document already in the docs table.
+func indexDocumentContent(ctx context.Context, db DBTX, docId int64, doc IndexEntry, cache *TermCache) (map[string]int64, error) {
+	termIdFreqMap, resolved, err := insertTerms(ctx, db, doc.TermFreqs, doc.TitleFreqs, doc.Positions, cache)
 	if err != nil {
-		return errors.New("failed to insert terms " + err.Error())
+		return nil, fmt.Errorf("failed to insert terms: %w", err)
 	}
 
 	err = insertPostings(ctx, db, docId, termIdFreqMap)
 	if err != nil {
-		return errors.New("failed to insert postings " + err.Error())
+		return nil, fmt.Errorf("failed to insert postings: %w", err)
 	}
 
-	return nil
+	if doc.Text != "" {
+		err = insertDocumentText(ctx, db, docId, doc.Text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert document text: %w", err)
+		}
+	}
+
+	return resolved, nil
 }
 
 // insertDocumentInfo inserts a document and returns the id of the document.
-// If the document already exists, it returns the existing id, but updates the length.
-func insertDocumentInfo(ctx context.Context, db DBTX, url, domain, hash string, len int) (doc_id int64, err error) {
-	hasConflict, err := hasDomainHashConflict(ctx, db, domain, hash)
+// If the document already exists, it returns the existing id, but updates the length, title and snippet.
+func insertDocumentInfo(ctx context.Context, db DBTX, doc IndexEntry) (doc_id int64, err error) {
+	hasConflict, err := hasDomainHashConflict(ctx, db, doc.Url, doc.Domain, doc.Hash)
 	if err != nil {
 		return -1, err
 	}
 
 	if hasConflict {
-		return -1, errors.New("document with same hash already exists for this domain")
+		return -1, ErrorDuplicateContent
 	}
 
-	err = db.QueryRow(ctx, insertDocStmt, url, domain, hash, len).Scan(&doc_id)
+	err = db.QueryRow(ctx, insertDocStmt, doc.Url, doc.Domain, doc.Hash, doc.Len, doc.TitleLen, doc.Title, doc.Snippet).Scan(&doc_id)
 	return doc_id, err
 }
 
-// hasDomainHashConflict checks if a document with the same hash and domain already exists.
-// If it does, it returns true.
-func hasDomainHashConflict(ctx context.Context, db DBTX, domain, hash string) (bool, error) {
+// hasDomainHashConflict checks if a document with the same hash and domain already exists
+// under a URL other than url. If it does, it returns true.
+func hasDomainHashConflict(ctx context.Context, db DBTX, url, domain, hash string) (bool, error) {
 	var doc_id int64
-	err := db.QueryRow(ctx, checkDocConflictStmt, domain, hash).Scan(&doc_id)
+	err := db.QueryRow(ctx, checkDocConflictStmt, domain, hash, url).Scan(&doc_id)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return false, nil
@@ -121,18 +224,36 @@ func hasDomainHashConflict(ctx context.Context, db DBTX, domain, hash string) (b
 	return true, nil
 }
 
-// insertTerms inserts terms into the term table, returning a map of term_id -> term_frequency for this document.
-func insertTerms(ctx context.Context, db DBTX, termFreqs map[string]int) (map[int64]int, error) {
-	termIdFreqMap := make(map[int64]int)
+// insertTerms inserts body and title terms into the term table, returning a map of term_id -> per-field frequencies and positions
+// for this document, along with the term ids that were resolved from the database rather than the cache.
+func insertTerms(ctx context.Context, db DBTX, termFreqs, titleFreqs map[string]int, positions map[string][]int, cache *TermCache) (map[int64]fieldFreqs, map[string]int64, error) {
+	termIdFreqMap := make(map[int64]fieldFreqs)
+	resolved := make(map[string]int64)
 
-	terms := make([]string, 0, len(termFreqs))
-	for term := range termFreqs {
+	terms := make([]string, 0, len(termFreqs)+len(titleFreqs))
+	addTerm := func(term string) {
+		if termId, ok := cache.Get(term); ok {
+			termIdFreqMap[termId] = fieldFreqs{body: termFreqs[term], title: titleFreqs[term], positions: positions[term]}
+			return
+		}
 		terms = append(terms, term)
 	}
+	for term := range termFreqs {
+		addTerm(term)
+	}
+	for term := range titleFreqs {
+		if _, inBody := termFreqs[term]; !inBody {
+			addTerm(term)
+		}
+	}
+
+	if len(terms) == 0 {
+		return termIdFreqMap, resolved, nil
+	}
 
 	rows, err := db.Query(ctx, insertTermsStmt, terms)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
@@ -140,23 +261,68 @@ func insertTerms(ctx context.Context, db DBTX, termFreqs map[string]int) (map[in
 		var termId int64
 		var termRaw string
 		if err := rows.Scan(&termId, &termRaw); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		// safety: invariant here is that termFreqs must contain the termRaw key
-		// It wouldn't make sense to insert a term that doesn't exist in the term frequency map
-		termIdFreqMap[termId] = termFreqs[termRaw]
+		// safety: invariant here is that termFreqs or titleFreqs must contain the termRaw key
+		// It wouldn't make sense to insert a term that doesn't exist in either frequency map
+		termIdFreqMap[termId] = fieldFreqs{body: termFreqs[termRaw], title: titleFreqs[termRaw], positions: positions[termRaw]}
+		resolved[termRaw] = termId
 	}
-	return termIdFreqMap, nil
+	return termIdFreqMap, resolved, rows.Err()
 }
 
 // insertPostings inserts postings into the postings table.
-func insertPostings(ctx context.Context, db DBTX, docId int64, termIdFreqMap map[int64]int) error {
+func insertPostings(ctx context.Context, db DBTX, docId int64, termIdFreqMap map[int64]fieldFreqs) error {
 	termIds := make([]int64, 0, len(termIdFreqMap))
 	tfRaws := make([]int64, 0, len(termIdFreqMap))
+	tfTitles := make([]int64, 0, len(termIdFreqMap))
+	positions := make([]string, 0, len(termIdFreqMap))
 	for termId, tf := range termIdFreqMap {
 		termIds = append(termIds, termId)
-		tfRaws = append(tfRaws, int64(tf))
+		tfRaws = append(tfRaws, int64(tf.body))
+		tfTitles = append(tfTitles, int64(tf.title))
+		positions = append(positions, positionsLiteral(tf.positions))
 	}
-	_, err := db.Exec(ctx, insertPostingsBatchStmt, docId, termIds, tfRaws)
+	_, err := db.Exec(ctx, insertPostingsBatchStmt, docId, termIds, tfRaws, tfTitles, positions)
 	return err
 }
+
+// positionsLiteral formats positions as a PostgreSQL array literal such as "{3,17}",
+// or an empty string, stored as NULL, when there are none.
+func positionsLiteral(positions []int) string {
+	if len(positions) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, pos := range positions {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(pos))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+const getDocCountsByDomainStmt = `SELECT domain, COUNT(*) FROM docs GROUP BY domain;`
+
+// GetDocCountsByDomain returns the number of indexed documents for each domain.
+func GetDocCountsByDomain(ctx context.Context, db DBTX) (map[string]int, error) {
+	rows, err := db.Query(ctx, getDocCountsByDomainStmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var domain string
+		var count int
+		if err := rows.Scan(&domain, &count); err != nil {
+			return nil, err
+		}
+		counts[domain] = count
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,39 @@
+// Package store provides tracking of the link graph between crawled pages.
+package store
+
+import "context"
+
+// record each newly seen link edge, then boost the priority of the unvisited targets
+// that gained a new referring page
+const insertInlinksStmt = `WITH edges AS (
+  INSERT INTO inlinks (from_url, to_url_norm)
+  SELECT $1, unnest($2::text[])
+  ON CONFLICT DO NOTHING
+  RETURNING to_url_norm
+)
+UPDATE frontier f
+SET priority = f.priority + $3
+FROM edges e
+WHERE f.url_norm = e.to_url_norm
+  AND f.status = $4;`
+
+const getInlinkCountStmt = `SELECT COUNT(*) FROM inlinks WHERE to_url_norm = $1;`
+
+// InsertInlinks records that fromUrl links to each of the normalized target URLs.
+// Edges already recorded are ignored. Each unvisited target that gains a new
+// referring page has its frontier priority raised by boost, so widely linked
+// pages are crawled sooner.
+func InsertInlinks(ctx context.Context, db DBTX, fromUrl string, toUrlNorms []string, boost float64) error {
+	if len(toUrlNorms) == 0 {
+		return nil
+	}
+	_, err := db.Exec(ctx, insertInlinksStmt, fromUrl, toUrlNorms, boost, StatusUnvisited)
+	return err
+}
+
+// GetInlinkCount returns the number of distinct pages known to link to a normalized URL.
+func GetInlinkCount(ctx context.Context, db DBTX, urlNorm string) (int, error) {
+	var count int
+	err := db.QueryRow(ctx, getInlinkCountStmt, urlNorm).Scan(&count)
+	return count, err
+}
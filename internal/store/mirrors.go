@@ -0,0 +1,110 @@
+// Package store provides detection and exclusion of mirror domains.
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// DuplicateGroup is a set of documents with identical content hashes served from
+// more than one domain, the signature of a mirror site.
+type DuplicateGroup struct {
+	Hash    string   `json:"hash"`
+	Domains []string `json:"domains"` // Distinct domains serving the content, sorted
+	URLs    []string `json:"urls"`    // Every document with the hash, sorted
+	DocIDs  []int64  `json:"doc_ids"` // Ids of the documents, in the same order as URLs
+}
+
+// group documents by hash, keeping hashes seen on more than one domain; the largest groups come first
+const findDuplicateAcrossDomainsStmt = `SELECT
+  hash,
+  array_agg(DISTINCT domain ORDER BY domain),
+  array_agg(url ORDER BY url),
+  array_agg(id::bigint ORDER BY url)
+FROM docs
+GROUP BY hash
+HAVING COUNT(DISTINCT domain) > 1
+ORDER BY COUNT(*) DESC, hash;`
+
+// FindDuplicateAcrossDomains reports every set of documents that share a content hash
+// across different domains. Domains that keep turning up together are likely mirrors,
+// which operators can exclude with ExcludeDomain.
+func FindDuplicateAcrossDomains(ctx context.Context, db DBTX) ([]DuplicateGroup, error) {
+	rows, err := db.Query(ctx, findDuplicateAcrossDomainsStmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []DuplicateGroup
+	for rows.Next() {
+		var group DuplicateGroup
+		if err := rows.Scan(&group.Hash, &group.Domains, &group.URLs, &group.DocIDs); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+// record a domain as excluded, updating the reason if it already is
+const excludeDomainStmt = `INSERT INTO domain_exclusions (domain, reason) VALUES ($1, $2)
+ON CONFLICT (domain) DO UPDATE SET reason = EXCLUDED.reason;`
+
+// delete the indexed documents of a domain and its subdomains; postings and text cascade
+const deleteDomainDocsStmt = `DELETE FROM docs WHERE domain = $1 OR domain LIKE '%.' || $1;`
+
+const includeDomainStmt = `DELETE FROM domain_exclusions WHERE domain = $1;`
+
+const getExcludedDomainsStmt = `SELECT domain FROM domain_exclusions ORDER BY domain;`
+
+// ExcludeDomain excludes a domain, and its subdomains, from future crawls, recording why,
+// and removes their documents from the index so they stop appearing in results. It
+// returns the number of documents removed. Document frequencies and corpus statistics
+// still count them until the ranker next runs.
+func ExcludeDomain(ctx context.Context, db DBTX, domain, reason string) (int64, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if domain == "" {
+		return 0, errors.New("domain must not be empty")
+	}
+	var deleted int64
+	err := withTx(ctx, db, func(tx DBTX) error {
+		if _, err := tx.Exec(ctx, excludeDomainStmt, domain, reason); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, deleteDomainDocsStmt, domain)
+		if err != nil {
+			return err
+		}
+		deleted = tag.RowsAffected()
+		return nil
+	})
+	return deleted, err
+}
+
+// IncludeDomain lifts a domain's exclusion, so it is crawled again; documents removed
+// by ExcludeDomain come back only once re-crawled. It is not an error if it wasn't excluded.
+func IncludeDomain(ctx context.Context, db DBTX, domain string) error {
+	_, err := db.Exec(ctx, includeDomainStmt, strings.ToLower(strings.TrimSpace(domain)))
+	return err
+}
+
+// GetExcludedDomains returns every excluded domain.
+func GetExcludedDomains(ctx context.Context, db DBTX) ([]string, error) {
+	rows, err := db.Query(ctx, getExcludedDomainsStmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var domains []string
+	for rows.Next() {
+		var domain string
+		if err := rows.Scan(&domain); err != nil {
+			return nil, err
+		}
+		domains = append(domains, domain)
+	}
+	return domains, rows.Err()
+}
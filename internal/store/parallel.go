@@ -0,0 +1,243 @@
+// Package store provides parallel BM25 search for queries with many terms.
+package store
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/sync/errgroup"
+)
+
+// ParallelOptions configures splitting wide queries into term groups searched concurrently.
+type ParallelOptions struct {
+	MinTerms    int // Queries with at least this many distinct terms are split; 0 disables splitting
+	Concurrency int // Number of term groups, and so concurrent queries; 0 defaults to 4
+}
+
+// defaultParallelConcurrency is the number of term groups used when ParallelOptions leaves it unset.
+const defaultParallelConcurrency = 4
+
+// partialBM25Stmt computes each matching document's per-term BM25 contributions over a
+// subset of the query terms, in term order, along with how many of their match groups
+// ($4) it matched and its boost. The per-term formula must stay in sync with
+// searchBM25Stmt, so the contributions add up to its score.
+const partialBM25Stmt = `
+WITH
+  params AS (
+    SELECT $2::real AS k1, $3::real AS b
+  ),
+  ` + corpusStatsCTE + `,
+  q AS (
+    SELECT * FROM unnest($1::text[], $4::text[]) AS q(raw, grp)
+  )
+SELECT
+  d.id,
+  ARRAY_AGG(q.raw ORDER BY q.raw COLLATE "C") AS terms,
+  ARRAY_AGG(
+    (
+      (LN(((corpus.N - t.df::real + 0.5) / (t.df::real + 0.5)) + 1.0))
+      *
+      (
+        (p.tf_raw::real * (params.k1 + 1.0))
+        /
+        (p.tf_raw::real
+          + params.k1 * (1.0 - params.b + params.b * (d.len::real / NULLIF(corpus.avgdl, 0)))
+        )
+      )
+    )::float8
+    ORDER BY q.raw COLLATE "C"
+  ) AS contributions,
+  COUNT(DISTINCT q.grp) AS matched,
+  COALESCE(b.boost, 1.0)::float8 AS boost
+FROM q
+JOIN terms t     ON t.raw = q.raw
+JOIN postings p  ON p.term_id = t.id
+JOIN docs d      ON d.id = p.doc_id
+LEFT JOIN doc_boost b ON b.doc_id = d.id
+CROSS JOIN params
+CROSS JOIN corpus
+WHERE d.len > 0
+  AND p.tf_raw > 0
+  AND t.df IS NOT NULL
+GROUP BY d.id, b.boost;`
+
+// get the display fields of the final ranked documents
+const getResultDocsStmt = `SELECT id, url, title, snippet, len FROM docs WHERE id = ANY($1::int[]);`
+
+// partialScore accumulates a document's score across term groups.
+type partialScore struct {
+	id      int64
+	terms   []string  // Matched query terms
+	parts   []float64 // Contribution of each of terms
+	score   float64   // Sum of parts in term order, once every group is merged
+	matched int
+	boost   float64
+}
+
+// sum adds up the contributions in byte order of their terms, as searchBM25Stmt's
+// SUM does, so both arrive at the same float64.
+func (ps *partialScore) sum() {
+	order := make([]int, len(ps.terms))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int { return strings.Compare(ps.terms[a], ps.terms[b]) })
+	ps.score = 0
+	for _, i := range order {
+		ps.score += ps.parts[i]
+	}
+}
+
+// shouldSearchParallel reports whether a query of n distinct terms is split across
+// term groups, which needs a pool to run the groups on separate connections. Phrase
+// queries aren't split, as a phrase's words may land in different groups.
+func (opts SearchOptions) shouldSearchParallel(db DBTX, n int) (*pgxpool.Pool, bool) {
+	if opts.Parallel.MinTerms <= 0 || n < opts.Parallel.MinTerms || len(opts.Phrases) > 0 {
+		return nil, false
+	}
+	pool, ok := db.(*pgxpool.Pool)
+	return pool, ok
+}
+
+// searchBM25Parallel is SearchBM25 for wide queries: the terms are split into groups
+// scored concurrently on separate connections, and the per-term contributions merged,
+// summed and ranked in Go. They are summed in the same order and precision as the
+// single statement, so scores, and so rankings and cursors, match it exactly; ties
+// are broken by descending document id. The expansions
+// of a prefix are kept in one group, so each group counts its matches on its own.
+func searchBM25Parallel(ctx context.Context, pool *pgxpool.Pool, terms []string, limit int, opts SearchOptions) ([]SearchResult, error) {
+	concurrency := opts.Parallel.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultParallelConcurrency
+	}
+	matchGroup, n := matchGroups(terms, opts.Prefixes)
+	groups := make([][]string, min(concurrency, n))
+	groupKeys := make([][]string, len(groups))
+	slot := make(map[string]int, n)
+	for i, term := range terms {
+		s, ok := slot[matchGroup[i]]
+		if !ok {
+			s = len(slot) % len(groups)
+			slot[matchGroup[i]] = s
+		}
+		groups[s] = append(groups[s], term)
+		groupKeys[s] = append(groupKeys[s], matchGroup[i])
+	}
+
+	partials := make([][]partialScore, len(groups))
+	g, gctx := errgroup.WithContext(ctx)
+	for i, group := range groups {
+		g.Go(func() error {
+			scores, err := searchPartialBM25(gctx, pool, group, groupKeys[i], opts)
+			partials[i] = scores
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	// Merge the groups, then apply the minimum match and boost as the single statement does
+	merged := make(map[int64]*partialScore)
+	for _, scores := range partials {
+		for _, ps := range scores {
+			if m, ok := merged[ps.id]; ok {
+				m.terms = append(m.terms, ps.terms...)
+				m.parts = append(m.parts, ps.parts...)
+				m.matched += ps.matched
+				continue
+			}
+			merged[ps.id] = &ps
+		}
+	}
+	minMatches := opts.minDistinctMatches(n)
+	ranked := make([]partialScore, 0, len(merged))
+	for _, ps := range merged {
+		if ps.matched < minMatches {
+			continue
+		}
+		ps.sum()
+		if opts.BoostMode == BoostAdd {
+			ps.score += ps.boost - 1.0
+		} else {
+			ps.score *= ps.boost
+		}
+		if !opts.After.after(ps.score, ps.id) {
+			continue
+		}
+		ranked = append(ranked, *ps)
+	}
+	slices.SortFunc(ranked, func(a, b partialScore) int {
+		if c := cmp.Compare(b.score, a.score); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.id, a.id)
+	})
+
+	offset := min(max(opts.Offset, 0), len(ranked))
+	ranked = ranked[offset:min(offset+limit, len(ranked))]
+	return resultsForScores(ctx, pool, ranked)
+}
+
+// searchPartialBM25 scores the documents matching one term group, whose terms belong
+// to the given match groups. opts.K1 and opts.B must be set, as SearchBM25 does.
+func searchPartialBM25(ctx context.Context, db DBTX, terms, groupKeys []string, opts SearchOptions) ([]partialScore, error) {
+	rows, err := db.Query(ctx, partialBM25Stmt, terms, *opts.K1, *opts.B, groupKeys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scores []partialScore
+	for rows.Next() {
+		var ps partialScore
+		if err := rows.Scan(&ps.id, &ps.terms, &ps.parts, &ps.matched, &ps.boost); err != nil {
+			return nil, err
+		}
+		scores = append(scores, ps)
+	}
+	return scores, rows.Err()
+}
+
+// resultsForScores loads the display fields of ranked documents, keeping their order.
+func resultsForScores(ctx context.Context, db DBTX, ranked []partialScore) ([]SearchResult, error) {
+	if len(ranked) == 0 {
+		return nil, nil
+	}
+	ids := make([]int64, len(ranked))
+	for i, ps := range ranked {
+		ids[i] = ps.id
+	}
+
+	rows, err := db.Query(ctx, getResultDocsStmt, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byId := make(map[int64]SearchResult, len(ranked))
+	for rows.Next() {
+		var result SearchResult
+		if err := rows.Scan(&result.ID, &result.URL, &result.Title, &result.Snippet, &result.Len); err != nil {
+			return nil, err
+		}
+		byId[result.ID] = result
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(ranked))
+	for _, ps := range ranked {
+		result, ok := byId[ps.id]
+		if !ok {
+			continue // deleted since it was scored
+		}
+		result.Score = ps.score
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,207 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jdpolicano/go-search/internal/store"
+	"github.com/jdpolicano/go-search/internal/store/testutil"
+)
+
+// benchVocabulary is the number of distinct words in a benchCorpus.
+const benchVocabulary = 500
+
+// newBenchStore returns a scratch store seeded with n documents of 100 words each,
+// drawn from benchVocabulary words with a skewed but repeatable distribution,
+// skipping the benchmark without a database.
+func newBenchStore(b *testing.B, n int) store.Store {
+	b.Helper()
+	dsn, err := testutil.TestDSN()
+	if err != nil {
+		b.Skip(err)
+	}
+	ctx := context.Background()
+	s, cleanup, err := testutil.NewTempStore(ctx, dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(cleanup)
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	docs := make([]testutil.TestDoc, n)
+	words := make([]string, 100)
+	for i := range docs {
+		for j := range words {
+			// Low-numbered words are common, as in natural text
+			words[j] = fmt.Sprintf("word%d", rng.IntN(rng.IntN(benchVocabulary)+1))
+		}
+		docs[i] = testutil.TestDoc{Url: fmt.Sprintf("https://example.com/doc/%d", i), Text: strings.Join(words, " ")}
+	}
+	if _, err := testutil.SeedCorpus(ctx, s.Pool, docs); err != nil {
+		b.Fatal(err)
+	}
+	return s
+}
+
+// tieTerms are the query terms of the near-tie corpus.
+var tieTerms = []string{"apple", "banana", "cherry", "damson", "elder", "fig", "grape", "guava", "kiwi", "lemon", "mango", "olive"}
+
+// newTieStore returns a scratch store whose documents contain every tieTerms word with
+// the term frequencies rotated from one document to the next, so many documents score
+// the same up to the order their contributions are added in, and some differ only by
+// a filler word or two or a boost.
+func newTieStore(t *testing.T) store.Store {
+	t.Helper()
+	dsn, err := testutil.TestDSN()
+	if err != nil {
+		t.Skip(err)
+	}
+	ctx := context.Background()
+	s, cleanup, err := testutil.NewTempStore(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+
+	docs := make([]testutil.TestDoc, 40)
+	for i := range docs {
+		var words []string
+		for j, term := range tieTerms {
+			for range 1 + (i+j)%4 {
+				words = append(words, term)
+			}
+		}
+		for range i % 3 {
+			words = append(words, "filler")
+		}
+		docs[i] = testutil.TestDoc{Url: fmt.Sprintf("https://example.com/tie/%d", i), Text: strings.Join(words, " ")}
+	}
+	if _, err := testutil.SeedCorpus(ctx, s.Pool, docs); err != nil {
+		t.Fatal(err)
+	}
+	// Boosts that aren't exact in single precision, so both paths must widen them alike
+	for i, boost := range []float64{1.1, 0.3, 7.7} {
+		if err := store.SetDocBoost(ctx, s.Pool, docs[i*5].Url, boost); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestSearchBM25ParallelScoresMatch(t *testing.T) {
+	s := newTieStore(t)
+	ctx := context.Background()
+	single, err := store.SearchBM25(ctx, s.Pool, tieTerms, store.SearchOptions{Limit: 100, MinDistinctMatches: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(single) != 40 {
+		t.Fatalf("single statement found %d documents, want 40", len(single))
+	}
+
+	tests := []struct {
+		name        string
+		concurrency int
+		boost       store.BoostMode
+	}{
+		{"two groups", 2, store.BoostMultiply},
+		{"five groups", 5, store.BoostMultiply},
+		{"one group per term", len(tieTerms), store.BoostMultiply},
+		{"additive boost", 3, store.BoostAdd},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := store.SearchBM25(ctx, s.Pool, tieTerms, store.SearchOptions{Limit: 100, MinDistinctMatches: 1, BoostMode: tt.boost})
+			if err != nil {
+				t.Fatal(err)
+			}
+			parallel := store.ParallelOptions{MinTerms: 1, Concurrency: tt.concurrency}
+			got, err := store.SearchBM25(ctx, s.Pool, tieTerms, store.SearchOptions{Limit: 100, MinDistinctMatches: 1, BoostMode: tt.boost, Parallel: parallel})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("parallel found %d documents, single statement %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i].ID != want[i].ID || got[i].Score != want[i].Score {
+					t.Errorf("rank %d: parallel %d scored %v, single statement %d scored %v", i, got[i].ID, got[i].Score, want[i].ID, want[i].Score)
+				}
+			}
+
+			// Cursors issued by one path page through the other without skipping or repeating
+			var paged []int64
+			var after *store.Cursor
+			for page := 0; ; page++ {
+				opts := store.SearchOptions{Limit: 7, MinDistinctMatches: 1, BoostMode: tt.boost, After: after}
+				if page%2 == 1 {
+					opts.Parallel = parallel
+				}
+				results, err := store.SearchBM25(ctx, s.Pool, tieTerms, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(results) == 0 {
+					break
+				}
+				for _, result := range results {
+					paged = append(paged, result.ID)
+				}
+				after = store.NextCursor(results)
+			}
+			for i := range max(len(paged), len(want)) {
+				if i >= len(paged) || i >= len(want) || paged[i] != want[i].ID {
+					t.Fatalf("alternating pages gave %v", paged)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSearchBM25Parallel compares a wide 20-term OR query run as one statement
+// with the same query split into term groups searched concurrently.
+func BenchmarkSearchBM25Parallel(b *testing.B) {
+	s := newBenchStore(b, 5000)
+	ctx := context.Background()
+	terms := make([]string, 20)
+	for i := range terms {
+		terms[i] = fmt.Sprintf("word%d", i*7)
+	}
+
+	single, err := store.SearchBM25(ctx, s.Pool, terms, store.SearchOptions{MinDistinctMatches: 1})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, concurrency := range []int{0, 2, 4, 8} {
+		name := "single statement"
+		if concurrency > 0 {
+			name = fmt.Sprintf("concurrency=%d", concurrency)
+		}
+		b.Run(name, func(b *testing.B) {
+			opts := store.SearchOptions{MinDistinctMatches: 1}
+			if concurrency > 0 {
+				opts.Parallel = store.ParallelOptions{MinTerms: 1, Concurrency: concurrency}
+			}
+
+			// The split query must rank exactly as the single statement does
+			results, err := store.SearchBM25(ctx, s.Pool, terms, opts)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if !slices.EqualFunc(results, single, func(a, b store.SearchResult) bool { return a.ID == b.ID }) {
+				b.Fatalf("ranked %d results differently from the single statement", len(results))
+			}
+
+			for b.Loop() {
+				if _, err := store.SearchBM25(ctx, s.Pool, terms, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// Package store provides phrase matching for BM25 search.
+package store
+
+// phraseDocsCTE defines the phrase_docs CTE: the documents containing every query
+// phrase, with the words of each phrase at consecutive body positions. Phrases are
+// passed flattened as parallel arrays of word ($10), phrase number ($11) and index
+// of the word within its phrase ($12). An occurrence of a phrase is a start position
+// from which every one of its words sits at its index; documents indexed without
+// positions never match.
+const phraseDocsCTE = `phrase_words AS (
+    SELECT * FROM unnest($10::text[], $11::int[], $12::int[]) AS pw(raw, phrase, idx)
+  ),
+  phrase_docs AS (
+    SELECT occurrence.doc_id
+    FROM (
+      SELECT p.doc_id, pw.phrase
+      FROM phrase_words pw
+      JOIN terms t    ON t.raw = pw.raw
+      JOIN postings p ON p.term_id = t.id
+      CROSS JOIN LATERAL unnest(p.positions) AS pos
+      GROUP BY p.doc_id, pw.phrase, pos - pw.idx
+      HAVING COUNT(*) = (SELECT COUNT(*) FROM phrase_words l WHERE l.phrase = pw.phrase)
+    ) occurrence
+    GROUP BY occurrence.doc_id
+    HAVING COUNT(DISTINCT occurrence.phrase) = (SELECT COUNT(DISTINCT phrase) FROM phrase_words)
+  )`
+
+// phraseArgs flattens phrases into the word, phrase number and word index arrays
+// read by phraseDocsCTE. Phrases of fewer than two words constrain nothing beyond
+// their words being query terms, so they are left out.
+func phraseArgs(phrases [][]string) (words []string, phraseNums []int, indexes []int) {
+	words, phraseNums, indexes = []string{}, []int{}, []int{}
+	for n, phrase := range phrases {
+		if len(phrase) < 2 {
+			continue
+		}
+		for i, word := range phrase {
+			words = append(words, word)
+			phraseNums = append(phraseNums, n)
+			indexes = append(indexes, i)
+		}
+	}
+	return words, phraseNums, indexes
+}
+
+// phraseTerms returns terms with the words of every phrase appended, so phrase
+// words are scored like any other query term.
+func phraseTerms(terms []string, phrases [][]string) []string {
+	for _, phrase := range phrases {
+		terms = append(terms, phrase...)
+	}
+	return terms
+}
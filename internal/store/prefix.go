@@ -0,0 +1,20 @@
+// Package store provides grouping of prefix query expansions for the minimum match.
+package store
+
+// matchGroups returns the minimum-match group of each term, in order: the prefix a
+// term was expanded from, per prefixes, or the term itself. It also returns the
+// number of distinct groups. Prefix groups are marked with a trailing '*', so a
+// typed term never shares a group with a prefix spelled the same way.
+func matchGroups(terms []string, prefixes map[string]string) (groups []string, n int) {
+	groups = make([]string, len(terms))
+	seen := make(map[string]struct{}, len(terms))
+	for i, term := range terms {
+		group := term
+		if prefix, ok := prefixes[term]; ok {
+			group = prefix + "*"
+		}
+		groups[i] = group
+		seen[group] = struct{}{}
+	}
+	return groups, len(seen)
+}
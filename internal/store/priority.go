@@ -0,0 +1,56 @@
+// Package store provides heuristic prioritization of frontier URLs.
+package store
+
+import (
+	"net/url"
+	"strings"
+)
+
+// PriorityWeights configures the URL heuristics used to prioritize the frontier.
+// Each weight is a penalty subtracted from a URL's score, so URLs that look like
+// content pages are dequeued before index, tag and listing pages.
+type PriorityWeights struct {
+	Depth         float64  // Penalty per level of crawl depth
+	QueryString   float64  // Penalty for URLs that carry a query string
+	DemotedPath   float64  // Penalty per demoted keyword found in the path
+	DemotedTokens []string // Lowercase path fragments that suggest a non-content page
+	Inlink        float64  // Bonus added for every distinct page found linking to a URL
+}
+
+// DefaultPriorityWeights returns weights that demote deep, parameterized and listing URLs.
+func DefaultPriorityWeights() PriorityWeights {
+	return PriorityWeights{
+		Depth:       1.0,
+		QueryString: 2.0,
+		Inlink:      0.5,
+		DemotedPath: 3.0,
+		DemotedTokens: []string{
+			"/tag/", "/tags/", "/category/", "/categories/", "/archive/", "/page/", "/search",
+			"/wiki/special:", "/wiki/category:", "/wiki/talk:", "/wiki/help:", "/wiki/file:",
+		},
+	}
+}
+
+// ScoreURL returns the priority of a URL found at the given depth. Higher scores
+// are crawled first; a shallow, parameter-free content URL scores 0.
+func ScoreURL(rawUrl string, depth int, w PriorityWeights) float64 {
+	score := -w.Depth * float64(depth)
+
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return score
+	}
+
+	if u.RawQuery != "" {
+		score -= w.QueryString
+	}
+
+	path := strings.ToLower(u.Path)
+	for _, token := range w.DemotedTokens {
+		if strings.Contains(path, token) {
+			score -= w.DemotedPath
+		}
+	}
+
+	return score
+}
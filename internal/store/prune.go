@@ -0,0 +1,92 @@
+// Package store provides pruning of rare terms from the index.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// pruneGenerationKey is the index_meta key counting PruneRareTerms runs, so processes
+// caching term ids can tell when terms may have been deleted under them.
+const pruneGenerationKey = "prune_generation"
+
+// bump the prune generation, locking it until the prune commits
+const bumpPruneGenerationStmt = `INSERT INTO index_meta (key, value) VALUES ($1, '1')
+ON CONFLICT (key) DO UPDATE SET value = (index_meta.value::bigint + 1)::text;`
+
+// read the prune generation, holding it so a prune can't start until the reader commits
+const getPruneGenerationStmt = `SELECT value::bigint FROM index_meta WHERE key = $1 FOR SHARE;`
+
+// deleteRareTermsStmt removes terms in fewer than $1 documents; their postings cascade.
+// df only counts bodies, so terms still in a title are kept for BM25F.
+const deleteRareTermsStmt = `DELETE FROM terms t
+WHERE t.df < $1
+  AND NOT EXISTS (SELECT 1 FROM postings p WHERE p.term_id = t.id AND p.tf_title > 0);`
+
+// zeroPrunedNormsStmt zeroes the norm of documents left without body postings, which
+// the norm update skips.
+const zeroPrunedNormsStmt = `UPDATE docs d SET norm = 0
+WHERE NOT EXISTS (SELECT 1 FROM postings p WHERE p.doc_id = d.id AND p.tf_raw > 0);`
+
+// PruneRareTerms deletes every term appearing in fewer than minDF document bodies, and
+// in no title, with its postings, and returns how many terms were removed. Document frequencies are
+// refreshed first so the threshold sees current counts, and norms are recomputed
+// afterwards with the stored TF scheme, all in one transaction (or savepoint).
+//
+// Term ids are not reused, so a TermCache holding pruned terms must be cleared. The
+// prune bumps the generation returned by GetPruneGeneration, which indexers in other
+// processes check to know when to clear theirs.
+func PruneRareTerms(ctx context.Context, db DBTX, minDF int) (int64, error) {
+	if minDF <= 0 {
+		return 0, fmt.Errorf("min df must be positive, got %d", minDF)
+	}
+
+	var pruned int64
+	err := withTx(ctx, db, func(tx DBTX) error {
+		// Bump the generation first, waiting out transactions that already read it
+		if _, err := tx.Exec(ctx, bumpPruneGenerationStmt, pruneGenerationKey); err != nil {
+			return err
+		}
+		if err := UpdateDocumentFrequency(ctx, tx); err != nil {
+			return err
+		}
+
+		tag, err := tx.Exec(ctx, deleteRareTermsStmt, minDF)
+		if err != nil {
+			return err
+		}
+		pruned = tag.RowsAffected()
+		if pruned == 0 {
+			return nil
+		}
+
+		if _, err := tx.Exec(ctx, zeroPrunedNormsStmt); err != nil {
+			return err
+		}
+		scheme, err := GetNormTFScheme(ctx, tx)
+		if err != nil {
+			return err
+		}
+		return UpdateDocumentNormsWith(ctx, tx, scheme)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return pruned, nil
+}
+
+// GetPruneGeneration returns the number of times PruneRareTerms has run. Reading it
+// in a transaction holds off any prune until the transaction ends, so term ids cached
+// under the returned generation stay valid for the rest of it: an indexer calls it
+// before writing postings, and clears its TermCache whenever the generation changed.
+func GetPruneGeneration(ctx context.Context, db DBTX) (int64, error) {
+	var generation int64
+	err := db.QueryRow(ctx, getPruneGenerationStmt, pruneGenerationKey).Scan(&generation)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return generation, err
+}
@@ -2,21 +2,68 @@ package store
 
 import (
 	"context"
+	"fmt"
+	"strings"
 )
 
+// corpusStatsCTE defines the corpus CTE used by the BM25 searches: document count N,
+// average body length avgdl and average title length avgtl. It reads the values the
+// ranker cached in corpus_stats, avoiding an aggregate over docs on every query, and
+// only computes them live until the ranker has first run. Cached values lag documents
+// indexed since the last ranking run, just as idf does.
+const corpusStatsCTE = `corpus AS (
+    SELECT n AS N, avgdl, avgtl FROM corpus_stats
+    UNION ALL
+    SELECT COUNT(*)::real, AVG(len)::real, AVG(title_len)::real
+    FROM docs
+    WHERE len > 0 AND NOT EXISTS (SELECT 1 FROM corpus_stats)
+  )`
+
+// corpusStatsQuery computes the single corpus_stats row.
+const corpusStatsQuery = `SELECT TRUE, COUNT(*)::real, AVG(len)::real, AVG(title_len)::real, now()
+FROM docs
+WHERE len > 0`
+
+// UpdateCorpusStats caches the corpus-level statistics read by corpusStatsCTE.
+// Phase 0 of the ranking update process.
+const updateCorpusStatsStmt = `INSERT INTO corpus_stats (id, n, avgdl, avgtl, updated_at)
+` + corpusStatsQuery + `
+ON CONFLICT (id) DO UPDATE SET
+	n = EXCLUDED.n,
+	avgdl = EXCLUDED.avgdl,
+	avgtl = EXCLUDED.avgtl,
+	updated_at = EXCLUDED.updated_at;`
+
+func UpdateCorpusStats(ctx context.Context, db DBTX) error {
+	_, err := db.Exec(ctx, updateCorpusStatsStmt)
+	return err
+}
+
 // UpdateDocumentFrequency updates the df (document frequency) for all terms
 // based on the current postings. Phase 1 of the ranking update process.
+// Only body postings count: a title-only posting has no body frequency, so it adds
+// nothing to a BM25 or tf-idf score and mustn't make the term look more common.
 const updateDocumentFrequencyStmt = `UPDATE terms t
 SET df = x.df
-FROM (
+FROM (` + documentFrequencyQuery + `) x
+WHERE t.id = x.term_id;`
+
+// documentFrequencyQuery computes the df of every term with a body posting.
+const documentFrequencyQuery = `
   SELECT term_id, COUNT(*)::int AS df
   FROM postings
+  WHERE tf_raw > 0
   GROUP BY term_id
-) x
-WHERE t.id = x.term_id;`
+`
 
-// SetZeroDfForTermsWithNoPostings ensures terms with no postings get df=0
-const setZeroDfForTermsWithNoPostingsStmt = `UPDATE terms SET df = 0 WHERE df IS NULL;`
+// SetZeroDfForTermsWithNoPostings ensures terms with no body postings get df=0,
+// including terms whose last body posting went away since the previous run
+const setZeroDfForTermsWithNoPostingsStmt = `UPDATE terms t SET df = 0
+WHERE ` + zeroDfCondition + `;`
+
+// zeroDfCondition matches the terms of alias t that setZeroDfForTermsWithNoPostingsStmt updates.
+const zeroDfCondition = `t.df IS DISTINCT FROM 0
+  AND NOT EXISTS (SELECT 1 FROM postings p WHERE p.term_id = t.id AND p.tf_raw > 0)`
 
 func UpdateDocumentFrequency(ctx context.Context, db DBTX) error {
 	_, err := db.Exec(ctx, updateDocumentFrequencyStmt)
@@ -43,31 +90,117 @@ func UpdateInverseDocumentFrequency(ctx context.Context, db DBTX) error {
 	return err
 }
 
+// SetZeroNormForDocsWithNoPostings ensures docs with no postings get norm=0
+const setZeroNormForDocsWithNoPostingsStmt = `UPDATE docs SET norm = 0 WHERE norm IS NULL;`
+
 // UpdateDocumentNorms updates the norm (vector magnitude) for all documents
 // using TF-IDF weights. Phase 3 of the ranking update process.
-// TF formula: 1 + ln(tf_raw)
+// TF formula: 1 + ln(tf_raw); see UpdateDocumentNormsWith for other schemes.
 // Norm formula: sqrt(sum((tf * idf)^2))
-const updateDocumentNormsStmt = `UPDATE docs d
-SET norm = x.norm
-FROM (
-  SELECT
-    p.doc_id,
-    SQRT(SUM(POWER((1.0 + LN(p.tf_raw::real)) * t.idf, 2))) AS norm
-  FROM postings p
-  JOIN terms t ON t.id = p.term_id
-  GROUP BY p.doc_id
-) x
-WHERE d.id = x.doc_id;`
+func UpdateDocumentNorms(ctx context.Context, db DBTX) error {
+	return UpdateDocumentNormsWith(ctx, db, TFLog)
+}
 
-// SetZeroNormForDocsWithNoPostings ensures docs with no postings get norm=0
-const setZeroNormForDocsWithNoPostingsStmt = `UPDATE docs SET norm = 0 WHERE norm IS NULL;`
+// IndexWatermark summarizes the state of the docs table so callers can
+// cheaply detect whether anything was indexed since a previous check.
+type IndexWatermark struct {
+	MaxDocId  int64 // Highest document id currently in the index
+	DocCount  int64 // Number of documents currently in the index
+	Revisions int64 // Sum of every document's revision, which grows whenever one is re-indexed
+}
 
-func UpdateDocumentNorms(ctx context.Context, db DBTX) error {
-	_, err := db.Exec(ctx, updateDocumentNormsStmt)
+const getIndexWatermarkStmt = `SELECT COALESCE(MAX(id), 0), COUNT(*), COALESCE(SUM(revision), 0) FROM docs;`
+
+// GetIndexWatermark returns the current IndexWatermark of the docs table. New
+// documents move MaxDocId and DocCount, deleted ones DocCount, and re-indexed ones,
+// which keep their id, move Revisions. Unlike a timestamp, the sum of revisions
+// can't be fooled by a transaction that commits after a later one.
+func GetIndexWatermark(ctx context.Context, db DBTX) (IndexWatermark, error) {
+	var w IndexWatermark
+	err := db.QueryRow(ctx, getIndexWatermarkStmt).Scan(&w.MaxDocId, &w.DocCount, &w.Revisions)
+	return w, err
+}
+
+// PhaseEstimate describes what a ranking phase would touch, without running it.
+type PhaseEstimate struct {
+	Phase string // Name of the ranking phase
+	Rows  int64  // Number of rows the phase's statement would write
+	Plan  string // Query plan of the phase's statement, from EXPLAIN (not ANALYZE)
+}
+
+// rankingPhase is one statement of the ranking update process, with a query counting
+// the rows it would write.
+type rankingPhase struct {
+	phase string // Name of the phase
+	stmt  string // Statement the phase runs
+	count string // Counts the rows stmt would write, from the same subquery or condition
+}
+
+// rankingPhases returns the statements of the ranking update process in the order
+// they run, with the document norms computed by scheme over terms in at least minDF
+// documents, as UpdateDocumentNormsMinDF does.
+func rankingPhases(scheme TFScheme, minDF int) []rankingPhase {
+	return []rankingPhase{
+		{"corpus_stats", updateCorpusStatsStmt, countRows(corpusStatsQuery)},
+		{"document_frequency", updateDocumentFrequencyStmt, countRows(documentFrequencyQuery)},
+		{"zero_document_frequency", setZeroDfForTermsWithNoPostingsStmt, `SELECT COUNT(*) FROM terms t WHERE ` + zeroDfCondition + `;`},
+		{"inverse_document_frequency", updateInverseDocumentFrequencyStmt, `SELECT COUNT(*) FROM terms;`},
+		{"document_norms", documentNormsStmt(scheme, minDF), countRows(documentNormsQuery(scheme, minDF))},
+		{"zero_document_norms", setZeroNormForDocsWithNoPostingsStmt, `SELECT COUNT(*) FROM docs WHERE norm IS NULL;`},
+	}
+}
+
+// countRows returns a statement counting the rows of query.
+func countRows(query string) string {
+	return "SELECT COUNT(*) FROM (" + query + ") x;"
+}
+
+// ExplainRankingUpdates estimates every ranking phase, for norms computed with scheme
+// and minDF as the ranker would, by counting the rows each statement would write and
+// collecting its query plan. No update is executed; plain EXPLAIN only plans the
+// statement. Counts for later phases reflect the current data, so they may differ
+// once earlier phases have actually run.
+func ExplainRankingUpdates(ctx context.Context, db DBTX, scheme TFScheme, minDF int) ([]PhaseEstimate, error) {
+	if err := scheme.Validate(); err != nil {
+		return nil, err
+	}
+	if minDF < 0 {
+		return nil, fmt.Errorf("min df must not be negative, got %d", minDF)
+	}
+
+	phases := rankingPhases(scheme, minDF)
+	estimates := make([]PhaseEstimate, 0, len(phases))
+	for _, p := range phases {
+		est := PhaseEstimate{Phase: p.phase}
+		if err := db.QueryRow(ctx, p.count).Scan(&est.Rows); err != nil {
+			return nil, err
+		}
+
+		plan, err := explainPlan(ctx, db, p.stmt)
+		if err != nil {
+			return nil, err
+		}
+		est.Plan = plan
+		estimates = append(estimates, est)
+	}
+	return estimates, nil
+}
+
+// explainPlan returns the text query plan of a statement without executing it.
+func explainPlan(ctx context.Context, db DBTX, stmt string) (string, error) {
+	rows, err := db.Query(ctx, "EXPLAIN "+stmt)
 	if err != nil {
-		return err
+		return "", err
 	}
+	defer rows.Close()
 
-	_, err = db.Exec(ctx, setZeroNormForDocsWithNoPostingsStmt)
-	return err
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), rows.Err()
 }
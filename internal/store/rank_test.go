@@ -0,0 +1,101 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jdpolicano/go-search/internal/store"
+)
+
+// BenchmarkSearchBM25CorpusStats compares queries reading the corpus statistics the
+// ranker cached in corpus_stats with queries computing them over docs every time,
+// as they do before the ranker has first run.
+func BenchmarkSearchBM25CorpusStats(b *testing.B) {
+	s := newBenchStore(b, 20000)
+	ctx := context.Background()
+	terms := []string{"word3", "word40", "word120"}
+
+	benchmarks := []struct {
+		name    string
+		prepare string // Run before timing
+	}{
+		{"cached", ""},
+		{"live aggregate", "DELETE FROM corpus_stats"},
+	}
+	var first []store.SearchResult
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			if bm.prepare != "" {
+				if _, err := s.Pool.Exec(ctx, bm.prepare); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			// Both must score alike while the cache is current
+			results, err := store.SearchBM25(ctx, s.Pool, terms, store.SearchOptions{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			if first == nil {
+				first = results
+			} else if len(results) != len(first) || (len(results) > 0 && results[0].ID != first[0].ID) {
+				b.Fatalf("%s ranked differently from %s", bm.name, benchmarks[0].name)
+			}
+
+			for b.Loop() {
+				if _, err := store.SearchBM25(ctx, s.Pool, terms, store.SearchOptions{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestExplainRankingUpdatesWritesNothing(t *testing.T) {
+	s, ids := newSearchStore(t)
+	ctx := context.Background()
+
+	// Leave the norms for the explain to count, as after indexing
+	if _, err := s.Pool.Exec(ctx, "UPDATE docs SET norm = NULL"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		scheme store.TFScheme
+		minDF  int
+	}{
+		{"log", store.TFLog, 0},
+		{"augmented", store.TFAugmented, 0},
+		{"min df", store.TFRaw, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimates, err := store.ExplainRankingUpdates(ctx, s.Pool, tt.scheme, tt.minDF)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows := make(map[string]int64)
+			for _, est := range estimates {
+				if est.Plan == "" {
+					t.Errorf("%s: no plan", est.Phase)
+				}
+				rows[est.Phase] = est.Rows
+			}
+			if rows["corpus_stats"] != 1 {
+				t.Errorf("corpus_stats would write %d rows, want 1", rows["corpus_stats"])
+			}
+			if rows["zero_document_norms"] != int64(len(ids)) {
+				t.Errorf("zero_document_norms would write %d rows, want %d", rows["zero_document_norms"], len(ids))
+			}
+
+			var unset int
+			if err := s.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM docs WHERE norm IS NULL").Scan(&unset); err != nil {
+				t.Fatal(err)
+			}
+			if unset != len(ids) {
+				t.Errorf("%d of %d norms still unset after explain", unset, len(ids))
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// Package store provides term co-occurrence queries for related term suggestions.
+package store
+
+import (
+	"context"
+)
+
+// DefaultRelatedSample is the number of documents containing a term that RelatedTerms
+// examines, bounding its cost for very common terms.
+const DefaultRelatedSample = 1000
+
+// minRelatedCoOccurrences drops terms seen alongside the target only once, whose
+// PMI is high by chance rather than by association.
+const minRelatedCoOccurrences = 2
+
+// score co-occurring terms by pointwise mutual information over a random sample of the
+// target's documents: ln(P(term | target) / P(term)), with P(term | target) estimated
+// as co / sample and P(term) as df / N. Scaling by ln(1 + co) favors well supported
+// associations over rare terms that happen to co-occur a couple of times.
+const relatedTermsStmt = `WITH target AS (
+  SELECT id FROM terms WHERE raw = $1
+),
+sample AS (
+  SELECT p.doc_id
+  FROM postings p
+  JOIN target ON p.term_id = target.id
+  ORDER BY random()
+  LIMIT $2
+),
+sample_size AS (
+  SELECT COUNT(*)::float AS s FROM sample
+),
+n AS (
+  SELECT COUNT(*)::float AS n FROM docs
+),
+co AS (
+  SELECT p.term_id, COUNT(*) AS co
+  FROM postings p
+  JOIN sample ON sample.doc_id = p.doc_id
+  WHERE p.term_id <> (SELECT id FROM target)
+  GROUP BY p.term_id
+  HAVING COUNT(*) >= $3
+)
+SELECT t.raw, co.co, LN((co.co / ss.s) / (t.df / n.n)) * LN(1 + co.co) AS score
+FROM co
+JOIN terms t ON t.id = co.term_id
+CROSS JOIN sample_size ss
+CROSS JOIN n
+WHERE t.df > 0
+ORDER BY score DESC, t.raw
+LIMIT $4;`
+
+// TermScore is a term related to a query term, with how strongly they are associated.
+type TermScore struct {
+	Term          string  `json:"term"`
+	CoOccurrences int     `json:"co_occurrences"` // Sampled documents containing both terms
+	Score         float64 `json:"score"`          // PMI weighted by co-occurrence count
+}
+
+// RelatedTerms returns up to limit terms that appear in the same documents as raw more
+// often than their overall frequency would predict. At most sample documents containing
+// raw are examined. Document frequencies come from the ranker, so terms indexed since
+// its last run are not considered. An unknown term has no related terms.
+func RelatedTerms(ctx context.Context, db DBTX, raw string, limit, sample int) ([]TermScore, error) {
+	rows, err := db.Query(ctx, relatedTermsStmt, raw, sample, minRelatedCoOccurrences, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	related := make([]TermScore, 0, limit)
+	for rows.Next() {
+		var ts TermScore
+		if err := rows.Scan(&ts.Term, &ts.CoOccurrences, &ts.Score); err != nil {
+			return nil, err
+		}
+		related = append(related, ts)
+	}
+	return related, rows.Err()
+}
@@ -0,0 +1,72 @@
+// Package store provides retry handling for transient database errors.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// RetryPolicy configures how transient database errors are retried.
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts, including the first
+	BaseDelay   time.Duration // Delay before the first retry, doubled for each later retry
+	MaxDelay    time.Duration // Upper bound on the delay between attempts
+}
+
+// DefaultRetryPolicy returns a RetryPolicy suited to short write transactions.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   50 * time.Millisecond,
+		MaxDelay:    2 * time.Second,
+	}
+}
+
+// ErrorIsRetryable reports whether an error is a transient lock or serialization
+// failure that is likely to succeed if the whole transaction is run again.
+func ErrorIsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	switch pgErr.Code {
+	case pgerrcode.SerializationFailure, pgerrcode.DeadlockDetected, pgerrcode.LockNotAvailable:
+		return true
+	default:
+		return false
+	}
+}
+
+// WithRetry runs fn, retrying with exponential backoff while it fails with a
+// retryable error. Permanent errors are returned immediately, and exhausting the
+// attempts returns an error wrapping the last failure.
+func WithRetry(ctx context.Context, policy RetryPolicy, fn func() error) error {
+	attempts := max(policy.MaxAttempts, 1)
+	delay := policy.BaseDelay
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = fn(); err == nil || !ErrorIsRetryable(err) {
+			return err
+		}
+
+		if attempt == attempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, policy.MaxDelay)
+	}
+
+	return fmt.Errorf("giving up after %d attempts: %w", attempts, err)
+}
@@ -0,0 +1,71 @@
+// Package store provides schema validation for the search engine database.
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// requiredColumns lists the columns, by table, that the store's queries depend on.
+// Columns added after the original schema are included so older databases are
+// caught at startup instead of failing with opaque SQL errors at query time.
+var requiredColumns = map[string][]string{
+	"docs":              {"id", "url", "domain", "hash", "len", "title", "snippet", "norm", "title_len", "revision"},
+	"terms":             {"id", "raw", "df", "idf"},
+	"postings":          {"term_id", "doc_id", "tf_raw", "tf_title", "positions"},
+	"frontier":          {"url", "url_norm", "parent_url", "depth", "status", "priority", "failure_reason", "attempts"},
+	"inlinks":           {"from_url", "to_url_norm"},
+	"index_meta":        {"key", "value"},
+	"doc_boost":         {"doc_id", "boost"},
+	"domain_exclusions": {"domain", "reason"},
+	"corpus_stats":      {"id", "n", "avgdl", "avgtl", "updated_at"},
+}
+
+const getColumnsStmt = `SELECT table_name, column_name
+FROM information_schema.columns
+WHERE table_schema = current_schema()
+  AND table_name = ANY($1::text[]);`
+
+// CheckSchema verifies that every column the store relies on exists, returning an
+// error naming the missing columns and how to migrate if any are absent.
+func CheckSchema(ctx context.Context, db DBTX) error {
+	tables := make([]string, 0, len(requiredColumns))
+	for table := range requiredColumns {
+		tables = append(tables, table)
+	}
+
+	rows, err := db.Query(ctx, getColumnsStmt, tables)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	present := make(map[string]bool)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return err
+		}
+		present[table+"."+column] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var missing []string
+	for table, columns := range requiredColumns {
+		for _, column := range columns {
+			if !present[table+"."+column] {
+				missing = append(missing, table+"."+column)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("database schema is out of date, missing columns: %s (apply assets/sql/schema.sql to migrate)", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -5,6 +5,12 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jdpolicano/go-search/internal/extract"
 )
 
 // SearchResult represents a single search result with BM25 score
@@ -15,69 +21,418 @@ type SearchResult struct {
 	Snippet *string `json:"snippet"`
 	Len     int     `json:"len"`
 	Score   float64 `json:"score"`
+
+	Explanation []TermContribution `json:"explanation,omitempty"` // Per-term score breakdown, only set when explaining
+	Highlights  []extract.Range    `json:"highlights,omitempty"`  // Rune offsets of matched terms in Snippet, only set when requested
+}
+
+// TermContribution describes how much a single query term contributed to a result's score.
+type TermContribution struct {
+	Term         string  `json:"term"`
+	TF           int     `json:"tf"`
+	IDF          float64 `json:"idf"`
+	Contribution float64 `json:"contribution"`
+}
+
+// NormalizeQueryTerms de-duplicates query terms, returning the unique terms in
+// sorted order (stable for logging and cache keys) along with each term's count
+// in the original query, for rankers that weight by query term frequency.
+func NormalizeQueryTerms(terms []string) (unique []string, counts map[string]int) {
+	counts = make(map[string]int, len(terms))
+	for _, term := range terms {
+		if counts[term] == 0 {
+			unique = append(unique, term)
+		}
+		counts[term]++
+	}
+	sort.Strings(unique)
+	return unique, counts
+}
+
+// Default BM25 parameters used when SearchOptions leaves them unset.
+const (
+	DefaultK1 = 1.2
+	DefaultB  = 0.75
+)
+
+// SearchOptions configures a BM25 search.
+type SearchOptions struct {
+	Limit   int      // Maximum number of results to return
+	Offset  int      // Number of top results to skip, for pagination
+	K1      *float64 // BM25 term frequency saturation; nil uses DefaultK1
+	B       *float64 // BM25 length normalization strength; nil uses DefaultB, 0 disables it
+	Explain bool     // Attach a per-term score breakdown to each result (costs a second query)
+	// MinDistinctMatches is how many distinct query terms a document must contain.
+	// 0 uses min(len(terms), 2); 1 is a pure OR. Values above the number of
+	// (normalized) query terms can never be met and yield no results. The
+	// expansions of one prefix count as a single term, see Prefixes.
+	MinDistinctMatches int
+	// Prefixes maps each term expanded from a prefix query term to that prefix, e.g.
+	// "computer" and "computing" to "comput". A document containing any number of a
+	// prefix's expansions has matched it once for MinDistinctMatches, so a lone
+	// "comput*" matches documents with just one of its expansions. Terms absent from
+	// the map count on their own.
+	Prefixes map[string]string
+	// BoostMode selects how doc_boost values adjust scores; the zero value multiplies.
+	BoostMode BoostMode
+	// Parallel splits wide queries into term groups searched concurrently, when the
+	// search is run against a pool rather than a single connection or transaction.
+	Parallel ParallelOptions
+	// After continues from a previous page's NextCursor; nil starts at the top. It
+	// can be combined with Offset, which then skips results after the cursor.
+	After *Cursor
+	// MinDF drops query terms appearing in fewer documents, as if they were stop
+	// words; 0 keeps every term. It should match the ranker's MinDF.
+	MinDF int
+	// Phrases are word sequences, tokenized like terms, that must each appear in a
+	// result's body at consecutive positions. Their words are scored as query terms
+	// too; the terms need not repeat them. Queries with phrases are never split
+	// across term groups.
+	Phrases [][]string
+}
+
+// dropRareTermsStmt keeps the query terms in at least $2 documents.
+const dropRareTermsStmt = `SELECT raw FROM terms WHERE raw = ANY($1::text[]) AND df >= $2;`
+
+// dropRareTerms returns the terms appearing in at least minDF documents, in query order.
+func dropRareTerms(ctx context.Context, db DBTX, terms []string, minDF int) ([]string, error) {
+	rows, err := db.Query(ctx, dropRareTermsStmt, terms, minDF)
+	if err != nil {
+		return nil, err
+	}
+	common, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(terms, func(term string) bool {
+		return !slices.Contains(common, term)
+	}), nil
+}
+
+// minDistinctMatches returns the HAVING threshold for a query of n distinct terms, or
+// of n match groups when prefixes are grouped, see matchGroups.
+func (opts SearchOptions) minDistinctMatches(n int) int {
+	if opts.MinDistinctMatches > 0 {
+		return opts.MinDistinctMatches
+	}
+	return min(n, 2)
 }
 
 // SearchBM25 performs a BM25 search using the provided query terms
-// BM25 parameters: k1=1.2, b=0.75
+// BM25 parameters: k1 ($5) and b ($6), see SearchOptions for defaults. Each match's
+// score is then adjusted by its doc_boost row, if any, as selected by the boost mode ($7).
+// Results continue after the cursor ($8, $9) when one is given; ties on score are
+// broken by descending id so every page boundary is well defined. When phrases are
+// given ($10-$12, see phraseDocsCTE) only documents containing all of them match.
+// The minimum match ($2) counts distinct match groups ($13), one per term except
+// that a prefix's expansions share one.
 const searchBM25Stmt = `
 WITH
   params AS (
-    SELECT 1.2::real AS k1, 0.75::real AS b
+    SELECT $5::real AS k1, $6::real AS b
   ),
-  corpus AS (
-    SELECT COUNT(*)::real AS N, AVG(len)::real AS avgdl
-    FROM docs
-    WHERE len > 0
+  ` + corpusStatsCTE + `,
+  ` + phraseDocsCTE + `,
+  q AS (
+    -- query terms are de-duped by the caller (BM25 typically doesn't need query TF for basic ranking)
+    SELECT * FROM unnest($1::text[], $13::text[]) AS q(raw, grp)
+  ),
+  matches AS (
+    SELECT
+      d.id,
+      d.url,
+      d.title,
+      d.snippet,
+      d.len,
+      -- Contributions are added as float8 in term order, so searchBM25Parallel can
+      -- add the same values in the same order and arrive at the same score
+      SUM(
+        (
+          -- idf (BM25 variant; +1 makes it non-negative even for very common terms)
+          (LN(((corpus.N - t.df::real + 0.5) / (t.df::real + 0.5)) + 1.0))
+          *
+          -- BM25 tf component with length normalization
+          (
+            (p.tf_raw::real * (params.k1 + 1.0))
+            /
+            (p.tf_raw::real
+              + params.k1 * (1.0 - params.b + params.b * (d.len::real / NULLIF(corpus.avgdl, 0)))
+            )
+          )
+        )::float8
+        ORDER BY q.raw COLLATE "C"
+      ) AS score
+    FROM q
+    JOIN terms t     ON t.raw = q.raw
+    JOIN postings p  ON p.term_id = t.id
+    JOIN docs d      ON d.id = p.doc_id
+    CROSS JOIN params
+    CROSS JOIN corpus
+    WHERE d.len > 0
+      AND p.tf_raw > 0 -- title-only postings score nothing, so they mustn't count towards the minimum match
+      AND t.df IS NOT NULL
+      AND (cardinality($10::text[]) = 0 OR d.id IN (SELECT doc_id FROM phrase_docs))
+    GROUP BY d.id, d.url, d.title, d.snippet, d.len
+    HAVING COUNT(DISTINCT q.grp) >= $2
   ),
+  ranked AS (
+    SELECT
+      m.id,
+      m.url,
+      m.title,
+      m.snippet,
+      m.len,
+      (CASE WHEN $7::text = 'add'
+        THEN m.score + (COALESCE(b.boost, 1.0)::float8 - 1.0)
+        ELSE m.score * COALESCE(b.boost, 1.0)::float8
+      END) AS score
+    FROM matches m
+    LEFT JOIN doc_boost b ON b.doc_id = m.id
+  )
+SELECT id, url, title, snippet, len, score
+FROM ranked
+WHERE $8::float8 IS NULL OR (score, id) < ($8::float8, $9::bigint)
+ORDER BY score DESC, id DESC
+LIMIT $3
+OFFSET $4;`
+
+func SearchBM25(ctx context.Context, db DBTX, terms []string, opts SearchOptions) ([]SearchResult, error) {
+	terms = phraseTerms(terms, opts.Phrases)
+	if len(terms) == 0 {
+		return nil, errors.New("no terms provided for search")
+	}
+	terms, _ = NormalizeQueryTerms(terms)
+	if opts.MinDF > 0 {
+		var err error
+		if terms, err = dropRareTerms(ctx, db, terms, opts.MinDF); err != nil {
+			return nil, err
+		}
+		if len(terms) == 0 {
+			return nil, nil
+		}
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 10 // default limit
+	}
+
+	// Only unset parameters take defaults: b = 0 is a valid choice, not a missing one
+	k1, b := DefaultK1, DefaultB
+	if opts.K1 != nil {
+		k1 = *opts.K1
+	}
+	if opts.B != nil {
+		b = *opts.B
+	}
+	opts.K1, opts.B = &k1, &b
+
+	var results []SearchResult
+	if pool, ok := opts.shouldSearchParallel(db, len(terms)); ok {
+		var err error
+		results, err = searchBM25Parallel(ctx, pool, terms, limit, opts)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		afterScore, afterId := opts.After.args()
+		phraseWords, phraseNums, phraseIndexes := phraseArgs(opts.Phrases)
+		groups, n := matchGroups(terms, opts.Prefixes)
+		rows, err := db.Query(ctx, searchBM25Stmt, terms, opts.minDistinctMatches(n), limit, max(opts.Offset, 0), k1, b, string(opts.BoostMode), afterScore, afterId,
+			phraseWords, phraseNums, phraseIndexes, groups)
+		if err != nil {
+			return nil, err
+		}
+		results, err = scanSearchResults(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Explain && len(results) > 0 {
+		if err := explainBM25(ctx, db, terms, opts, results); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// explainBM25Stmt computes the per-term BM25 contributions for a fixed set of documents.
+// The formula must stay in sync with searchBM25Stmt so contributions sum to the score
+// before any doc boost is applied.
+const explainBM25Stmt = `
+WITH
+  params AS (
+    SELECT $3::real AS k1, $4::real AS b
+  ),
+  ` + corpusStatsCTE + `,
   q AS (
-    -- de-dupe query terms (BM25 typically doesn't need query TF for basic ranking)
     SELECT DISTINCT UNNEST($1::text[]) AS raw
   )
 SELECT
   d.id,
-  d.url,
-  d.title,
-  d.snippet,
-  d.len,
-  SUM(
-    -- idf (BM25 variant; +1 makes it non-negative even for very common terms)
-    (LN(((corpus.N - t.df::real + 0.5) / (t.df::real + 0.5)) + 1.0))
-    *
-    -- BM25 tf component with length normalization
-    (
-      (p.tf_raw::real * (params.k1 + 1.0))
-      /
-      (p.tf_raw::real
-        + params.k1 * (1.0 - params.b + params.b * (d.len::real / NULLIF(corpus.avgdl, 0)))
-      )
+  t.raw,
+  p.tf_raw,
+  (LN(((corpus.N - t.df::real + 0.5) / (t.df::real + 0.5)) + 1.0)) AS idf,
+  (LN(((corpus.N - t.df::real + 0.5) / (t.df::real + 0.5)) + 1.0))
+  *
+  (
+    (p.tf_raw::real * (params.k1 + 1.0))
+    /
+    (p.tf_raw::real
+      + params.k1 * (1.0 - params.b + params.b * (d.len::real / NULLIF(corpus.avgdl, 0)))
     )
-  ) AS score
+  ) AS contribution
 FROM q
 JOIN terms t     ON t.raw = q.raw
 JOIN postings p  ON p.term_id = t.id
 JOIN docs d      ON d.id = p.doc_id
 CROSS JOIN params
 CROSS JOIN corpus
-WHERE d.len > 0
+WHERE d.id = ANY($2::int[])
+  AND p.tf_raw > 0
   AND t.df IS NOT NULL
-GROUP BY d.id, d.url, d.title, d.snippet, d.len
-HAVING COUNT(DISTINCT t.raw) >= $2
-ORDER BY score DESC
-LIMIT $3;`
+ORDER BY d.id, contribution DESC;`
+
+// explainBM25 attaches each result's per-term score breakdown in place. opts.K1 and
+// opts.B must be set, as SearchBM25 does before calling it.
+func explainBM25(ctx context.Context, db DBTX, terms []string, opts SearchOptions, results []SearchResult) error {
+	ids := make([]int64, len(results))
+	byId := make(map[int64]*SearchResult, len(results))
+	for i := range results {
+		ids[i] = results[i].ID
+		byId[results[i].ID] = &results[i]
+	}
+
+	rows, err := db.Query(ctx, explainBM25Stmt, terms, ids, *opts.K1, *opts.B)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var docId int64
+		var tc TermContribution
+		if err := rows.Scan(&docId, &tc.Term, &tc.TF, &tc.IDF, &tc.Contribution); err != nil {
+			return err
+		}
+		if result, ok := byId[docId]; ok {
+			result.Explanation = append(result.Explanation, tc)
+		}
+	}
+	return rows.Err()
+}
+
+// BM25FOptions configures the per-field weights and length normalization used by SearchBM25F.
+type BM25FOptions struct {
+	K1         float64 // Term frequency saturation shared by all fields
+	TitleBoost float64 // Weight applied to title term frequencies
+	BodyBoost  float64 // Weight applied to body term frequencies
+	TitleB     float64 // Length normalization strength for the title field
+	BodyB      float64 // Length normalization strength for the body field
+	Limit      int     // Maximum number of results to return
+	After      *Cursor // Continue after a previous page's NextCursor; nil starts at the top
+
+	// Prefixes groups prefix expansions for the minimum match, as in SearchOptions.
+	Prefixes map[string]string
+}
+
+// DefaultBM25FOptions returns BM25F options that favor title matches over body matches.
+func DefaultBM25FOptions() BM25FOptions {
+	return BM25FOptions{
+		K1:         1.2,
+		TitleBoost: 2.0,
+		BodyBoost:  1.0,
+		TitleB:     0.75,
+		BodyB:      0.75,
+		Limit:      10,
+	}
+}
 
-func SearchBM25(ctx context.Context, db DBTX, terms []string, limit int) ([]SearchResult, error) {
+// SearchBM25F performs a BM25F search: each field's term frequency is length
+// normalized and weighted separately, then the fields are summed into a single
+// pseudo-frequency before the BM25 saturation is applied. Both fields share the
+// term's idf, which like df only counts bodies. Results continue after the cursor
+// ($9, $10) when one is given, ordered as SearchBM25's are, and the minimum match
+// ($2) counts match groups ($11) as SearchBM25's does.
+const searchBM25FStmt = `
+WITH
+  params AS (
+    SELECT $3::real AS k1, $4::real AS w_title, $5::real AS w_body, $6::real AS b_title, $7::real AS b_body
+  ),
+  ` + corpusStatsCTE + `,
+  q AS (
+    SELECT * FROM unnest($1::text[], $11::text[]) AS q(raw, grp)
+  ),
+  weighted AS (
+    SELECT
+      d.id,
+      d.url,
+      d.title,
+      d.snippet,
+      d.len,
+      q.grp,
+      (LN(((corpus.N - t.df::real + 0.5) / (t.df::real + 0.5)) + 1.0)) AS idf,
+      -- combined, field-weighted and length-normalized term frequency
+      (
+        params.w_title * p.tf_title::real
+          / (1.0 - params.b_title + params.b_title * COALESCE(d.title_len::real / NULLIF(corpus.avgtl, 0), 1.0))
+        +
+        params.w_body * p.tf_raw::real
+          / (1.0 - params.b_body + params.b_body * COALESCE(d.len::real / NULLIF(corpus.avgdl, 0), 1.0))
+      ) AS tf
+    FROM q
+    JOIN terms t     ON t.raw = q.raw
+    JOIN postings p  ON p.term_id = t.id
+    JOIN docs d      ON d.id = p.doc_id
+    CROSS JOIN params
+    CROSS JOIN corpus
+    WHERE d.len > 0
+      AND t.df IS NOT NULL
+  ),
+  ranked AS (
+    SELECT
+      w.id,
+      w.url,
+      w.title,
+      w.snippet,
+      w.len,
+      SUM(w.idf * (w.tf * (params.k1 + 1.0)) / (w.tf + params.k1))::float8 AS score
+    FROM weighted w
+    CROSS JOIN params
+    GROUP BY w.id, w.url, w.title, w.snippet, w.len
+    HAVING COUNT(DISTINCT w.grp) >= $2
+  )
+SELECT id, url, title, snippet, len, score
+FROM ranked
+WHERE $9::float8 IS NULL OR (score, id) < ($9::float8, $10::bigint)
+ORDER BY score DESC, id DESC
+LIMIT $8;`
+
+func SearchBM25F(ctx context.Context, db DBTX, terms []string, opts BM25FOptions) ([]SearchResult, error) {
 	if len(terms) == 0 {
 		return nil, errors.New("no terms provided for search")
 	}
+	terms, _ = NormalizeQueryTerms(terms)
 
+	limit := opts.Limit
 	if limit <= 0 {
 		limit = 10 // default limit
 	}
 
-	rows, err := db.Query(ctx, searchBM25Stmt, terms, min(len(terms), 2), limit)
+	afterScore, afterId := opts.After.args()
+	groups, n := matchGroups(terms, opts.Prefixes)
+	rows, err := db.Query(ctx, searchBM25FStmt, terms, min(n, 2),
+		opts.K1, opts.TitleBoost, opts.BodyBoost, opts.TitleB, opts.BodyB, limit, afterScore, afterId, groups)
 	if err != nil {
 		return nil, err
 	}
+	return scanSearchResults(rows)
+}
+
+// scanSearchResults reads every row of a search query into SearchResults and closes the rows.
+func scanSearchResults(rows pgx.Rows) ([]SearchResult, error) {
 	defer rows.Close()
 
 	var results []SearchResult
@@ -104,6 +459,33 @@ func SearchBM25(ctx context.Context, db DBTX, terms []string, limit int) ([]Sear
 	return results, nil
 }
 
+// expandPrefixStmt finds indexed terms that start with a prefix, most common first.
+const expandPrefixStmt = `SELECT raw FROM terms
+WHERE raw LIKE $1 || '%' ESCAPE '\'
+ORDER BY df DESC NULLS LAST, raw
+LIMIT $2;`
+
+// ExpandPrefix returns up to limit indexed terms beginning with prefix, ordered by
+// descending document frequency so the expansion keeps the most useful terms.
+func ExpandPrefix(ctx context.Context, db DBTX, prefix string, limit int) ([]string, error) {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)
+	rows, err := db.Query(ctx, expandPrefixStmt, escaped, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var terms []string
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		terms = append(terms, raw)
+	}
+	return terms, rows.Err()
+}
+
 // SearchResultSlice is a helper type for JSON marshaling
 type SearchResultSlice []SearchResult
 
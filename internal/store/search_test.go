@@ -0,0 +1,142 @@
+package store_test
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/jdpolicano/go-search/internal/store"
+	"github.com/jdpolicano/go-search/internal/store/testutil"
+)
+
+// searchCorpus is a small corpus with terms of varying frequency and documents of varying length.
+var searchCorpus = []testutil.TestDoc{
+	{Url: "https://example.com/go", Title: "Go", Text: "go is a programming language go compiles fast"},
+	{Url: "https://example.com/rust", Title: "Rust", Text: "rust is a systems programming language"},
+	{Url: "https://example.com/python", Title: "Python", Text: "python is a programming language for scripting and data science work"},
+	{Url: "https://example.com/cooking", Title: "Cooking", Text: "cooking pasta takes water salt and time"},
+	{Url: "https://example.org/go-tour", Title: "Tour", Text: "a tour of go covers the go language and go tooling"},
+}
+
+// newSearchStore returns a scratch store seeded with searchCorpus, skipping the test without a database.
+func newSearchStore(t *testing.T) (store.Store, []int64) {
+	t.Helper()
+	dsn, err := testutil.TestDSN()
+	if err != nil {
+		t.Skip(err)
+	}
+	ctx := context.Background()
+	s, cleanup, err := testutil.NewTempStore(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cleanup)
+	ids, err := testutil.SeedCorpus(ctx, s.Pool, searchCorpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, ids
+}
+
+func float(v float64) *float64 { return &v }
+
+func TestSearchBM25ExplainSumsToScore(t *testing.T) {
+	s, _ := newSearchStore(t)
+
+	tests := []struct {
+		name  string
+		terms []string
+		k1, b *float64
+	}{
+		{"single term", []string{"go"}, nil, nil},
+		{"several terms", []string{"go", "programming", "language"}, nil, nil},
+		{"no length normalization", []string{"go", "language"}, nil, float(0)},
+		{"high saturation", []string{"programming", "language"}, float(2), float(1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := store.SearchBM25(context.Background(), s.Pool, tt.terms, store.SearchOptions{
+				K1: tt.k1, B: tt.b, Explain: true, MinDistinctMatches: 1,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) == 0 {
+				t.Fatal("no results")
+			}
+			for _, result := range results {
+				if len(result.Explanation) == 0 {
+					t.Errorf("%s: no explanation", result.URL)
+					continue
+				}
+				sum := 0.0
+				for _, tc := range result.Explanation {
+					sum += tc.Contribution
+				}
+				// Contributions are computed in single precision
+				if math.Abs(sum-result.Score) > 1e-4*math.Max(1, result.Score) {
+					t.Errorf("%s: contributions sum to %g, score is %g", result.URL, sum, result.Score)
+				}
+			}
+		})
+	}
+}
+
+// phraseCorpus has documents containing "computer" and "science" adjacent, apart
+// and reversed, and one whose phrase is only adjacent once its stop word is dropped.
+var phraseCorpus = []testutil.TestDoc{
+	{Url: "https://example.com/cs", Text: "computer science studies computation"},
+	{Url: "https://example.com/age", Text: "science of the computer age"},
+	{Url: "https://example.com/fair", Text: "computer lab and science fair"},
+	{Url: "https://example.com/london", Text: "university of london campus"},
+	{Url: "https://example.com/london-cs", Text: "university of london computer science degree"},
+}
+
+func TestSearchBM25Phrases(t *testing.T) {
+	dsn, err := testutil.TestDSN()
+	if err != nil {
+		t.Skip(err)
+	}
+	ctx := context.Background()
+	s, cleanup, err := testutil.NewTempStore(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+	if _, err := testutil.SeedCorpus(ctx, s.Pool, phraseCorpus); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		terms   []string
+		phrases [][]string
+		want    []int64 // Matching ids, in any order
+	}{
+		{"bag of words", []string{"computer", "science"}, nil, []int64{1, 2, 3, 5}},
+		{"adjacent phrase", nil, [][]string{{"computer", "science"}}, []int64{1, 5}},
+		{"reversed phrase", nil, [][]string{{"science", "computer"}}, []int64{2}},
+		{"stop word dropped", nil, [][]string{{"university", "london"}}, []int64{4, 5}},
+		{"two phrases", nil, [][]string{{"university", "london"}, {"computer", "science"}}, []int64{5}},
+		{"phrase and term", []string{"degree"}, [][]string{{"computer", "science"}}, []int64{1, 5}},
+		{"no adjacent match", nil, [][]string{{"lab", "fair"}}, nil},
+		{"single word phrase", nil, [][]string{{"campus"}}, []int64{4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := store.SearchBM25(ctx, s.Pool, tt.terms, store.SearchOptions{Phrases: tt.phrases, MinDistinctMatches: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int64
+			for _, result := range results {
+				got = append(got, result.ID)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,14 @@
 // Package store provides database connection and transaction management for the search engine.
+//
+// PostgreSQL, through pgx, is the only storage backend. Every store function takes a
+// DBTX, so the crawler, ingest tool, ranker and server share the same insertion and
+// query logic whether they run against the pool or inside a transaction.
 package store
 
 import (
 	"context"
 	_ "embed"
+	"os"
 
 	"github.com/jackc/pgx/v5"
 	_ "github.com/jackc/pgx/v5"
@@ -12,24 +17,151 @@ import (
 )
 
 // DBTX interface that joins pgx.Conn and pgx.Tx for easier handling of transactions.
-// A caller can just pass in either a pgx.Conn or pgx.Tx where a DBTX is expected.
+// A caller can just pass in a *pgxpool.Pool, *pgxpool.Conn, *pgx.Conn or pgx.Tx where a
+// DBTX is expected, so several store operations can be composed in one transaction
+// by passing the same pgx.Tx to each of them.
 type DBTX interface {
 	Exec(context.Context, string, ...any) (pgconn.CommandTag, error)
 	Query(context.Context, string, ...any) (pgx.Rows, error)
 	QueryRow(context.Context, string, ...any) pgx.Row
 }
 
-// Store represents the database connection pool for the search engine.
+// Compile-time checks that the pgx handles callers are expected to pass satisfy DBTX.
+var (
+	_ DBTX = (*pgxpool.Pool)(nil)
+	_ DBTX = (*pgxpool.Conn)(nil)
+	_ DBTX = (*pgx.Conn)(nil)
+	_ DBTX = (pgx.Tx)(nil)
+)
+
+// Store represents the database connection pools for the search engine.
+// Pool is the primary used for all writes. ReadPool is an optional read-only
+// replica that search queries use instead of the primary when set.
 type Store struct {
-	Pool *pgxpool.Pool
+	Pool     *pgxpool.Pool
+	ReadPool *pgxpool.Pool
+}
+
+// DefaultDSN is the connection string used when DSNEnv is unset: a local PostgreSQL
+// reached through its Unix socket in /tmp.
+const DefaultDSN = "user=postgres dbname=gosearch host=/tmp"
+
+// DSNEnv names the environment variable holding the PostgreSQL connection string.
+const DSNEnv = "GOSEARCH_DSN"
+
+// DSNFromEnv returns the connection string in DSNEnv, or DefaultDSN if it is unset.
+func DSNFromEnv() string {
+	if dsn := os.Getenv(DSNEnv); dsn != "" {
+		return dsn
+	}
+	return DefaultDSN
 }
 
-// NewStore creates a new database store with connection to PostgreSQL.
-func NewStore(dbPath string) (Store, error) {
+// NewStore creates a new database store connected to the PostgreSQL database at dsn,
+// a pgx connection string or URL such as DSNFromEnv returns.
+// It fails fast if the database schema predates columns the store depends on.
+func NewStore(dsn string) (Store, error) {
 	ctx := context.Background()
-	pool, openErr := pgxpool.New(ctx, "user=postgres dbname=gosearch host=/tmp")
+	pool, openErr := pgxpool.New(ctx, dsn)
 	if openErr != nil {
 		return Store{}, openErr
 	}
-	return Store{pool}, nil
+	if err := CheckSchema(ctx, pool); err != nil {
+		pool.Close()
+		return Store{}, err
+	}
+	return Store{Pool: pool}, nil
+}
+
+// NewStoreWithReadReplica creates a store whose search queries are served by a
+// separate read-only connection pool, isolating query latency from crawl and
+// ranking write load.
+//
+// A replica may lag the primary, so recently indexed documents or freshly
+// updated rankings can take a moment to appear in search results.
+func NewStoreWithReadReplica(dsn string, replicaDSN string) (Store, error) {
+	s, err := NewStore(dsn)
+	if err != nil {
+		return Store{}, err
+	}
+
+	cfg, err := pgxpool.ParseConfig(replicaDSN)
+	if err != nil {
+		s.Pool.Close()
+		return Store{}, err
+	}
+	// Guard against accidentally writing through the search connection.
+	cfg.ConnConfig.RuntimeParams["default_transaction_read_only"] = "on"
+
+	readPool, err := pgxpool.NewWithConfig(context.Background(), cfg)
+	if err != nil {
+		s.Pool.Close()
+		return Store{}, err
+	}
+	s.ReadPool = readPool
+	return s, nil
+}
+
+// DBTX returns the primary pool as a DBTX, for store operations that don't need a transaction.
+func (s Store) DBTX() DBTX {
+	return s.Pool
+}
+
+// InTx runs fn inside a transaction on the primary pool, committing if fn returns
+// nil and rolling back otherwise. Pass the given DBTX to each store operation
+// that should be part of the transaction.
+func (s Store) InTx(ctx context.Context, fn func(db DBTX) error) error {
+	tx, err := s.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// txBeginner is implemented by pgx pools, connections and transactions; Begin on a
+// transaction starts a savepoint.
+type txBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// withTx runs fn atomically against db: in a transaction, or a savepoint if db is
+// already a transaction. A db that can't begin one is used as is.
+func withTx(ctx context.Context, db DBTX, fn func(tx DBTX) error) error {
+	beginner, ok := db.(txBeginner)
+	if !ok {
+		return fn(db)
+	}
+
+	tx, err := beginner.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Reader returns the handle search queries should use: the read replica when
+// one is configured, otherwise the primary pool.
+func (s Store) Reader() DBTX {
+	if s.ReadPool != nil {
+		return s.ReadPool
+	}
+	return s.Pool
+}
+
+// Close closes the primary pool and the read replica pool, if any.
+func (s Store) Close() {
+	if s.ReadPool != nil {
+		s.ReadPool.Close()
+	}
+	s.Pool.Close()
 }
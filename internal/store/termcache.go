@@ -0,0 +1,113 @@
+// Package store provides an in-memory cache of term ids for the indexer.
+package store
+
+import (
+	"container/list"
+	"sync"
+)
+
+// TermCache is a fixed-size LRU cache of term raw -> term id, consulted before
+// inserting terms so common terms don't round-trip to the database for every document.
+// It is safe for concurrent use, and a nil *TermCache is a valid, always-empty cache.
+//
+// Ids must only be added once the transaction that resolved them has committed,
+// otherwise a rolled back insert could leave the cache pointing at a missing term.
+type TermCache struct {
+	mu       sync.Mutex               // Guards every field below
+	capacity int                      // Maximum number of cached terms
+	order    *list.List               // Terms from most to least recently used
+	items    map[string]*list.Element // Term raw -> element in order
+	hits     int64                    // Lookups answered from the cache
+	misses   int64                    // Lookups that had to go to the database
+}
+
+// termCacheEntry is the value stored in each TermCache list element.
+type termCacheEntry struct {
+	raw string
+	id  int64
+}
+
+// TermCacheStats is a snapshot of a TermCache's effectiveness.
+type TermCacheStats struct {
+	Hits   int64 // Lookups answered from the cache
+	Misses int64 // Lookups that had to go to the database
+	Size   int   // Number of terms currently cached
+}
+
+// NewTermCache creates a TermCache holding up to capacity terms. A capacity of
+// zero or less returns nil, which disables caching.
+func NewTermCache(capacity int) *TermCache {
+	if capacity <= 0 {
+		return nil
+	}
+	return &TermCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element, capacity),
+	}
+}
+
+// Get returns the cached id for a term, marking it as recently used.
+func (c *TermCache) Get(raw string) (int64, bool) {
+	if c == nil {
+		return 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[raw]
+	if !ok {
+		c.misses++
+		return 0, false
+	}
+	c.hits++
+	c.order.MoveToFront(elem)
+	return elem.Value.(*termCacheEntry).id, true
+}
+
+// Add caches the given term ids, evicting the least recently used terms if full.
+func (c *TermCache) Add(ids map[string]int64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for raw, id := range ids {
+		if elem, ok := c.items[raw]; ok {
+			elem.Value.(*termCacheEntry).id = id
+			c.order.MoveToFront(elem)
+			continue
+		}
+
+		c.items[raw] = c.order.PushFront(&termCacheEntry{raw, id})
+		if c.order.Len() > c.capacity {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.items, oldest.Value.(*termCacheEntry).raw)
+		}
+	}
+}
+
+// Clear empties the cache. It must be called whenever terms are deleted from the database.
+func (c *TermCache) Clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = make(map[string]*list.Element, c.capacity)
+}
+
+// Stats returns the cache's hit and miss counts and current size.
+func (c *TermCache) Stats() TermCacheStats {
+	if c == nil {
+		return TermCacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return TermCacheStats{c.hits, c.misses, len(c.items)}
+}
@@ -0,0 +1,97 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"testing"
+
+	"github.com/jdpolicano/go-search/internal/store"
+	"github.com/jdpolicano/go-search/internal/store/testutil"
+)
+
+// crawlEntry builds the i'th document of a simulated crawl: mostly words from a
+// shared vocabulary of vocab words, as on real sites, plus a few of its own.
+func crawlEntry(i, vocab int) (store.IndexEntry, error) {
+	freqs := make(map[string]int)
+	for j := range 200 {
+		freqs[fmt.Sprintf("common%d", (i*31+j*7)%vocab)]++
+	}
+	for j := range 5 {
+		freqs[fmt.Sprintf("rare%d_%d", i, j)]++
+	}
+	return store.NewIndexEntry(fmt.Sprintf("https://example.com/page/%d", i), strconv.Itoa(i), 205, freqs)
+}
+
+// BenchmarkIndexTermCache indexes a multi-document crawl with and without a term
+// cache, reporting how many term ids each document had to resolve from the database.
+func BenchmarkIndexTermCache(b *testing.B) {
+	dsn, err := testutil.TestDSN()
+	if err != nil {
+		b.Skip(err)
+	}
+
+	benchmarks := []struct {
+		name     string
+		capacity int
+	}{
+		{"uncached", 0},
+		{"cached", 50000},
+		{"cache smaller than vocabulary", 500},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			ctx := context.Background()
+			s, cleanup, err := testutil.NewTempStore(ctx, dsn)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer cleanup()
+			cache := store.NewTermCache(bm.capacity)
+
+			dbTerms := 0
+			for i := range b.N {
+				entry, err := crawlEntry(i, 2000)
+				if err != nil {
+					b.Fatal(err)
+				}
+				resolved := make(map[string]int64)
+				err = s.InTx(ctx, func(tx store.DBTX) error {
+					ids, err := store.IndexDocumentCached(ctx, tx, entry, cache)
+					maps.Copy(resolved, ids)
+					return err
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+				cache.Add(resolved)
+				dbTerms += len(resolved)
+			}
+			b.ReportMetric(float64(dbTerms)/float64(b.N), "db-terms/doc")
+		})
+	}
+}
+
+// BenchmarkTermCacheGet measures cache lookups alone, for a mix of hits and misses.
+func BenchmarkTermCacheGet(b *testing.B) {
+	cache := store.NewTermCache(10000)
+	ids := make(map[string]int64, 10000)
+	for i := range 10000 {
+		ids["term"+strconv.Itoa(i)] = int64(i)
+	}
+	cache.Add(ids)
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "term" + strconv.Itoa(i*13)
+		if i%2 == 1 {
+			keys[i] = "missing" + strconv.Itoa(i) // Every other lookup misses
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		cache.Get(keys[i%len(keys)])
+	}
+}
@@ -0,0 +1,183 @@
+// Package testutil provides helpers for building small, known corpora in a scratch
+// database, so ranking and search behavior can be checked end to end.
+package testutil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jdpolicano/go-search/internal/extract"
+	"github.com/jdpolicano/go-search/internal/store"
+)
+
+// TestDoc is an in-memory document to index.
+type TestDoc struct {
+	ID    int64  // Document id; 0 assigns the next id after the previous document's
+	Url   string // Document URL; must be unique and have a host
+	Title string // Optional title, indexed as the title field
+	Text  string // Body text, tokenized like crawled visible text
+}
+
+// SeedCorpus indexes docs under deterministic ids, then runs every ranking phase so
+// the corpus is immediately searchable. It returns each document's id in order.
+// Documents without an ID are numbered after the previous document, starting at 1.
+func SeedCorpus(ctx context.Context, db store.DBTX, docs []TestDoc) ([]int64, error) {
+	ids := make([]int64, 0, len(docs))
+	next := int64(1)
+	for _, doc := range docs {
+		id := doc.ID
+		if id == 0 {
+			id = next
+		}
+		next = id + 1
+
+		entry, err := newIndexEntry(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", doc.Url, err)
+		}
+		if err := store.IndexDocumentWithID(ctx, db, id, entry); err != nil {
+			return nil, fmt.Errorf("%s: %w", doc.Url, err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := store.UpdateCorpusStats(ctx, db); err != nil {
+		return nil, err
+	}
+	if err := store.UpdateDocumentFrequency(ctx, db); err != nil {
+		return nil, err
+	}
+	if err := store.UpdateInverseDocumentFrequency(ctx, db); err != nil {
+		return nil, err
+	}
+	if err := store.UpdateDocumentNorms(ctx, db); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// newIndexEntry tokenizes a TestDoc into an IndexEntry the way the crawler would.
+func newIndexEntry(doc TestDoc) (store.IndexEntry, error) {
+	words, err := extract.ScanWordsFromString(doc.Text)
+	if err != nil {
+		return store.IndexEntry{}, err
+	}
+	hash, err := extract.HashText(doc.Text)
+	if err != nil {
+		return store.IndexEntry{}, err
+	}
+
+	entry, err := store.NewIndexEntry(doc.Url, hash, len(words), countTerms(words))
+	if err != nil {
+		return store.IndexEntry{}, err
+	}
+	entry.Positions = termPositions(words)
+
+	if err := entry.SetTitle(doc.Title); err != nil {
+		return store.IndexEntry{}, err
+	}
+	entry.Snippet = extract.CleanSnippet(doc.Text)
+	entry.Text = doc.Text
+	return entry, nil
+}
+
+// countTerms returns the frequency of each word.
+func countTerms(words []string) map[string]int {
+	freqs := make(map[string]int, len(words))
+	for _, word := range words {
+		freqs[word]++
+	}
+	return freqs
+}
+
+// termPositions returns the positions of each word in words.
+func termPositions(words []string) map[string][]int {
+	positions := make(map[string][]int, len(words))
+	for i, word := range words {
+		positions[word] = append(positions[word], i)
+	}
+	return positions
+}
+
+// NewTempStore connects to the database at dsn and creates a uniquely named schema
+// with the search engine's tables, isolated from any other data in the database.
+// The returned cleanup function drops the schema and closes the store.
+func NewTempStore(ctx context.Context, dsn string) (store.Store, func(), error) {
+	schemaSql, err := os.ReadFile(schemaPath())
+	if err != nil {
+		return store.Store{}, nil, err
+	}
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return store.Store{}, nil, err
+	}
+	schema := "gosearch_test_" + hex.EncodeToString(suffix)
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return store.Store{}, nil, err
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema
+
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return store.Store{}, nil, err
+	}
+	cleanup := func() {
+		pool.Exec(context.Background(), "DROP SCHEMA IF EXISTS "+schema+" CASCADE")
+		pool.Close()
+	}
+
+	if _, err := pool.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		pool.Close()
+		return store.Store{}, nil, err
+	}
+	if _, err := pool.Exec(ctx, string(schemaSql)); err != nil {
+		cleanup()
+		return store.Store{}, nil, err
+	}
+	if err := store.CheckSchema(ctx, pool); err != nil {
+		cleanup()
+		return store.Store{}, nil, err
+	}
+	return store.Store{Pool: pool}, cleanup, nil
+}
+
+// schemaPath finds assets/sql/schema.sql by walking up from the working directory to
+// the module root, since tests run from their own package directory.
+func schemaPath() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return filepath.Join("assets", "sql", "schema.sql")
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return filepath.Join(dir, "assets", "sql", "schema.sql")
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return filepath.Join("assets", "sql", "schema.sql")
+		}
+		dir = parent
+	}
+}
+
+// ErrorNoTestDatabase is returned by TestDSN when no test database is configured.
+var ErrorNoTestDatabase = errors.New("GOSEARCH_TEST_DSN is not set")
+
+// TestDSN returns the connection string of the database tests should use, from the
+// GOSEARCH_TEST_DSN environment variable, so tests can skip when there is none.
+func TestDSN() (string, error) {
+	dsn := os.Getenv("GOSEARCH_TEST_DSN")
+	if dsn == "" {
+		return "", ErrorNoTestDatabase
+	}
+	return dsn, nil
+}
@@ -0,0 +1,129 @@
+package testutil
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/jdpolicano/go-search/internal/extract"
+	"github.com/jdpolicano/go-search/internal/store"
+)
+
+func TestSeedCorpus(t *testing.T) {
+	dsn, err := TestDSN()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name    string
+		docs    []TestDoc
+		wantIDs []int64
+		query   string
+		wantTop int64 // Id of the best result for query
+	}{
+		{
+			name: "assigned ids",
+			docs: []TestDoc{
+				{Url: "https://example.com/go", Title: "Go", Text: "go compiles go programs into go binaries"},
+				{Url: "https://example.com/pasta", Title: "Pasta", Text: "boil pasta in salted water"},
+			},
+			wantIDs: []int64{1, 2},
+			query:   "pasta water",
+			wantTop: 2,
+		},
+		{
+			name: "explicit ids",
+			docs: []TestDoc{
+				{ID: 10, Url: "https://example.com/a", Text: "gardening tips for tomatoes"},
+				{ID: 20, Url: "https://example.com/b", Text: "tomatoes tomatoes tomatoes everywhere in the garden"},
+			},
+			wantIDs: []int64{10, 20},
+			query:   "tomatoes",
+			wantTop: 20,
+		},
+		{
+			name: "ids continue after explicit",
+			docs: []TestDoc{
+				{ID: 5, Url: "https://example.com/a", Text: "astronomy of distant galaxies"},
+				{Url: "https://example.com/b", Title: "Telescopes", Text: "choosing telescopes for astronomy"},
+			},
+			wantIDs: []int64{5, 6},
+			query:   "telescopes",
+			wantTop: 6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, cleanup, err := NewTempStore(ctx, dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cleanup()
+
+			ids, err := SeedCorpus(ctx, s.Pool, tt.docs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("ids %v, want %v", ids, tt.wantIDs)
+			}
+
+			terms, err := extract.ScanWordsFromString(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results, err := store.SearchBM25(ctx, s.Pool, terms, store.SearchOptions{MinDistinctMatches: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) == 0 || results[0].ID != tt.wantTop {
+				t.Errorf("results %+v, want %d first", results, tt.wantTop)
+			}
+		})
+	}
+}
+
+func TestNewTempStoreIsolation(t *testing.T) {
+	dsn, err := TestDSN()
+	if err != nil {
+		t.Skip(err)
+	}
+	ctx := context.Background()
+
+	first, cleanupFirst, err := NewTempStore(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, cleanupSecond, err := NewTempStore(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanupSecond()
+
+	var schema string
+	if err := first.Pool.QueryRow(ctx, "SELECT current_schema()").Scan(&schema); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SeedCorpus(ctx, first.Pool, []TestDoc{{Url: "https://example.com/a", Text: "only in the first store"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var docs int
+	if err := second.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM docs").Scan(&docs); err != nil {
+		t.Fatal(err)
+	}
+	if docs != 0 {
+		t.Errorf("second store sees %d documents from the first", docs)
+	}
+
+	cleanupFirst()
+	var exists bool
+	if err := second.Pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM information_schema.schemata WHERE schema_name = $1)", schema).Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Errorf("schema %s survived cleanup", schema)
+	}
+}
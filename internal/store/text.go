@@ -0,0 +1,56 @@
+// Package store provides storage of extracted document text.
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+)
+
+// upsert the compressed text for a document, replacing any previous version
+const upsertDocumentTextStmt = `INSERT INTO document_text (doc_id, body)
+VALUES ($1, $2)
+ON CONFLICT (doc_id) DO UPDATE SET
+	body = EXCLUDED.body;`
+
+const getDocumentTextStmt = `SELECT body FROM document_text WHERE doc_id = $1;`
+
+// insertDocumentText gzip-compresses a document's extracted text and stores it.
+func insertDocumentText(ctx context.Context, db DBTX, docId int64, text string) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(text)); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	_, err := db.Exec(ctx, upsertDocumentTextStmt, docId, buf.Bytes())
+	return err
+}
+
+// GetDocumentText returns the extracted visible text stored for a document.
+// It returns pgx.ErrNoRows if the text was not captured at index time.
+func GetDocumentText(ctx context.Context, db DBTX, docId int64) (string, error) {
+	var body []byte
+	if err := db.QueryRow(ctx, getDocumentTextStmt, docId).Scan(&body); err != nil {
+		return "", err
+	}
+	return decompressText(body)
+}
+
+// decompressText reverses the gzip compression applied by insertDocumentText.
+func decompressText(body []byte) (string, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+
+	text, err := io.ReadAll(zr)
+	if err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
@@ -0,0 +1,226 @@
+// Package store provides the term frequency weighting schemes shared by document
+// norms and cosine search.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TFScheme names how a raw term frequency is weighted before multiplying by idf.
+// Document norms and cosine scores must use the same scheme, or scores are wrong.
+type TFScheme string
+
+const (
+	TFRaw       TFScheme = "raw"       // tf
+	TFLog       TFScheme = "log"       // 1 + ln(tf); the default
+	TFAugmented TFScheme = "augmented" // 0.5 + 0.5 * tf / max tf in the document
+	TFBoolean   TFScheme = "boolean"   // 1 for any occurrence
+)
+
+// normTFSchemeKey is the index_meta key recording the scheme docs.norm was computed with.
+const normTFSchemeKey = "norm_tf_scheme"
+
+// ErrorNormSchemeMismatch is returned by SearchCosine when the requested TF scheme is
+// not the one the stored document norms were computed with.
+var ErrorNormSchemeMismatch = errors.New("tf scheme does not match stored document norms")
+
+// Validate returns an error if the scheme is unknown.
+func (s TFScheme) Validate() error {
+	switch s {
+	case TFRaw, TFLog, TFAugmented, TFBoolean:
+		return nil
+	}
+	return fmt.Errorf("unknown tf scheme %q", string(s))
+}
+
+// needsMaxTF reports whether sqlExpr reads the document's maximum tf_raw as max_tf,
+// which costs a pass over all of a document's postings.
+func (s TFScheme) needsMaxTF() bool {
+	return s == TFAugmented
+}
+
+// sqlExpr returns the SQL weighting tf_raw of postings alias p under the scheme.
+// The augmented scheme also needs the document's maximum tf_raw as max_tf; see needsMaxTF.
+func (s TFScheme) sqlExpr() string {
+	switch s {
+	case TFRaw:
+		return "p.tf_raw::real"
+	case TFAugmented:
+		return "(0.5 + 0.5 * p.tf_raw::real / p.max_tf)"
+	case TFBoolean:
+		return "1.0"
+	default:
+		return "(1.0 + LN(p.tf_raw::real))"
+	}
+}
+
+// documentNormsStmt builds the norm update for a scheme, ignoring terms in fewer
+// than minDF documents.
+// Norm formula: sqrt(sum((tf * idf)^2))
+func documentNormsStmt(scheme TFScheme, minDF int) string {
+	return `UPDATE docs d
+SET norm = x.norm
+FROM (` + documentNormsQuery(scheme, minDF) + `) x
+WHERE d.id = x.doc_id;`
+}
+
+// documentNormsQuery computes the norm of every document with a body posting, as
+// documentNormsStmt writes it. Only schemes that need max_tf pay for the window over
+// every posting that computes it.
+func documentNormsQuery(scheme TFScheme, minDF int) string {
+	maxTF := ""
+	if scheme.needsMaxTF() {
+		maxTF = ", MAX(tf_raw) OVER (PARTITION BY doc_id) AS max_tf"
+	}
+	return strings.NewReplacer("{tf}", scheme.sqlExpr(), "{minDF}", strconv.Itoa(minDF), "{maxTF}", maxTF).Replace(`
+  SELECT
+    p.doc_id,
+    SQRT(SUM(POWER({tf} * t.idf, 2))) AS norm
+  FROM (
+    SELECT term_id, doc_id, tf_raw{maxTF}
+    FROM postings
+    WHERE tf_raw > 0 -- title-only postings have no body frequency
+  ) p
+  JOIN terms t ON t.id = p.term_id AND t.df >= {minDF}
+  GROUP BY p.doc_id
+`)
+}
+
+// record a setting the index was built with, such as the scheme the norms were computed with
+const setIndexMetaStmt = `INSERT INTO index_meta (key, value) VALUES ($1, $2)
+ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value;`
+
+const getIndexMetaStmt = `SELECT value FROM index_meta WHERE key = $1;`
+
+// UpdateDocumentNormsWith updates every document's norm using the given TF scheme and
+// records the scheme, so cosine searches can check they weight terms the same way.
+func UpdateDocumentNormsWith(ctx context.Context, db DBTX, scheme TFScheme) error {
+	return UpdateDocumentNormsMinDF(ctx, db, scheme, 0)
+}
+
+// UpdateDocumentNormsMinDF is UpdateDocumentNormsWith leaving terms that appear in
+// fewer than minDF documents out of the norms, matching searches with the same MinDF.
+func UpdateDocumentNormsMinDF(ctx context.Context, db DBTX, scheme TFScheme, minDF int) error {
+	if err := scheme.Validate(); err != nil {
+		return err
+	}
+	if minDF < 0 {
+		return fmt.Errorf("min df must not be negative, got %d", minDF)
+	}
+
+	if _, err := db.Exec(ctx, documentNormsStmt(scheme, minDF)); err != nil {
+		return err
+	}
+	if _, err := db.Exec(ctx, setZeroNormForDocsWithNoPostingsStmt); err != nil {
+		return err
+	}
+	_, err := db.Exec(ctx, setIndexMetaStmt, normTFSchemeKey, string(scheme))
+	return err
+}
+
+// GetNormTFScheme returns the TF scheme the stored document norms were computed with.
+// Norms computed before schemes were recorded used TFLog.
+func GetNormTFScheme(ctx context.Context, db DBTX) (TFScheme, error) {
+	var value string
+	err := db.QueryRow(ctx, getIndexMetaStmt, normTFSchemeKey).Scan(&value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return TFLog, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return TFScheme(value), nil
+}
+
+// CosineOptions configures a SearchCosine query.
+type CosineOptions struct {
+	Limit  int      // Maximum number of results; 0 defaults to 10
+	Offset int      // Number of top results to skip, for pagination
+	TF     TFScheme // Expected TF scheme; empty uses whatever the stored norms were computed with
+	After  *Cursor  // Continue after a previous page's NextCursor; nil starts at the top
+}
+
+// searchCosineStmt ranks documents by the cosine similarity of their tf-idf vector with
+// the query's, where each unique query term is weighted by its idf. Results continue
+// after the cursor ($4, $5) when one is given; documents with no score come last and
+// can't be paged past.
+func searchCosineStmt(scheme TFScheme) string {
+	maxTF := ""
+	if scheme.needsMaxTF() {
+		maxTF = ",\n        (SELECT MAX(m.tf_raw) FROM postings m WHERE m.doc_id = p.doc_id) AS max_tf"
+	}
+	return strings.NewReplacer("{tf}", scheme.sqlExpr(), "{maxTF}", maxTF).Replace(`
+WITH
+  q AS (
+    SELECT t.id, t.idf
+    FROM terms t
+    WHERE t.raw = ANY($1::text[]) AND t.idf IS NOT NULL
+  ),
+  qn AS (
+    SELECT SQRT(SUM(idf * idf)) AS qnorm FROM q
+  ),
+  ranked AS (
+    SELECT
+      d.id,
+      d.url,
+      d.title,
+      d.snippet,
+      d.len,
+      (SUM({tf} * q.idf * q.idf) / NULLIF(d.norm * qn.qnorm, 0))::float8 AS score
+    FROM (
+      SELECT p.term_id, p.doc_id, p.tf_raw{maxTF}
+      FROM postings p
+      JOIN q ON q.id = p.term_id
+      WHERE p.tf_raw > 0
+    ) p
+    JOIN q ON q.id = p.term_id
+    JOIN docs d ON d.id = p.doc_id
+    CROSS JOIN qn
+    GROUP BY d.id, d.url, d.title, d.snippet, d.len, d.norm, qn.qnorm
+  )
+SELECT id, url, title, snippet, len, score
+FROM ranked
+WHERE $4::float8 IS NULL OR (score, id) < ($4::float8, $5::bigint)
+ORDER BY score DESC NULLS LAST, id DESC
+LIMIT $2
+OFFSET $3;`)
+}
+
+// SearchCosine ranks documents by tf-idf cosine similarity with the query terms, using the
+// TF scheme the document norms were computed with. If opts.TF names a different scheme
+// it returns ErrorNormSchemeMismatch rather than scores that mix two definitions of tf.
+func SearchCosine(ctx context.Context, db DBTX, terms []string, opts CosineOptions) ([]SearchResult, error) {
+	if len(terms) == 0 {
+		return nil, errors.New("no terms provided for search")
+	}
+	terms, _ = NormalizeQueryTerms(terms)
+
+	stored, err := GetNormTFScheme(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if opts.TF != "" && opts.TF != stored {
+		return nil, fmt.Errorf("%w: query uses %q, norms use %q", ErrorNormSchemeMismatch, opts.TF, stored)
+	}
+	if err := stored.Validate(); err != nil {
+		return nil, err
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 10 // default limit
+	}
+
+	afterScore, afterId := opts.After.args()
+	rows, err := db.Query(ctx, searchCosineStmt(stored), terms, limit, max(opts.Offset, 0), afterScore, afterId)
+	if err != nil {
+		return nil, err
+	}
+	return scanSearchResults(rows)
+}
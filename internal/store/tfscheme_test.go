@@ -0,0 +1,41 @@
+package store_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jdpolicano/go-search/internal/store"
+)
+
+func TestDocumentNormsPlanWindow(t *testing.T) {
+	s, _ := newSearchStore(t)
+
+	tests := []struct {
+		scheme     store.TFScheme
+		wantWindow bool // Only the augmented scheme needs each document's max tf
+	}{
+		{store.TFLog, false},
+		{store.TFRaw, false},
+		{store.TFBoolean, false},
+		{store.TFAugmented, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.scheme), func(t *testing.T) {
+			estimates, err := store.ExplainRankingUpdates(context.Background(), s.Pool, tt.scheme, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, est := range estimates {
+				if est.Phase != "document_norms" {
+					continue
+				}
+				if window := strings.Contains(est.Plan, "WindowAgg"); window != tt.wantWindow {
+					t.Errorf("plan has WindowAgg %t, want %t:\n%s", window, tt.wantWindow, est.Plan)
+				}
+				return
+			}
+			t.Fatal("no document_norms phase")
+		})
+	}
+}
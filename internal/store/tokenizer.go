@@ -0,0 +1,124 @@
+// Package store provides the record of the tokenizer settings an index was built with.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jdpolicano/go-search/internal/extract"
+)
+
+// index_meta keys recording tokenizer settings that change which terms are indexed
+const (
+	stemmingKey    = "stemming"     // Whether indexed terms were stemmed
+	keepNumbersKey = "keep_numbers" // Whether numbers were indexed
+	stopWordsKey   = "stop_words"   // Hash of the stop word set dropped from indexed terms
+)
+
+// ErrorTokenizerMismatch is returned when documents are about to be indexed with a
+// different tokenizer setting than the ones already in the index.
+var ErrorTokenizerMismatch = errors.New("tokenizer setting does not match the index")
+
+// check for any document, to tell an empty index from one built without a recorded setting
+const hasDocsStmt = `SELECT EXISTS (SELECT 1 FROM docs);`
+
+// GetIndexStemming reports whether the index's terms were stemmed, so queries can be
+// tokenized to match. Indexes built before the setting was recorded are unstemmed.
+func GetIndexStemming(ctx context.Context, db DBTX) (bool, error) {
+	return getIndexFlag(ctx, db, stemmingKey)
+}
+
+// GetIndexKeepNumbers reports whether the index's terms include numbers. Indexes
+// built before the setting was recorded don't.
+func GetIndexKeepNumbers(ctx context.Context, db DBTX) (bool, error) {
+	return getIndexFlag(ctx, db, keepNumbersKey)
+}
+
+// UseIndexTokenizer configures stemming and number handling in the extract package
+// to match the index, for processes that tokenize queries or add to an existing index.
+func UseIndexTokenizer(ctx context.Context, db DBTX) error {
+	stem, err := GetIndexStemming(ctx, db)
+	if err != nil {
+		return err
+	}
+	numbers, err := GetIndexKeepNumbers(ctx, db)
+	if err != nil {
+		return err
+	}
+	extract.SetStemming(stem)
+	extract.SetKeepNumbers(numbers)
+	return nil
+}
+
+// CheckIndexStemming records that documents are indexed with stemming enabled or not.
+// An empty index adopts the given setting; an index with documents returns
+// ErrorTokenizerMismatch if it was built with the other one, since stemmed and
+// unstemmed terms of the same word would never match each other.
+func CheckIndexStemming(ctx context.Context, db DBTX, enabled bool) error {
+	return checkIndexFlag(ctx, db, stemmingKey, enabled)
+}
+
+// CheckIndexKeepNumbers is CheckIndexStemming for whether numbers are indexed.
+func CheckIndexKeepNumbers(ctx context.Context, db DBTX, enabled bool) error {
+	return checkIndexFlag(ctx, db, keepNumbersKey, enabled)
+}
+
+// CheckIndexStopWords records the hash of the stop word set documents are indexed
+// with, see extract.StopWordsHash. An empty index, or one built before the set was
+// recorded, adopts the given hash; an index with documents returns
+// ErrorTokenizerMismatch if it was built with another set, since terms one set drops
+// would be missing from some documents and not others.
+func CheckIndexStopWords(ctx context.Context, db DBTX, hash string) error {
+	var stored string
+	err := db.QueryRow(ctx, getIndexMetaStmt, stopWordsKey).Scan(&stored)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	if err == nil && stored != hash {
+		var hasDocs bool
+		if err := db.QueryRow(ctx, hasDocsStmt).Scan(&hasDocs); err != nil {
+			return err
+		}
+		if hasDocs {
+			return fmt.Errorf("%w: indexing with stop word set %.12s, index built with %.12s", ErrorTokenizerMismatch, hash, stored)
+		}
+	}
+	_, err = db.Exec(ctx, setIndexMetaStmt, stopWordsKey, hash)
+	return err
+}
+
+// getIndexFlag reads a boolean index setting, false if it was never recorded.
+func getIndexFlag(ctx context.Context, db DBTX, key string) (bool, error) {
+	var value string
+	err := db.QueryRow(ctx, getIndexMetaStmt, key).Scan(&value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(value)
+}
+
+// checkIndexFlag records a boolean index setting, failing if the index already has
+// documents built with the other value.
+func checkIndexFlag(ctx context.Context, db DBTX, key string, enabled bool) error {
+	stored, err := getIndexFlag(ctx, db, key)
+	if err != nil {
+		return err
+	}
+	if stored != enabled {
+		var hasDocs bool
+		if err := db.QueryRow(ctx, hasDocsStmt).Scan(&hasDocs); err != nil {
+			return err
+		}
+		if hasDocs {
+			return fmt.Errorf("%w: indexing with %s %t, index built with %s %t", ErrorTokenizerMismatch, key, enabled, key, stored)
+		}
+	}
+	_, err = db.Exec(ctx, setIndexMetaStmt, key, strconv.FormatBool(enabled))
+	return err
+}
@@ -0,0 +1,90 @@
+// Package store provides per-document term vectors for relevance debugging.
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TermWeight is one term of a document's tf-idf vector.
+type TermWeight struct {
+	Term    string   `json:"term"`
+	TF      int      `json:"tf"`       // Raw frequency in the body
+	TFTitle int      `json:"tf_title"` // Raw frequency in the title
+	IDF     *float64 `json:"idf"`      // Nil until the ranker has computed idf
+	Weight  *float64 `json:"weight"`   // Weighted tf times idf, the term's component of the norm
+}
+
+// DocumentVector is a document's stored term weights, the document side of a
+// search explanation.
+type DocumentVector struct {
+	ID    int64        `json:"id"`
+	URL   string       `json:"url"`
+	Norm  *float64     `json:"norm"`      // Magnitude of the vector, nil until computed by the ranker
+	TF    TFScheme     `json:"tf_scheme"` // Scheme the weights and norm use
+	Terms []TermWeight `json:"terms"`     // Ordered by descending weight
+}
+
+const getDocumentNormStmt = `SELECT url, norm::float8 FROM docs WHERE id = $1;`
+
+// documentVectorStmt builds the term weights query for a scheme. Title-only
+// postings have no body frequency and so no weight.
+func documentVectorStmt(scheme TFScheme) string {
+	return strings.ReplaceAll(`SELECT
+  t.raw,
+  p.tf_raw,
+  p.tf_title,
+  t.idf::float8,
+  (CASE WHEN p.tf_raw > 0 THEN {tf} * t.idf ELSE 0 END)::float8 AS weight
+FROM (
+  SELECT term_id, tf_raw, tf_title, MAX(tf_raw) OVER () AS max_tf
+  FROM postings
+  WHERE doc_id = $1
+) p
+JOIN terms t ON t.id = p.term_id
+ORDER BY weight DESC NULLS LAST, t.raw;`, "{tf}", scheme.sqlExpr())
+}
+
+// GetDocumentVector returns a document's term weights, computed with the TF scheme
+// its norm was, so the weights' magnitude matches the stored norm. It returns
+// ErrorDocNotFound if no document has the id.
+func GetDocumentVector(ctx context.Context, db DBTX, docId int64) (DocumentVector, error) {
+	vector := DocumentVector{ID: docId}
+	err := db.QueryRow(ctx, getDocumentNormStmt, docId).Scan(&vector.URL, &vector.Norm)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return DocumentVector{}, ErrorDocNotFound
+	}
+	if err != nil {
+		return DocumentVector{}, err
+	}
+
+	vector.TF, err = GetNormTFScheme(ctx, db)
+	if err != nil {
+		return DocumentVector{}, err
+	}
+	if err := vector.TF.Validate(); err != nil {
+		return DocumentVector{}, err
+	}
+
+	rows, err := db.Query(ctx, documentVectorStmt(vector.TF), docId)
+	if err != nil {
+		return DocumentVector{}, err
+	}
+	defer rows.Close()
+
+	vector.Terms = []TermWeight{}
+	for rows.Next() {
+		var tw TermWeight
+		if err := rows.Scan(&tw.Term, &tw.TF, &tw.TFTitle, &tw.IDF, &tw.Weight); err != nil {
+			return DocumentVector{}, err
+		}
+		vector.Terms = append(vector.Terms, tw)
+	}
+	if err := rows.Err(); err != nil {
+		return DocumentVector{}, err
+	}
+	return vector, nil
+}
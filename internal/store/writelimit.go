@@ -0,0 +1,219 @@
+// Package store provides shared throttling of database writes.
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WriteLimiter bounds the number of concurrent write transactions shared by every
+// writer in a process, and adapts that bound to observed lock contention: each
+// lock or serialization failure halves the limit and backs all writers off for a
+// growing delay, while each success raises the limit by one again up to its maximum.
+// This way writers back off together instead of each retrying into the same locks.
+//
+// A limiter created by NewSharedWriteLimiter also bounds writes across processes, such
+// as a crawler and a separate ranker, sharing one database.
+//
+// A nil *WriteLimiter never blocks, so throttling can be disabled by leaving it unset.
+type WriteLimiter struct {
+	mu        sync.Mutex
+	max       int           // Upper bound on concurrent writes
+	limit     int           // Current bound, between 1 and max
+	inUse     int           // Writes currently holding a slot
+	backoff   time.Duration // Current global backoff after contention, 0 when uncontended
+	until     time.Time     // No slot is granted before this time
+	contended int64         // Total contention errors observed
+	wake      chan struct{} // Closed and replaced whenever a slot may have become available
+	pool      *pgxpool.Pool // Database whose advisory locks bound writes across processes; nil for in-process only
+	held      []sharedSlot  // Cross-process slots held by writes in this process
+	waits     int64         // Times every cross-process slot was taken by other writers
+}
+
+// sharedSlot is a cross-process write slot: a session advisory lock, held on its own
+// connection until the write is done.
+type sharedSlot struct {
+	conn *pgxpool.Conn
+	slot int
+}
+
+// WriteLimiterStats is a snapshot of a WriteLimiter's state for metrics.
+type WriteLimiterStats struct {
+	Max       int           // Upper bound on concurrent writes
+	Limit     int           // Current bound on concurrent writes
+	InUse     int           // Writes currently holding a slot
+	Backoff   time.Duration // Current global backoff, 0 when uncontended
+	Contended int64         // Total contention errors observed
+	Waits     int64         // Times a write waited for another process's writes; 0 unless shared
+}
+
+// minWriteBackoff and maxWriteBackoff bound the global backoff after contention.
+const (
+	minWriteBackoff = 25 * time.Millisecond
+	maxWriteBackoff = 2 * time.Second
+)
+
+// writeSlotLockClass is the first key of the advisory locks used as cross-process
+// write slots; the second is the slot number.
+const writeSlotLockClass = 0x67737771 // "gswq"
+
+// NewWriteLimiter creates a WriteLimiter allowing up to maxWrites concurrent writes.
+func NewWriteLimiter(maxWrites int) *WriteLimiter {
+	maxWrites = max(maxWrites, 1)
+	return &WriteLimiter{max: maxWrites, limit: maxWrites, wake: make(chan struct{})}
+}
+
+// NewSharedWriteLimiter creates a WriteLimiter allowing up to maxWrites concurrent
+// writes across every process using a shared limiter on the same database. Each write
+// also holds one of maxWrites advisory locks in pool, and with it a connection, so
+// processes should agree on maxWrites. Contention backoff stays per process.
+func NewSharedWriteLimiter(maxWrites int, pool *pgxpool.Pool) *WriteLimiter {
+	l := NewWriteLimiter(maxWrites)
+	l.pool = pool
+	return l
+}
+
+// Acquire blocks until a write slot is free and any backoff has passed, or ctx is done.
+func (l *WriteLimiter) Acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	if err := l.acquireLocal(ctx); err != nil {
+		return err
+	}
+	if l.pool == nil {
+		return nil
+	}
+	if err := l.acquireShared(ctx); err != nil {
+		l.releaseLocal(nil)
+		return err
+	}
+	return nil
+}
+
+// acquireLocal blocks until one of this process's write slots is free.
+func (l *WriteLimiter) acquireLocal(ctx context.Context) error {
+
+	for {
+		l.mu.Lock()
+		wait := time.Until(l.until)
+		if wait <= 0 && l.inUse < l.limit {
+			l.inUse++
+			l.mu.Unlock()
+			return nil
+		}
+		wake := l.wake
+		l.mu.Unlock()
+
+		var timer <-chan time.Time
+		if wait > 0 {
+			timer = time.After(wait)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wake:
+		case <-timer:
+		}
+	}
+}
+
+// acquireShared blocks until one of the cross-process write slots is free, polling
+// for one since advisory locks can't be waited on for any of several keys.
+func (l *WriteLimiter) acquireShared(ctx context.Context) error {
+	for {
+		conn, err := l.pool.Acquire(ctx)
+		if err != nil {
+			return err
+		}
+		for slot := range l.max {
+			var locked bool
+			if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1, $2)", writeSlotLockClass, slot).Scan(&locked); err != nil {
+				conn.Release()
+				return err
+			}
+			if locked {
+				l.mu.Lock()
+				l.held = append(l.held, sharedSlot{conn, slot})
+				l.mu.Unlock()
+				return nil
+			}
+		}
+		conn.Release()
+
+		l.mu.Lock()
+		l.waits++
+		l.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(minWriteBackoff):
+		}
+	}
+}
+
+// Release returns a slot taken by Acquire, adjusting the limit by the outcome of
+// the write: err is the write's result, and only retryable errors count as contention.
+func (l *WriteLimiter) Release(err error) {
+	if l == nil {
+		return
+	}
+	if l.pool != nil {
+		l.releaseShared()
+	}
+	l.releaseLocal(err)
+}
+
+// releaseShared unlocks one of the cross-process slots held by this process; they are
+// interchangeable, so it needn't be the one the releasing write took.
+func (l *WriteLimiter) releaseShared() {
+	l.mu.Lock()
+	if len(l.held) == 0 {
+		l.mu.Unlock()
+		return
+	}
+	held := l.held[len(l.held)-1]
+	l.held = l.held[:len(l.held)-1]
+	l.mu.Unlock()
+
+	// A connection that can't be unlocked is closed, which drops the lock with it
+	ctx := context.Background()
+	if _, err := held.conn.Exec(ctx, "SELECT pg_advisory_unlock($1, $2)", writeSlotLockClass, held.slot); err != nil {
+		held.conn.Hijack().Close(ctx)
+		return
+	}
+	held.conn.Release()
+}
+
+// releaseLocal returns one of this process's write slots and adjusts the limit.
+func (l *WriteLimiter) releaseLocal(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inUse--
+	if ErrorIsRetryable(err) {
+		l.contended++
+		l.limit = max(l.limit/2, 1)
+		l.backoff = min(max(l.backoff*2, minWriteBackoff), maxWriteBackoff)
+		l.until = time.Now().Add(l.backoff)
+	} else if err == nil {
+		l.limit = min(l.limit+1, l.max)
+		l.backoff = 0
+	}
+
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
+
+// Stats returns the limiter's current state.
+func (l *WriteLimiter) Stats() WriteLimiterStats {
+	if l == nil {
+		return WriteLimiterStats{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return WriteLimiterStats{l.max, l.limit, l.inUse, l.backoff, l.contended, l.waits}
+}